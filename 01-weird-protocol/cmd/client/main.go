@@ -4,16 +4,20 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalcertificate "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/certificate"
 	internalclient "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/client"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 )
 
 const (
 	// MenuMessage is the message for the menu
 	MenuMessage = `--- Welcome to the Client Application ---
 Current protocol: %s
+Current encoding: %s
 
 Please select an option:
 	1. Change the protocol
@@ -22,22 +26,73 @@ Please select an option:
 	4. Remove a file
 	5. Send a morse code
 	6. Exit
+	7. Change the encoding
+	8. Show the server capabilities
+	9. Render a morse code as audio
+	10. Decode a morse code audio
+	11. Send a batch of morse codes
+	12. Stream a morse code conversion
+	13. Create a directory
+	14. Remove a directory
+	15. Rename a file or directory
+	16. Move a file or directory
+	17. Get a file
+	18. List the files of a directory
+	19. Set the user token
+	20. Show the quota
+	21. Show the information of a file or directory
+	22. Verify the files checksums
+	23. Upload a local file in chunks
+	24. Resume an upload
+	25. Download a file in chunks
+	26. Synchronize a local directory
+	27. Watch the file changes
+	28. Open a chat session
 `
+
+	// ChatCommands is the help message of the chat session commands
+	ChatCommands = `Type a message to broadcast it, or a command:
+	/join <room>               Join a room
+	/leave <room>              Leave a room
+	/room <room> <message>     Send a message to a room
+	/msg <session> <message>   Send a private message to a session
+	/echo <message>            Send a message back to yourself
+An empty line closes the session`
+
+	// EnvTLSCAFile is the key for the optional CA file in the environment variables that verifies the certificate of the QUIC and TLS TCP servers, the system certificates are used if it is missing. A self-signed certificate of the server must be in it
+	EnvTLSCAFile = "TLS_CA_FILE"
+
+	// EnvTCPTLS is the key for the optional flag in the environment variables that connects to the TCP server with TLS, which it uses when it has a configured certificate
+	EnvTCPTLS = "TCP_TLS"
 )
 
 var (
+	// Protocols are the supported protocols
+	Protocols = []string{"TCP", "UDP", "QUIC"}
+
 	// Protocol is the current protocol
 	Protocol = "TCP"
 
+	// Encoding is the current encoding
+	Encoding = internalmessage.TextEncoding
+
 	// TCPAddr is the TCP address
 	TCPAddr *net.TCPAddr
 
 	// UDPAddr is the UDP address
 	UDPAddr *net.UDPAddr
+
+	// QUICAddr is the QUIC address, its host is verified in the certificate of the server
+	QUICAddr string
 )
 
 // HandleResponse the response from the server
 func HandleResponse(response string, err error) {
+	// Decode the response
+	if err == nil {
+		response, err = internalclient.DecodeResponse(Encoding, response)
+	}
+
 	if err != nil {
 		fmt.Printf("\nFailed to send message: %v\n\n", err.Error())
 	} else {
@@ -45,6 +100,14 @@ func HandleResponse(response string, err error) {
 	}
 }
 
+// PrintProgress prints the progress of a transfer
+func PrintProgress(offset, size int64) {
+	fmt.Printf("\rTransferred %d of %d bytes", offset, size)
+	if offset == size {
+		fmt.Println()
+	}
+}
+
 // ReadString reads a string from a reader
 func ReadString(message string, reader *bufio.Reader) (string, bool) {
 	fmt.Printf("%s: ", message)
@@ -56,6 +119,32 @@ func ReadString(message string, reader *bufio.Reader) (string, bool) {
 	return value[:len(value)-2], true
 }
 
+// SendChatCommand sends a line typed in a chat session, the lines that are not commands are broadcast
+func SendChatCommand(session *internalclient.ChatSession, line string) error {
+	if !strings.HasPrefix(line, "/") {
+		return session.Broadcast("", line)
+	}
+
+	// Get the command and its arguments
+	fields := strings.SplitN(line, " ", 3)
+	command := fields[0]
+	args := fields[1:]
+	switch {
+	case command == "/join" && len(args) == 1:
+		return session.Join(args[0])
+	case command == "/leave" && len(args) == 1:
+		return session.Leave(args[0])
+	case command == "/room" && len(args) == 2:
+		return session.Broadcast(args[0], args[1])
+	case command == "/msg" && len(args) == 2:
+		return session.Private(args[0], args[1])
+	case command == "/echo" && len(args) > 0:
+		return session.Echo(strings.Join(args, " "))
+	default:
+		return fmt.Errorf("invalid command: %s\n%s", line, ChatCommands)
+	}
+}
+
 func main() {
 	// Resolve the TCP address
 	tcpAddr, err := net.ResolveTCPAddr(
@@ -79,8 +168,21 @@ func main() {
 	}
 	UDPAddr = udpAddr
 
+	// Set the QUIC address
+	QUICAddr = "localhost:" + strconv.Itoa(internal.QUICPort)
+
+	// Create the TLS configuration of the QUIC connections and of the TCP ones if they use TLS, the servers are verified as localhost
+	tlsConfig, err := internalcertificate.NewClientConfig(os.Getenv(EnvTLSCAFile))
+	if err != nil {
+		fmt.Println("Error creating TLS configuration:", err)
+		os.Exit(1)
+	}
+	tlsConfig.ServerName = "localhost"
+	internalclient.TLSConfig = tlsConfig
+	internalclient.TCPTLS = os.Getenv(EnvTCPTLS) == "true"
+
 	// Build the send message function
-	sendMessage := internalclient.SendMessage(TCPAddr, UDPAddr)
+	sendMessage := internalclient.SendMessage(TCPAddr, UDPAddr, QUICAddr)
 
 	// Create a new reader
 	reader := bufio.NewReader(os.Stdin)
@@ -88,7 +190,7 @@ func main() {
 	// Print the menu options and prompt the user to select an option
 	for {
 		// Print the menu options
-		fmt.Printf(MenuMessage, Protocol)
+		fmt.Printf(MenuMessage, Protocol, Encoding)
 
 		// Read the user input
 		option, ok := ReadString("\nOption", reader)
@@ -100,11 +202,12 @@ func main() {
 		// Process the selected option
 		switch option {
 		case "1":
-			// Change the protocol
-			if Protocol == "TCP" {
-				Protocol = "UDP"
-			} else {
-				Protocol = "TCP"
+			// Change the protocol to the next supported one
+			for i, protocol := range Protocols {
+				if protocol == Protocol {
+					Protocol = Protocols[(i+1)%len(Protocols)]
+					break
+				}
 			}
 		case "2":
 			// Ask the user for the mail details
@@ -132,6 +235,7 @@ func main() {
 			HandleResponse(
 				internalclient.SendMailMessage(
 					Protocol,
+					Encoding,
 					subject,
 					message,
 					toName,
@@ -151,12 +255,25 @@ func main() {
 				return
 			}
 
+			mode, ok := ReadString("Mode (overwrite, create or append, empty to overwrite)", reader)
+			if !ok {
+				return
+			}
+
+			ifMatch, ok := ReadString("If match checksum (empty for none)", reader)
+			if !ok {
+				return
+			}
+
 			// Send the add file message
 			HandleResponse(
 				internalclient.SendAddFileMessage(
 					Protocol,
+					Encoding,
 					filename,
 					content,
+					mode,
+					ifMatch,
 					sendMessage,
 				),
 			)
@@ -171,6 +288,7 @@ func main() {
 			HandleResponse(
 				internalclient.SendRemoveFileMessage(
 					Protocol,
+					Encoding,
 					filename,
 					sendMessage,
 				),
@@ -194,12 +312,23 @@ func main() {
 			// Convert the string to a boolean
 			convertToMorse := string(convertToMorseStr) == "y"
 
+			// Ask for the alphabet
+			alphabet, ok := ReadString(
+				"Alphabet (empty for international)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
 			// Send the morse message
 			HandleResponse(
 				internalclient.SendMorseMessage(
 					Protocol,
+					Encoding,
 					message,
 					convertToMorse,
+					alphabet,
 					sendMessage,
 				),
 			)
@@ -207,6 +336,542 @@ func main() {
 			// Exit the application
 			fmt.Println("Exiting the application...")
 			os.Exit(0)
+		case "7":
+			// Change the encoding to the next supported one
+			for i, encoding := range internalmessage.Encodings {
+				if encoding == Encoding {
+					Encoding = internalmessage.Encodings[(i+1)%len(internalmessage.Encodings)]
+					break
+				}
+			}
+		case "8":
+			// Send the hello message
+			HandleResponse(
+				internalclient.SendHelloMessage(
+					Protocol,
+					Encoding,
+					sendMessage,
+				),
+			)
+		case "9":
+			// Ask the user for the morse audio details
+			message, ok := ReadString("message", reader)
+			if !ok {
+				return
+			}
+			wpmStr, ok := ReadString(
+				"Words per minute (empty for the default)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+			filename, ok := ReadString("filename", reader)
+			if !ok {
+				return
+			}
+
+			// Parse the words per minute
+			var wpm float64
+			if wpmStr != "" {
+				var err error
+				wpm, err = strconv.ParseFloat(wpmStr, 64)
+				if err != nil {
+					fmt.Println("Invalid words per minute. Please try again.")
+					continue
+				}
+			}
+
+			// Send the morse audio message
+			HandleResponse(
+				internalclient.SendMorseAudioMessage(
+					Protocol,
+					Encoding,
+					message,
+					wpm,
+					filename,
+					sendMessage,
+				),
+			)
+		case "10":
+			// Ask the user for the morse audio file details
+			filename, ok := ReadString("filename", reader)
+			if !ok {
+				return
+			}
+			alphabet, ok := ReadString(
+				"Alphabet (empty for international)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
+			// Send the morse audio decode message
+			HandleResponse(
+				internalclient.SendMorseAudioDecodeMessage(
+					Protocol,
+					Encoding,
+					filename,
+					alphabet,
+					sendMessage,
+				),
+			)
+		case "11":
+			// Ask the user for the messages, separated by a vertical bar
+			messages, ok := ReadString("messages (separated by |)", reader)
+			if !ok {
+				return
+			}
+
+			// Ask whether to convert to morse code or from morse code
+			convertToMorseStr, ok := ReadString(
+				"Convert to morse code? (y/n)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
+			// Ask for the alphabet
+			alphabet, ok := ReadString(
+				"Alphabet (empty for international)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
+			// Send the morse batch message
+			HandleResponse(
+				internalclient.SendMorseBatchMessage(
+					Protocol,
+					Encoding,
+					strings.Split(messages, "|"),
+					convertToMorseStr == "y",
+					alphabet,
+					sendMessage,
+				),
+			)
+		case "12":
+			// Ask whether to convert to morse code or from morse code
+			convertToMorseStr, ok := ReadString(
+				"Convert to morse code? (y/n)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
+			// Ask for the alphabet
+			alphabet, ok := ReadString(
+				"Alphabet (empty for international)",
+				reader,
+			)
+			if !ok {
+				return
+			}
+
+			// Start the stream, it is always sent over TCP
+			stream, err := internalclient.StartMorseStream(
+				TCPAddr,
+				Encoding,
+				convertToMorseStr == "y",
+				alphabet,
+			)
+			if err != nil {
+				fmt.Printf("\nFailed to start the stream: %v\n\n", err.Error())
+				continue
+			}
+
+			// Print the output as it arrives
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				err := stream.Receive(
+					func(output string) {
+						fmt.Print(output)
+					},
+				)
+				if err != nil {
+					fmt.Printf("\n%v\n", err.Error())
+				}
+			}()
+
+			// Send each line until an empty one
+			fmt.Println("Type the lines to convert, an empty line stops the stream")
+			for {
+				line, ok := ReadString("", reader)
+				if !ok || line == "" {
+					break
+				}
+				if err = stream.Send(line + "\n"); err != nil {
+					fmt.Println(err.Error())
+					break
+				}
+			}
+
+			// Stop the stream and wait for the rest of the output
+			if err = stream.Stop(); err != nil {
+				fmt.Println(err.Error())
+			}
+			<-done
+			if err = stream.Close(); err != nil {
+				fmt.Println("Error closing the stream:", err)
+			}
+			fmt.Println()
+		case "13":
+			// Ask the user for the mkdir details
+			directory, ok := ReadString("Directory", reader)
+			if !ok {
+				return
+			}
+
+			// Send the mkdir message
+			HandleResponse(
+				internalclient.SendMkdirMessage(
+					Protocol,
+					Encoding,
+					directory,
+					sendMessage,
+				),
+			)
+		case "14":
+			// Ask the user for the rmdir details
+			directory, ok := ReadString("Directory", reader)
+			if !ok {
+				return
+			}
+
+			// Send the rmdir message
+			HandleResponse(
+				internalclient.SendRmdirMessage(
+					Protocol,
+					Encoding,
+					directory,
+					sendMessage,
+				),
+			)
+		case "15":
+			// Ask the user for the rename details
+			from, ok := ReadString("From", reader)
+			if !ok {
+				return
+			}
+			to, ok := ReadString("To", reader)
+			if !ok {
+				return
+			}
+
+			// Send the rename message
+			HandleResponse(
+				internalclient.SendRenameMessage(
+					Protocol,
+					Encoding,
+					from,
+					to,
+					sendMessage,
+				),
+			)
+		case "16":
+			// Ask the user for the move details
+			filename, ok := ReadString("Filename", reader)
+			if !ok {
+				return
+			}
+			directory, ok := ReadString("Directory (empty for the root)", reader)
+			if !ok {
+				return
+			}
+
+			// Send the move message
+			HandleResponse(
+				internalclient.SendMoveMessage(
+					Protocol,
+					Encoding,
+					filename,
+					directory,
+					sendMessage,
+				),
+			)
+		case "17":
+			// Ask the user for the filename
+			filename, ok := ReadString("Filename", reader)
+			if !ok {
+				return
+			}
+
+			// Send the get file message
+			HandleResponse(
+				internalclient.SendGetFileMessage(
+					Protocol,
+					Encoding,
+					filename,
+					sendMessage,
+				),
+			)
+		case "18":
+			// Ask the user for the directory
+			directory, ok := ReadString("Directory (empty for the root)", reader)
+			if !ok {
+				return
+			}
+
+			// Send the list files message
+			HandleResponse(
+				internalclient.SendListFilesMessage(
+					Protocol,
+					Encoding,
+					directory,
+					sendMessage,
+				),
+			)
+		case "19":
+			// Ask the user for the token
+			token, ok := ReadString("Token (empty to be anonymous)", reader)
+			if !ok {
+				return
+			}
+			internalclient.Token = token
+		case "20":
+			// Send the quota message
+			HandleResponse(
+				internalclient.SendQuotaMessage(
+					Protocol,
+					Encoding,
+					sendMessage,
+				),
+			)
+		case "21":
+			// Ask the user for the filename
+			filename, ok := ReadString("Filename", reader)
+			if !ok {
+				return
+			}
+
+			// Send the stat message
+			HandleResponse(
+				internalclient.SendStatMessage(
+					Protocol,
+					Encoding,
+					filename,
+					sendMessage,
+				),
+			)
+		case "22":
+			// Send the verify message
+			HandleResponse(
+				internalclient.SendVerifyMessage(
+					Protocol,
+					Encoding,
+					sendMessage,
+				),
+			)
+		case "23":
+			// Ask the user for the upload details
+			localPath, ok := ReadString("Local file path", reader)
+			if !ok {
+				return
+			}
+			content, err := os.ReadFile(localPath)
+			if err != nil {
+				fmt.Printf("\nError reading file: %v\n\n", err.Error())
+				continue
+			}
+			filename, ok := ReadString("Filename", reader)
+			if !ok {
+				return
+			}
+
+			// Upload the file, the session is shown so it can be resumed if it fails
+			session, response, err := internalclient.UploadFile(
+				Protocol,
+				Encoding,
+				filename,
+				content,
+				sendMessage,
+				PrintProgress,
+			)
+			if session != "" {
+				fmt.Printf("\nUpload session: %s\n", session)
+			}
+			HandleResponse(response, err)
+		case "24":
+			// Ask the user for the resume details
+			session, ok := ReadString("Upload session", reader)
+			if !ok {
+				return
+			}
+			localPath, ok := ReadString("Local file path", reader)
+			if !ok {
+				return
+			}
+			content, err := os.ReadFile(localPath)
+			if err != nil {
+				fmt.Printf("\nError reading file: %v\n\n", err.Error())
+				continue
+			}
+
+			// Resume the upload
+			HandleResponse(
+				internalclient.ResumeUpload(
+					Protocol,
+					Encoding,
+					session,
+					content,
+					sendMessage,
+					PrintProgress,
+				),
+			)
+		case "25":
+			// Ask the user for the download details
+			filename, ok := ReadString("Filename", reader)
+			if !ok {
+				return
+			}
+			localPath, ok := ReadString("Local file path", reader)
+			if !ok {
+				return
+			}
+
+			// Download the file and save it
+			content, err := internalclient.DownloadFile(
+				Protocol,
+				Encoding,
+				filename,
+				sendMessage,
+				PrintProgress,
+			)
+			if err == nil {
+				err = os.WriteFile(localPath, content, 0644)
+			}
+			if err != nil {
+				fmt.Printf("\nFailed to download file: %v\n\n", err.Error())
+			} else {
+				fmt.Printf("\nFile downloaded successfully: %d bytes\n\n", len(content))
+			}
+		case "26":
+			// Ask the user for the synchronization details
+			localDirectory, ok := ReadString("Local directory", reader)
+			if !ok {
+				return
+			}
+			remoteDirectory, ok := ReadString("Remote directory (empty for the root)", reader)
+			if !ok {
+				return
+			}
+			deleteExtras, ok := ReadString("Delete the remote files that do not exist locally (y/n)", reader)
+			if !ok {
+				return
+			}
+			dryRun, ok := ReadString("Dry run, only show the differences (y/n)", reader)
+			if !ok {
+				return
+			}
+
+			// Synchronize the directory, showing the result of each operation
+			operations, err := internalclient.SyncDirectory(
+				Protocol,
+				Encoding,
+				localDirectory,
+				remoteDirectory,
+				deleteExtras == "y",
+				dryRun == "y",
+				sendMessage,
+				func(operation *internalclient.SyncOperation, response string) {
+					fmt.Printf("%s: %s\n", operation.String(), response)
+				},
+			)
+			if dryRun == "y" {
+				for _, operation := range operations {
+					fmt.Println(operation.String())
+				}
+			}
+			if err != nil {
+				fmt.Printf("\nFailed to synchronize: %v\n\n", err.Error())
+			} else {
+				fmt.Printf("\nSynchronized successfully: %d operations\n\n", len(operations))
+			}
+		case "27":
+			// Ask for the watched directory
+			directory, ok := ReadString("Directory (empty for all the files)", reader)
+			if !ok {
+				return
+			}
+
+			// Start the watch, it is always done over TCP
+			watcher, err := internalclient.StartWatch(TCPAddr, Encoding, directory)
+			if err != nil {
+				fmt.Printf("\nFailed to start the watch: %v\n\n", err.Error())
+				continue
+			}
+
+			// Print the events as they arrive
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				err := watcher.Receive(
+					func(event string) {
+						fmt.Println(event)
+					},
+				)
+				if err != nil {
+					fmt.Printf("\n%v\n", err.Error())
+				}
+			}()
+
+			// Stop the watch when the user presses enter
+			fmt.Println("Watching the file changes, press enter to stop")
+			_, ok = ReadString("", reader)
+			if err = watcher.Close(); err != nil {
+				fmt.Println(err.Error())
+			}
+			<-done
+			if !ok {
+				return
+			}
+			fmt.Println()
+		case "28":
+			// Open the chat session, it is always done over TCP
+			session, err := internalclient.StartChat(TCPAddr, Encoding)
+			if err != nil {
+				fmt.Printf("\nFailed to open the chat session: %v\n\n", err.Error())
+				continue
+			}
+
+			// Print the responses and the messages as they arrive
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				err := session.Receive(
+					func(message string) {
+						fmt.Println(message)
+					},
+				)
+				if err != nil {
+					fmt.Printf("\n%v\n", err.Error())
+				}
+			}()
+
+			// Send the commands until an empty line
+			fmt.Printf("Chat session %s, listening for messages\n", session.ID)
+			fmt.Println(ChatCommands)
+			for {
+				line, ok := ReadString("", reader)
+				if !ok || line == "" {
+					break
+				}
+				if err = SendChatCommand(session, line); err != nil {
+					fmt.Println(err.Error())
+				}
+			}
+
+			// Close the session
+			if err = session.Close(); err != nil {
+				fmt.Println(err.Error())
+			}
+			<-done
+			fmt.Println()
 
 		default:
 			// Invalid option
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
-	goconcurrency "github.com/ralvarezdev/go-concurrency"
 	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
 	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
 	internalhandler "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/server"
-	"net"
+	"log/slog"
 	"os"
 	"sync"
 )
@@ -19,100 +19,93 @@ func init() {
 func main() {
 	var wg sync.WaitGroup
 
-	// Start the TCP server on a separate goroutine
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	// Get the transports, the TCP connections use TLS if a certificate is configured and the QUIC ones always use it
+	var tcpTLSConfig *tls.Config
+	if internalloader.TCPTLS {
+		tcpTLSConfig = internalloader.TLSConfig
+	}
+	transports := []internalhandler.Listener{
+		internalhandler.NewTCPTransport(
+			fmt.Sprintf("0.0.0.0:%d", internal.TCPPort),
+			tcpTLSConfig,
+		),
+		internalhandler.NewUDPTransport(fmt.Sprintf("0.0.0.0:%d", internal.UDPPort)),
+		internalhandler.NewQUICTransport(
+			fmt.Sprintf("0.0.0.0:%d", internal.QUICPort),
+			internalloader.TLSConfig,
+		),
+		internalhandler.NewWebSocketTransport(
+			fmt.Sprintf("0.0.0.0:%d", internal.WebSocketPort),
+			internalloader.WebSocketOrigins,
+		),
+	}
 
-		// Listen on a port
-		address := &net.TCPAddr{
-			Port: internal.TCPPort,
-			IP:   net.ParseIP("0.0.0.0"),
-		}
-		listener, err := net.ListenTCP(
-			"tcp",
-			address,
+	// Add the Unix domain sockets, if they are enabled
+	if internalloader.UnixSocketPath != "" {
+		transports = append(
+			transports,
+			internalhandler.NewUnixTransport(
+				internalloader.UnixSocketPath,
+				internalloader.UnixSocketMode,
+			),
 		)
-		if err != nil {
-			fmt.Println("Error starting TCP server: ", err)
-			os.Exit(1)
-		}
-		defer func(listener net.Listener) {
-			err := listener.Close()
-			if err != nil {
-				fmt.Println("Error closing listener:", err)
-			}
-		}(listener)
-		fmt.Printf("Server is listening on port %d\n", internal.TCPPort)
-
-		// Create a safe connection number
-		connNumber := goconcurrency.NewSafeNumber(0)
-
-		for {
-			// Accept a connection
-			conn, err := listener.Accept()
-			if err != nil {
-				fmt.Println("Error accepting connection:", err)
-				continue
-			}
-
-			// Handle the connection
-			go internalhandler.HandleIncomingData(
-				internalhandler.HandleTCPConnection(
-					conn,
-					connNumber.IncrementAndGetValue(),
-				),
-			)
-		}
-	}()
-
-	// Start the UDP server on a separate goroutine
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	}
+	if internalloader.UnixDatagramSocketPath != "" {
+		transports = append(
+			transports,
+			internalhandler.NewUnixgramTransport(
+				internalloader.UnixDatagramSocketPath,
+				internalloader.UnixSocketMode,
+			),
+		)
+	}
 
-		// Listen on a port
-		addr := net.UDPAddr{
-			Port: internal.UDPPort,
-			IP:   net.ParseIP("0.0.0.0"),
-		}
-		conn, err := net.ListenUDP("udp", &addr)
-		if err != nil {
-			fmt.Println("Error starting UDP server:", err)
-			os.Exit(1)
-		}
-		defer func(conn *net.UDPConn) {
-			err := conn.Close()
-			if err != nil {
-				fmt.Println("Error closing connection:", err)
-			}
-		}(conn)
-		fmt.Printf("Server is listening on port %d\n", internal.UDPPort)
+	// Add the serial port and the HTTP gateway, if they are enabled
+	if config := internalloader.SerialConfig; config != nil {
+		transports = append(transports, internalhandler.NewSerialTransport(config))
+	}
+	if internalloader.HTTPGatewayAddress != "" {
+		transports = append(
+			transports,
+			internalhandler.NewGatewayTransport(internalloader.HTTPGatewayAddress),
+		)
+	}
 
-		// Create a safe connection number
-		connNumber := goconcurrency.NewSafeNumber(0)
+	// Start the server of each transport on a separate goroutine
+	for _, transport := range transports {
+		wg.Add(1)
+		go func(transport internalhandler.Listener) {
+			defer wg.Done()
 
-		buffer := make([]byte, 1024)
-		for {
-			// Read data from the connection
-			n, clientAddr, err := conn.ReadFromUDP(buffer)
-			if err != nil {
-				fmt.Println("Error reading from connection:", err)
-				continue
+			// Listen for requests
+			if err := transport.Listen(); err != nil {
+				slog.Error(
+					"error starting server",
+					internalhandler.TransportLogKey, transport.Protocol(),
+					internalhandler.ErrorLogKey, err,
+				)
+				os.Exit(1)
 			}
-			data := string(buffer[:n])
-
-			// Handle the incoming data
-			go internalhandler.HandleIncomingData(
-				internalhandler.HandleUDPIncomingData(
-					conn,
-					connNumber.IncrementAndGetValue(),
-					clientAddr,
-					&data,
-				),
+			defer func(transport internalhandler.Listener) {
+				err := transport.Close()
+				if err != nil {
+					slog.Error(
+						"error closing listener",
+						internalhandler.TransportLogKey, transport.Protocol(),
+						internalhandler.ErrorLogKey, err,
+					)
+				}
+			}(transport)
+			slog.Info(
+				"server is listening",
+				internalhandler.TransportLogKey, transport.Protocol(),
+				"address", transport.Address(),
 			)
-		}
-	}()
+
+			// Handle the requests until the transport is closed
+			internalhandler.Serve(transport)
+		}(transport)
+	}
 
 	// Wait for all goroutines to finish
 	wg.Wait()
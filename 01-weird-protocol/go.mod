@@ -3,16 +3,42 @@ module github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protoco
 go 1.23.4
 
 require (
+	github.com/fxamacker/cbor/v2 v2.9.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/mailersend/mailersend-go v1.5.1
+	github.com/minio/minio-go/v7 v7.0.95
+	github.com/quic-go/quic-go v0.54.0
 	github.com/ralvarezdev/go-concurrency v0.1.1
 	github.com/ralvarezdev/go-loader v0.2.14
 	github.com/ralvarezdev/go-morse v0.1.2
+	go.bug.st/serial v1.6.4
+	golang.org/x/sys v0.33.0
 )
 
 require (
+	github.com/creack/goselect v0.1.2 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/go-ini/ini v1.67.0 // indirect
+	github.com/goccy/go-json v0.10.5 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.11 // indirect
+	github.com/minio/crc64nvme v1.0.2 // indirect
+	github.com/minio/md5-simd v1.1.2 // indirect
+	github.com/philhofer/fwd v1.2.0 // indirect
 	github.com/ralvarezdev/go-flags v0.3.2 // indirect
 	github.com/ralvarezdev/go-logger v0.4.6 // indirect
 	github.com/ralvarezdev/go-strings v0.1.7 // indirect
+	github.com/rs/xid v1.6.0 // indirect
+	github.com/tinylib/msgp v1.3.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.39.0 // indirect
+	golang.org/x/mod v0.25.0 // indirect
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sync v0.15.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+	golang.org/x/tools v0.33.0 // indirect
 )
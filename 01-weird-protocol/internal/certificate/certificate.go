@@ -0,0 +1,127 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// SelfSignedOrganization is the organization of the self-signed certificates
+	SelfSignedOrganization = "Weird Protocol"
+
+	// SelfSignedValidity is the validity of the self-signed certificates
+	SelfSignedValidity = 365 * 24 * time.Hour
+)
+
+// SelfSignedHosts are the hosts of the self-signed certificates, the server is reached locally
+var SelfSignedHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// GenerateSelfSigned generates a self-signed certificate for the given hosts, which can be names or IP addresses
+func GenerateSelfSigned(hosts []string) (*tls.Certificate, error) {
+	// Generate the private key
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the template of the certificate
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{SelfSignedOrganization}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	// Sign the certificate with its own key
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// NewServerConfig creates the TLS configuration of a server with the certificate and key files, a self-signed certificate is generated if they are empty
+func NewServerConfig(certFile, keyFile string) (*tls.Config, error) {
+	// Check if the certificate is self-signed
+	if certFile == "" && keyFile == "" {
+		certificate, err := GenerateSelfSigned(SelfSignedHosts)
+		if err != nil {
+			return nil, fmt.Errorf("error generating self-signed certificate: %v", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{*certificate},
+			MinVersion:   tls.VersionTLS13,
+		}, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both the certificate and the key files are required")
+	}
+
+	// Load the certificate
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %v", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// WriteCertificateFile writes the certificate chain of a certificate to a PEM file, so the clients can trust a self-signed certificate with it as their CA file
+func WriteCertificateFile(certificate *tls.Certificate, path string) error {
+	var data []byte
+	for _, der := range certificate.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing certificate file: %v", err)
+	}
+	return nil
+}
+
+// NewClientConfig creates the TLS configuration of a client that trusts the certificates of the CA file, or the ones of the system if it is empty. The certificate of the server is always verified, a self-signed one must be in the CA file
+func NewClientConfig(caFile string) (*tls.Config, error) {
+	if caFile == "" {
+		return &tls.Config{MinVersion: tls.VersionTLS13}, nil
+	}
+
+	// Load the certificates of the CA file
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA file: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in the CA file %s", caFile)
+	}
+	return &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS13,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+)
+
+// handshake connects a client to a TLS server with the given configurations, returning the error of the handshake of the client
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// TestClientConfig tests that the clients verify the self-signed certificate of the server with the written certificate file, and reject it without it
+func TestClientConfig(t *testing.T) {
+	serverConfig, err := NewServerConfig("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Check the certificate is not trusted without the CA file
+	clientConfig, err := NewClientConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = handshake(t, serverConfig, clientConfig); err == nil {
+		t.Errorf("expected the self-signed certificate to be rejected")
+	}
+
+	// Check the certificate is trusted with the written file
+	caFile := filepath.Join(t.TempDir(), "server.pem")
+	if err = WriteCertificateFile(&serverConfig.Certificates[0], caFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if clientConfig, err = NewClientConfig(caFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = handshake(t, serverConfig, clientConfig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Check another self-signed certificate is not trusted
+	otherConfig, err := NewServerConfig("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = handshake(t, otherConfig, clientConfig); err == nil {
+		t.Errorf("expected another self-signed certificate to be rejected")
+	}
+}
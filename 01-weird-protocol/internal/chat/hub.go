@@ -0,0 +1,341 @@
+package chat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	internalevents "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/events"
+	"sort"
+	"sync"
+)
+
+const (
+	// SessionIDSize is the size in bytes of the random session IDs
+	SessionIDSize = 8
+
+	// MaxMessageSize is the maximum size in bytes of a chat message
+	MaxMessageSize = 16 * 1024
+
+	// SessionTopicPrefix is the prefix of the event bus topics of the sessions
+	SessionTopicPrefix = "chat/"
+)
+
+const (
+	// MessageEvent is the type of the events of the messages
+	MessageEvent = "message"
+
+	// JoinedEvent is the type of the events of the sessions joining a room
+	JoinedEvent = "joined"
+
+	// LeftEvent is the type of the events of the sessions leaving a room
+	LeftEvent = "left"
+)
+
+const (
+	// BroadcastKind is the kind of the messages sent to all the sessions
+	BroadcastKind = "broadcast"
+
+	// PrivateKind is the kind of the messages sent to a session
+	PrivateKind = "private"
+
+	// RoomKind is the kind of the messages sent to the sessions of a room
+	RoomKind = "room"
+
+	// EchoKind is the kind of the messages sent back to their sender
+	EchoKind = "echo"
+)
+
+var (
+	// ErrSessionNotFound is the error for a session that is not connected
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrNotInRoom is the error for a room the session did not join
+	ErrNotInRoom = errors.New("the session is not in the room")
+
+	// ErrEmptyMessage is the error for an empty message
+	ErrEmptyMessage = errors.New("message is empty")
+
+	// ErrEmptyRoom is the error for an empty room name
+	ErrEmptyRoom = errors.New("room name is empty")
+)
+
+type (
+	// Session is a connected chat session, its events are the messages and notifications routed to it
+	Session struct {
+		// ID is the ID of the session
+		ID string
+
+		// User is the user of the session, empty for the anonymous ones
+		User string
+
+		subscription *internalevents.Subscription
+	}
+
+	// Hub tracks the connected chat sessions and their rooms, and routes the messages between them through the event bus
+	Hub struct {
+		bus      *internalevents.Bus
+		mutex    sync.RWMutex
+		sessions map[string]*Session
+		rooms    map[string]map[string]struct{}
+	}
+)
+
+// NewHub creates a new chat hub that routes the messages through the given event bus
+func NewHub(bus *internalevents.Bus) *Hub {
+	return &Hub{
+		bus:      bus,
+		sessions: make(map[string]*Session),
+		rooms:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Events returns the channel of the events routed to the session
+func (s *Session) Events() <-chan *internalevents.Event {
+	return s.subscription.Events()
+}
+
+// Dropped returns and resets the number of events dropped since the last call because the session was too slow
+func (s *Session) Dropped() int64 {
+	return s.subscription.Dropped()
+}
+
+// Actor returns the actor of the events of the session
+func (s *Session) Actor() string {
+	if s.User == "" {
+		return s.ID
+	}
+	return s.User
+}
+
+// newSessionID creates a new random session ID
+func newSessionID() (string, error) {
+	id := make([]byte, SessionIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// checkMessage checks the size of a message
+func checkMessage(message string) error {
+	if message == "" {
+		return ErrEmptyMessage
+	}
+	if len(message) > MaxMessageSize {
+		return fmt.Errorf("message too large, the maximum is %d bytes", MaxMessageSize)
+	}
+	return nil
+}
+
+// Connect connects a new session of a user
+func (h *Hub) Connect(user string) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		ID:           id,
+		User:         user,
+		subscription: h.bus.Subscribe(SessionTopicPrefix + id),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.sessions[id] = session
+	return session, nil
+}
+
+// Disconnect disconnects a session, it leaves all its rooms
+func (h *Hub) Disconnect(session *Session) {
+	h.mutex.Lock()
+	delete(h.sessions, session.ID)
+	var rooms []string
+	for room, members := range h.rooms {
+		if _, ok := members[session.ID]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	h.mutex.Unlock()
+
+	// Leave the rooms, notifying the other members
+	for _, room := range rooms {
+		_ = h.Leave(session, room)
+	}
+	session.subscription.Cancel()
+}
+
+// Sessions returns the sorted IDs of the connected sessions
+func (h *Hub) Sessions() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	ids := make([]string, 0, len(h.sessions))
+	for id := range h.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Members returns the sorted IDs of the sessions of a room
+func (h *Hub) Members(room string) []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	ids := make([]string, 0, len(h.rooms[room]))
+	for id := range h.rooms[room] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// publish publishes an event to the given sessions
+func (h *Hub) publish(ids []string, eventType, actor string, data map[string]any) {
+	for _, id := range ids {
+		h.bus.Publish(
+			&internalevents.Event{
+				Topic: SessionTopicPrefix + id,
+				Type:  eventType,
+				Actor: actor,
+				Data:  data,
+			},
+		)
+	}
+}
+
+// Join joins a session to a room, the other members are notified. It returns the members of the room
+func (h *Hub) Join(session *Session, room string) ([]string, error) {
+	if room == "" {
+		return nil, ErrEmptyRoom
+	}
+
+	h.mutex.Lock()
+	if _, ok := h.rooms[room]; !ok {
+		h.rooms[room] = make(map[string]struct{})
+	}
+	h.rooms[room][session.ID] = struct{}{}
+	h.mutex.Unlock()
+
+	// Notify the other members of the room
+	members := h.Members(room)
+	var others []string
+	for _, id := range members {
+		if id != session.ID {
+			others = append(others, id)
+		}
+	}
+	h.publish(
+		others, JoinedEvent, session.Actor(), map[string]any{
+			"session": session.ID,
+			"room":    room,
+		},
+	)
+	return members, nil
+}
+
+// Leave removes a session from a room, the other members are notified
+func (h *Hub) Leave(session *Session, room string) error {
+	h.mutex.Lock()
+	members, ok := h.rooms[room]
+	if ok {
+		_, ok = members[session.ID]
+	}
+	if !ok {
+		h.mutex.Unlock()
+		return fmt.Errorf("%s: %w", room, ErrNotInRoom)
+	}
+	delete(members, session.ID)
+	if len(members) == 0 {
+		delete(h.rooms, room)
+	}
+	h.mutex.Unlock()
+
+	// Notify the remaining members of the room
+	h.publish(
+		h.Members(room), LeftEvent, session.Actor(), map[string]any{
+			"session": session.ID,
+			"room":    room,
+		},
+	)
+	return nil
+}
+
+// Broadcast sends a message to all the other sessions. It returns the number of recipients
+func (h *Hub) Broadcast(session *Session, message string) (int, error) {
+	if err := checkMessage(message); err != nil {
+		return 0, err
+	}
+
+	// Get the other sessions
+	var ids []string
+	for _, id := range h.Sessions() {
+		if id != session.ID {
+			ids = append(ids, id)
+		}
+	}
+
+	h.publish(
+		ids, MessageEvent, session.Actor(), map[string]any{
+			"kind":    BroadcastKind,
+			"from":    session.ID,
+			"message": message,
+		},
+	)
+	return len(ids), nil
+}
+
+// SendRoom sends a message to the other members of a room the session joined. It returns the number of recipients
+func (h *Hub) SendRoom(session *Session, room, message string) (int, error) {
+	if err := checkMessage(message); err != nil {
+		return 0, err
+	}
+
+	// Get the other members of the room
+	var ids []string
+	inRoom := false
+	for _, id := range h.Members(room) {
+		if id == session.ID {
+			inRoom = true
+		} else {
+			ids = append(ids, id)
+		}
+	}
+	if !inRoom {
+		return 0, fmt.Errorf("%s: %w", room, ErrNotInRoom)
+	}
+
+	h.publish(
+		ids, MessageEvent, session.Actor(), map[string]any{
+			"kind":    RoomKind,
+			"from":    session.ID,
+			"room":    room,
+			"message": message,
+		},
+	)
+	return len(ids), nil
+}
+
+// SendPrivate sends a message to a session
+func (h *Hub) SendPrivate(session *Session, to, message string) error {
+	if err := checkMessage(message); err != nil {
+		return err
+	}
+
+	// Check if the recipient is connected
+	h.mutex.RLock()
+	_, ok := h.sessions[to]
+	h.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("%s: %w", to, ErrSessionNotFound)
+	}
+
+	h.publish(
+		[]string{to}, MessageEvent, session.Actor(), map[string]any{
+			"kind":    PrivateKind,
+			"from":    session.ID,
+			"to":      to,
+			"message": message,
+		},
+	)
+	return nil
+}
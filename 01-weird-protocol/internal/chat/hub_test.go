@@ -0,0 +1,211 @@
+package chat
+
+import (
+	"errors"
+	internalevents "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/events"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// connectSessions connects a session for each user to a new hub
+func connectSessions(t *testing.T, users ...string) (*Hub, []*Session) {
+	t.Helper()
+	hub := NewHub(internalevents.NewBus())
+	sessions := make([]*Session, len(users))
+	for i, user := range users {
+		session, err := hub.Connect(user)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		t.Cleanup(func() { hub.Disconnect(session) })
+		sessions[i] = session
+	}
+	return hub, sessions
+}
+
+// drainEvents returns the events routed to a session so far, they are delivered by the bus before the publisher returns
+func drainEvents(session *Session) []*internalevents.Event {
+	var events []*internalevents.Event
+	for {
+		select {
+		case event, ok := <-session.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// assertMessages checks the messages routed to each session, by their text
+func assertMessages(t *testing.T, sessions []*Session, expected ...[]string) {
+	t.Helper()
+	for i, session := range sessions {
+		var messages []string
+		for _, event := range drainEvents(session) {
+			if event.Type == MessageEvent {
+				messages = append(messages, event.Data["message"].(string))
+			}
+		}
+		if !reflect.DeepEqual(messages, expected[i]) {
+			t.Errorf("session %d: expected %q, got %q", i, expected[i], messages)
+		}
+	}
+}
+
+// TestHubBroadcast tests that a broadcast is routed to all the sessions but its sender
+func TestHubBroadcast(t *testing.T) {
+	hub, sessions := connectSessions(t, "alice", "bob", "")
+	recipients, err := hub.Broadcast(sessions[0], "hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recipients != 2 {
+		t.Errorf("expected 2 recipients, got %d", recipients)
+	}
+	assertMessages(t, sessions, nil, []string{"hello"}, []string{"hello"})
+
+	// Check the actor of the anonymous sessions is their ID
+	if _, err = hub.Broadcast(sessions[2], "anonymous"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := drainEvents(sessions[0])
+	if len(events) != 1 || events[0].Actor != sessions[2].ID || events[0].Data["kind"] != BroadcastKind {
+		t.Errorf("expected a broadcast of the anonymous session, got %v", events)
+	}
+}
+
+// TestHubRooms tests that the room messages are only routed to the other members of the room, and the members are notified of the joins and leaves
+func TestHubRooms(t *testing.T) {
+	hub, sessions := connectSessions(t, "alice", "bob", "carol")
+	for _, session := range sessions[:2] {
+		if _, err := hub.Join(session, "general"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Check the first member is notified of the join of the second one
+	events := drainEvents(sessions[0])
+	if len(events) != 1 || events[0].Type != JoinedEvent || events[0].Data["session"] != sessions[1].ID {
+		t.Errorf("expected the join of bob, got %v", events)
+	}
+	members := []string{sessions[0].ID, sessions[1].ID}
+	sort.Strings(members)
+	if !reflect.DeepEqual(hub.Members("general"), members) {
+		t.Errorf("expected %v, got %v", members, hub.Members("general"))
+	}
+
+	// Send a message to the room
+	recipients, err := hub.SendRoom(sessions[0], "general", "hi room")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recipients != 1 {
+		t.Errorf("expected 1 recipient, got %d", recipients)
+	}
+	assertMessages(t, sessions, nil, []string{"hi room"}, nil)
+
+	// Check a session outside the room cannot send to it
+	if _, err = hub.SendRoom(sessions[2], "general", "intruder"); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("expected %v, got %v", ErrNotInRoom, err)
+	}
+	assertMessages(t, sessions, nil, nil, nil)
+
+	// Check the remaining member is notified of the leave, and the leaving session does not get the next messages
+	if err = hub.Leave(sessions[1], "general"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events = drainEvents(sessions[0])
+	if len(events) != 1 || events[0].Type != LeftEvent || events[0].Data["session"] != sessions[1].ID {
+		t.Errorf("expected the leave of bob, got %v", events)
+	}
+	if _, err = hub.SendRoom(sessions[0], "general", "alone"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertMessages(t, sessions, nil, nil, nil)
+	if err = hub.Leave(sessions[1], "general"); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("expected %v, got %v", ErrNotInRoom, err)
+	}
+
+	// Check the empty rooms are removed
+	if err = hub.Leave(sessions[0], "general"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := hub.rooms["general"]; ok {
+		t.Errorf("expected the empty room to be removed")
+	}
+}
+
+// TestHubPrivate tests that a private message is only routed to its recipient
+func TestHubPrivate(t *testing.T) {
+	hub, sessions := connectSessions(t, "alice", "bob", "carol")
+	if err := hub.SendPrivate(sessions[0], sessions[2].ID, "secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := drainEvents(sessions[2])
+	if len(events) != 1 || events[0].Data["kind"] != PrivateKind || events[0].Data["from"] != sessions[0].ID {
+		t.Errorf("expected the private message of alice, got %v", events)
+	}
+	assertMessages(t, sessions, nil, nil, nil)
+
+	// Check the messages to unknown and disconnected sessions are rejected
+	if err := hub.SendPrivate(sessions[0], "unknown", "secret"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected %v, got %v", ErrSessionNotFound, err)
+	}
+	hub.Disconnect(sessions[1])
+	if err := hub.SendPrivate(sessions[0], sessions[1].ID, "secret"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected %v, got %v", ErrSessionNotFound, err)
+	}
+}
+
+// TestHubDisconnect tests that a disconnected session leaves its rooms, notifying their members, and its events channel is closed
+func TestHubDisconnect(t *testing.T) {
+	hub, sessions := connectSessions(t, "alice", "bob")
+	for _, room := range []string{"general", "random"} {
+		for _, session := range sessions {
+			if _, err := hub.Join(session, room); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+	drainEvents(sessions[0])
+
+	hub.Disconnect(sessions[1])
+	var leftRooms []string
+	for _, event := range drainEvents(sessions[0]) {
+		if event.Type == LeftEvent {
+			leftRooms = append(leftRooms, event.Data["room"].(string))
+		}
+	}
+	if len(leftRooms) != 2 {
+		t.Errorf("expected the leaves of both rooms, got %v", leftRooms)
+	}
+	if sessionIDs := hub.Sessions(); !reflect.DeepEqual(sessionIDs, []string{sessions[0].ID}) {
+		t.Errorf("expected only alice, got %v", sessionIDs)
+	}
+	if _, ok := <-sessions[1].Events(); ok {
+		t.Errorf("expected the events channel to be closed")
+	}
+}
+
+// TestHubMessageErrors tests the rejected messages and room names
+func TestHubMessageErrors(t *testing.T) {
+	hub, sessions := connectSessions(t, "alice", "bob")
+	if _, err := hub.Broadcast(sessions[0], ""); !errors.Is(err, ErrEmptyMessage) {
+		t.Errorf("expected %v, got %v", ErrEmptyMessage, err)
+	}
+	if _, err := hub.Broadcast(sessions[0], strings.Repeat("a", MaxMessageSize+1)); err == nil {
+		t.Errorf("expected an error for a message too large")
+	}
+	if err := hub.SendPrivate(sessions[0], sessions[1].ID, ""); !errors.Is(err, ErrEmptyMessage) {
+		t.Errorf("expected %v, got %v", ErrEmptyMessage, err)
+	}
+	if _, err := hub.Join(sessions[0], ""); !errors.Is(err, ErrEmptyRoom) {
+		t.Errorf("expected %v, got %v", ErrEmptyRoom, err)
+	}
+	assertMessages(t, sessions, nil, nil)
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"net"
+)
+
+// ChatSession is a chat session on a persistent TCP connection, the requests and the responses are frames, and the server pushes the messages of the other sessions between them
+type ChatSession struct {
+	// ID is the ID of the session, the other sessions send the private messages to it
+	ID string
+
+	conn     net.Conn
+	reader   *bufio.Reader
+	encoding internalmessage.Encoding
+}
+
+// StartChat opens a chat session, the server confirms it with the ID of the session
+func StartChat(
+	address *net.TCPAddr,
+	encoding internalmessage.Encoding,
+) (*ChatSession, error) {
+	conn, reader, fields, err := StartFramedSession(
+		address,
+		encoding,
+		internal.ChatHeader,
+		internalmessage.Fields{},
+		"session",
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := fields.GetString("session")
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &ChatSession{ID: id, conn: conn, reader: reader, encoding: encoding}, nil
+}
+
+// Send sends a framed request of the session, its response is read by Receive
+func (c *ChatSession) Send(header string, body internalmessage.Fields) error {
+	message, err := internalmessage.Encode(c.encoding, NewRequest(header, body))
+	if err != nil {
+		return fmt.Errorf("error encoding request: %v", err.Error())
+	}
+	if _, err = c.conn.Write([]byte(internalmessage.EncodeFrame(message))); err != nil {
+		return fmt.Errorf("error sending message: %v", err.Error())
+	}
+	return nil
+}
+
+// Broadcast sends a message to all the other sessions, or to the other members of a room if it is not empty
+func (c *ChatSession) Broadcast(room, message string) error {
+	body := internalmessage.Fields{
+		"message": internalmessage.NewString(message),
+	}
+	if room != "" {
+		body["room"] = internalmessage.NewString(room)
+	}
+	return c.Send(internal.BroadcastHeader, body)
+}
+
+// Private sends a message to a session
+func (c *ChatSession) Private(to, message string) error {
+	return c.Send(
+		internal.PrivateHeader, internalmessage.Fields{
+			"to":      internalmessage.NewString(to),
+			"message": internalmessage.NewString(message),
+		},
+	)
+}
+
+// Echo sends a message back to the session
+func (c *ChatSession) Echo(message string) error {
+	return c.Send(
+		internal.EchoHeader, internalmessage.Fields{
+			"message": internalmessage.NewString(message),
+		},
+	)
+}
+
+// Join joins a room
+func (c *ChatSession) Join(room string) error {
+	return c.Send(
+		internal.JoinHeader, internalmessage.Fields{
+			"room": internalmessage.NewString(room),
+		},
+	)
+}
+
+// Leave leaves a room
+func (c *ChatSession) Leave(room string) error {
+	return c.Send(
+		internal.LeaveHeader, internalmessage.Fields{
+			"room": internalmessage.NewString(room),
+		},
+	)
+}
+
+// Receive reads the responses and the pushed messages until the connection is closed, they are decoded before being passed to the message function
+func (c *ChatSession) Receive(messageFn func(message string)) error {
+	return ReceiveFrames(c.reader, c.encoding, messageFn)
+}
+
+// Close closes the session and its connection
+func (c *ChatSession) Close() error {
+	return c.conn.Close()
+}
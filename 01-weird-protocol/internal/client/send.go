@@ -1,81 +1,107 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"github.com/quic-go/quic-go"
 	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
 	"log"
 	"net"
+	"time"
 )
 
-const (
-	// MailMessageFormat is the mail message format
-	MailMessageFormat = `
-header: "%s",
-body: {
-	subject: %s,
-	message: "%s",
-	to: {
-		name: "%s",
-		email: "%s"
-	}
-}`
-
-	// MorseMessageFormat is the morse message format
-	MorseMessageFormat = `
-header: "%s",
-body: {
-	message: "%s",
-	to: "%s"
-}`
-
-	// AddFileMessageFormat is the add file message format
-	AddFileMessageFormat = `
-header: "%s",
-body: {
-	filename: "%s",
-	content: "%s"
-}
-`
-
-	// RemoveFileMessageFormat is the remove file message format
-	RemoveFileMessageFormat = `
-header: "%s",
-body: {
-	filename: "%s"
-}`
+// UDPResponseTimeout is the time to wait for the response of a UDP request
+const UDPResponseTimeout = 5 * time.Second
+
+var (
+	// Token is the token of the user of the requests, they are anonymous if it is empty
+	Token string
+
+	// TLSConfig is the TLS configuration of the QUIC connections, and of the TCP ones if they use TLS
+	TLSConfig = &tls.Config{MinVersion: tls.VersionTLS13}
+
+	// TCPTLS is true if the TCP connections use TLS, the server uses it when it has a configured certificate
+	TCPTLS bool
 )
 
+// NewRequest creates a new request with the given header and body, for the current protocol version and with the token of the user
+func NewRequest(
+	header string,
+	body internalmessage.Fields,
+) *internalmessage.Value {
+	fields := internalmessage.Fields{
+		"version": internalmessage.NewInt(internal.ProtocolVersion),
+		"header":  internalmessage.NewString(header),
+		"body":    internalmessage.NewObject(body),
+	}
+	if Token != "" {
+		fields["token"] = internalmessage.NewString(Token)
+	}
+	return internalmessage.NewObject(fields)
+}
+
+// SendRequest encodes a request with the given encoding and sends it to the server
+func SendRequest(
+	protocol string,
+	encoding internalmessage.Encoding,
+	request *internalmessage.Value,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Encode the request
+	message, err := internalmessage.Encode(encoding, request)
+	if err != nil {
+		return "", fmt.Errorf("error encoding request: %v", err.Error())
+	}
+
+	// Send the request
+	return sendMessage(protocol, message)
+}
+
+// DialTCP connects to the TCP server, with TLS if it is enabled
+func DialTCP(address *net.TCPAddr) (net.Conn, error) {
+	if TCPTLS {
+		return tls.Dial("tcp", address.String(), TLSConfig)
+	}
+	return net.DialTCP("tcp", nil, address)
+}
+
 // SendTCPMessage sends a message to the TCP server
 func SendTCPMessage(
 	address *net.TCPAddr,
 	message string,
 ) (response string, err error) {
 	// Connect to the TCP server
-	conn, err := net.DialTCP("tcp", nil, address)
+	conn, err := DialTCP(address)
 	if err != nil {
 		return "", fmt.Errorf("error connecting to TCP server: %v", err.Error())
 	}
-	defer func(conn *net.TCPConn) {
+	defer func(conn net.Conn) {
 		err := conn.Close()
 		if err != nil {
 			log.Println("error closing connection:", err)
 		}
 	}(conn)
 
-	// Send the message to the server
-	_, err = conn.Write([]byte(message))
+	// Send the message to the server, framed with its length
+	_, err = conn.Write([]byte(internalmessage.EncodeFrame(message)))
 	if err != nil {
 		return "", fmt.Errorf("error sending message: %v", err.Error())
 	}
 
-	// Read the response from the server
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
+	// Read the response from the server, until it closes the connection
+	data, err := io.ReadAll(conn)
 	if err != nil {
 		return "", fmt.Errorf("error reading response: %v", err.Error())
 	}
 
-	return string(buffer[:n]), nil
+	return string(data), nil
 }
 
 // SendUDPMessage sends a message to the UDP server
@@ -101,8 +127,11 @@ func SendUDPMessage(
 		return "", fmt.Errorf("error sending message: %v", err.Error())
 	}
 
-	// Read the response from the server
-	buffer := make([]byte, 1024)
+	// Read the response from the server, the datagrams can be lost
+	if err = conn.SetReadDeadline(time.Now().Add(UDPResponseTimeout)); err != nil {
+		return "", fmt.Errorf("error setting read deadline: %v", err.Error())
+	}
+	buffer := make([]byte, internal.MaxDatagramSize)
 	n, _, err := conn.ReadFromUDP(buffer)
 	if err != nil {
 		return "", fmt.Errorf("error reading response: %v", err.Error())
@@ -111,10 +140,53 @@ func SendUDPMessage(
 	return string(buffer[:n]), nil
 }
 
+// SendQUICMessage sends a message to the QUIC server, on a stream of a new connection. The host of the address is the name verified in the certificate of the server
+func SendQUICMessage(
+	address string,
+	message string,
+) (response string, err error) {
+	// Connect to the QUIC server
+	tlsConfig := TLSConfig.Clone()
+	tlsConfig.NextProtos = []string{internal.QUICProtocol}
+	conn, err := quic.DialAddr(context.Background(), address, tlsConfig, nil)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to QUIC server: %v", err.Error())
+	}
+	defer func(conn *quic.Conn) {
+		err := conn.CloseWithError(0, "")
+		if err != nil {
+			log.Println("error closing connection:", err)
+		}
+	}(conn)
+
+	// Open a stream
+	stream, err := conn.OpenStreamSync(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("error opening stream: %v", err.Error())
+	}
+
+	// Send the message to the server framed with its length, and close the writing side of the stream
+	if _, err = stream.Write([]byte(internalmessage.EncodeFrame(message))); err != nil {
+		return "", fmt.Errorf("error sending message: %v", err.Error())
+	}
+	if err = stream.Close(); err != nil {
+		return "", fmt.Errorf("error sending message: %v", err.Error())
+	}
+
+	// Read the response from the server, until it closes its writing side of the stream
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err.Error())
+	}
+
+	return string(data), nil
+}
+
 // SendMessage sends a message to the server
 func SendMessage(
 	tpcAddress *net.TCPAddr,
 	udpAddress *net.UDPAddr,
+	quicAddress string,
 ) func(protocol string, message string) (response string, err error) {
 	return func(protocol string, message string) (response string, err error) {
 		switch protocol {
@@ -122,6 +194,8 @@ func SendMessage(
 			return SendTCPMessage(tpcAddress, message)
 		case "UDP":
 			return SendUDPMessage(udpAddress, message)
+		case "QUIC":
+			return SendQUICMessage(quicAddress, message)
 		default:
 			return "", fmt.Errorf("unsupported protocol: %s", protocol)
 		}
@@ -130,23 +204,32 @@ func SendMessage(
 
 // SendMailMessage sends a mail message to the server
 func SendMailMessage(
-	protocol, subject, message, toName, toEmail string,
+	protocol string,
+	encoding internalmessage.Encoding,
+	subject, message, toName, toEmail string,
 	sendMessage func(protocol string, message string) (
 		response string,
 		err error,
 	),
 ) (response string, err error) {
 	// Send the mail
-	response, err = sendMessage(
+	response, err = SendRequest(
 		protocol,
-		fmt.Sprintf(
-			MailMessageFormat,
+		encoding,
+		NewRequest(
 			internal.MailHeader,
-			subject,
-			message,
-			toName,
-			toEmail,
+			internalmessage.Fields{
+				"subject": internalmessage.NewString(subject),
+				"message": internalmessage.NewString(message),
+				"to": internalmessage.NewObject(
+					internalmessage.Fields{
+						"name":  internalmessage.NewString(toName),
+						"email": internalmessage.NewString(toEmail),
+					},
+				),
+			},
 		),
+		sendMessage,
 	)
 	if err != nil {
 		return "", fmt.Errorf("error sending mail: %v", err.Error())
@@ -156,7 +239,11 @@ func SendMailMessage(
 
 // SendMorseMessage sends a morse message to the server
 func SendMorseMessage(
-	protocol, message string, convertToMorse bool,
+	protocol string,
+	encoding internalmessage.Encoding,
+	message string,
+	convertToMorse bool,
+	alphabet string,
 	sendMessage func(protocol string, message string) (
 		response string,
 		err error,
@@ -169,11 +256,22 @@ func SendMorseMessage(
 	} else {
 		to = internal.MorseToText
 	}
+	body := internalmessage.Fields{
+		"message": internalmessage.NewString(message),
+		"to":      internalmessage.NewString(to),
+	}
+
+	// Set the alphabet, the server uses the international one if it is empty
+	if alphabet != "" {
+		body["alphabet"] = internalmessage.NewString(alphabet)
+	}
 
 	// Send the morse message
-	response, err = sendMessage(
+	response, err = SendRequest(
 		protocol,
-		fmt.Sprintf(MorseMessageFormat, internal.MorseHeader, message, to),
+		encoding,
+		NewRequest(internal.MorseHeader, body),
+		sendMessage,
 	)
 	if err != nil {
 		return "", fmt.Errorf("error sending morse message: %v", err.Error())
@@ -181,23 +279,172 @@ func SendMorseMessage(
 	return response, nil
 }
 
+// SendMorseBatchMessage sends many morse messages to be converted in a single request
+func SendMorseBatchMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	messages []string,
+	convertToMorse bool,
+	alphabet string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Check if the messages should be converted to morse
+	var to string
+	if convertToMorse {
+		to = internal.MorseToMorse
+	} else {
+		to = internal.MorseToText
+	}
+	messageValues := make([]*internalmessage.Value, len(messages))
+	for i, message := range messages {
+		messageValues[i] = internalmessage.NewString(message)
+	}
+	body := internalmessage.Fields{
+		"messages": internalmessage.NewList(messageValues...),
+		"to":       internalmessage.NewString(to),
+	}
+
+	// Set the alphabet, the server uses the international one if it is empty
+	if alphabet != "" {
+		body["alphabet"] = internalmessage.NewString(alphabet)
+	}
+
+	// Send the morse batch message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(internal.MorseHeader, body),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending morse batch message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendMorseAudioMessage sends a morse message to be rendered as a WAV file, saved by the server with the given filename
+func SendMorseAudioMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	message string,
+	wpm float64,
+	filename string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Set the audio options, the server uses its default speed if it is zero
+	audio := internalmessage.Fields{
+		"filename": internalmessage.NewString(filename),
+	}
+	if wpm != 0 {
+		audio["wpm"] = internalmessage.NewFloat(wpm)
+	}
+
+	// Send the morse audio message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.MorseHeader, internalmessage.Fields{
+				"message": internalmessage.NewString(message),
+				"to":      internalmessage.NewString(internal.MorseToAudio),
+				"audio":   internalmessage.NewObject(audio),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending morse audio message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendMorseAudioDecodeMessage sends a message to decode the morse code of a WAV file of the server files folder to text
+func SendMorseAudioDecodeMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	alphabet string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	body := internalmessage.Fields{
+		"to":   internalmessage.NewString(internal.MorseToText),
+		"from": internalmessage.NewString(internal.MorseFromAudio),
+		"audio": internalmessage.NewObject(
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+			},
+		),
+	}
+
+	// Set the alphabet, the server uses the international one if it is empty
+	if alphabet != "" {
+		body["alphabet"] = internalmessage.NewString(alphabet)
+	}
+
+	// Send the morse audio decode message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(internal.MorseHeader, body),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending morse audio decode message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
 // SendAddFileMessage sends an add file message to the server
 func SendAddFileMessage(
-	protocol, filename, content string,
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename, content, mode, ifMatch string,
 	sendMessage func(protocol string, message string) (
 		response string,
 		err error,
 	),
 ) (response string, err error) {
+	// Set the checksum of the content, the server verifies it before writing the file
+	body := internalmessage.Fields{
+		"filename": internalmessage.NewString(filename),
+		"content":  internalmessage.NewString(content),
+		"checksum": internalmessage.NewString(internalstorage.Checksum([]byte(content))),
+	}
+
+	// Set the mode, the server overwrites the file if it is empty
+	if mode != "" {
+		body["mode"] = internalmessage.NewString(mode)
+	}
+
+	// Set the checksum of the condition, the server writes the file unconditionally if it is empty
+	if ifMatch != "" {
+		body["ifmatch"] = internalmessage.NewString(ifMatch)
+	}
+
 	// Send the add file message
-	response, err = sendMessage(
+	response, err = SendRequest(
 		protocol,
-		fmt.Sprintf(
-			AddFileMessageFormat,
-			internal.AddFileHeader,
-			filename,
-			content,
-		),
+		encoding,
+		NewRequest(internal.AddFileHeader, body),
+		sendMessage,
 	)
 	if err != nil {
 		return "", fmt.Errorf("error sending add file message: %v", err.Error())
@@ -207,20 +454,25 @@ func SendAddFileMessage(
 
 // SendRemoveFileMessage sends a remove file message to the server
 func SendRemoveFileMessage(
-	protocol, filename string,
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
 	sendMessage func(protocol string, message string) (
 		response string,
 		err error,
 	),
 ) (response string, err error) {
 	// Send the remove file message
-	response, err = sendMessage(
+	response, err = SendRequest(
 		protocol,
-		fmt.Sprintf(
-			RemoveFileMessageFormat,
+		encoding,
+		NewRequest(
 			internal.RemoveFileHeader,
-			filename,
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+			},
 		),
+		sendMessage,
 	)
 	if err != nil {
 		return "", fmt.Errorf(
@@ -230,3 +482,313 @@ func SendRemoveFileMessage(
 	}
 	return response, nil
 }
+
+// SendGetFileMessage sends a get file message to the server
+func SendGetFileMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the get file message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.GetFileHeader,
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending get file message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendListFilesMessage sends a list files message to the server, an empty directory is the files root
+func SendListFilesMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	directory string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the list files message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.ListFilesHeader,
+			internalmessage.Fields{
+				"directory": internalmessage.NewString(directory),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending list files message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendStatMessage sends a stat message to the server, to get the information of a file or directory
+func SendStatMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the stat message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.StatHeader,
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error sending stat message: %v", err.Error())
+	}
+	return response, nil
+}
+
+// SendVerifyMessage sends a verify message to the server, to check the content of the files with their stored checksums
+func SendVerifyMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the verify message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(internal.VerifyHeader, internalmessage.Fields{}),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error sending verify message: %v", err.Error())
+	}
+	return response, nil
+}
+
+// SendMkdirMessage sends a mkdir message to the server to create a directory
+func SendMkdirMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	directory string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the mkdir message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.MkdirHeader,
+			internalmessage.Fields{
+				"directory": internalmessage.NewString(directory),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending mkdir message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendRmdirMessage sends a rmdir message to the server to remove an empty directory
+func SendRmdirMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	directory string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the rmdir message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.RmdirHeader,
+			internalmessage.Fields{
+				"directory": internalmessage.NewString(directory),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending rmdir message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendRenameMessage sends a rename message to the server to rename a file or directory
+func SendRenameMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	from string,
+	to string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the rename message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.RenameHeader,
+			internalmessage.Fields{
+				"from": internalmessage.NewString(from),
+				"to":   internalmessage.NewString(to),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending rename message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendMoveMessage sends a move message to the server to move a file or directory into a directory
+func SendMoveMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	directory string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the move message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.MoveHeader,
+			internalmessage.Fields{
+				"filename":  internalmessage.NewString(filename),
+				"directory": internalmessage.NewString(directory),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf(
+			"error sending move message: %v",
+			err.Error(),
+		)
+	}
+	return response, nil
+}
+
+// SendHelloMessage sends a hello message to the server to negotiate the protocol version and get its capabilities
+func SendHelloMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the hello message with the supported versions
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.HelloHeader,
+			internalmessage.Fields{
+				"versions": internalmessage.NewList(
+					internalmessage.NewInt(internal.LegacyProtocolVersion),
+					internalmessage.NewInt(internal.ProtocolVersion),
+				),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error sending hello message: %v", err.Error())
+	}
+	return response, nil
+}
+
+// SendQuotaMessage sends a quota message to the server, to get the usage and the limits of the file storage of the user
+func SendQuotaMessage(
+	protocol string,
+	encoding internalmessage.Encoding,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	// Send the quota message
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(internal.QuotaHeader, internalmessage.Fields{}),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error sending quota message: %v", err.Error())
+	}
+	return response, nil
+}
+
+// DecodeResponse decodes a response to be shown as text, responses of binary encodings are converted to the text format
+func DecodeResponse(
+	encoding internalmessage.Encoding,
+	response string,
+) (string, error) {
+	// Check if the encoding is binary
+	if !encoding.IsBinary() {
+		return response, nil
+	}
+
+	// Decode the response
+	value, err := internalmessage.DecodeCBOR(&response)
+	if err != nil {
+		return "", fmt.Errorf("error decoding response: %v", err.Error())
+	}
+	return internalmessage.EncodeText(value)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"net"
+)
+
+// MorseStream is a streaming morse conversion on a persistent TCP connection, the server writes the converted chunks as frames
+type MorseStream struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	encoding internalmessage.Encoding
+}
+
+// StartMorseStream starts a streaming morse conversion, the server confirms it before the chunks can be sent
+func StartMorseStream(
+	address *net.TCPAddr,
+	encoding internalmessage.Encoding,
+	convertToMorse bool,
+	alphabet string,
+) (*MorseStream, error) {
+	// Check if the message should be converted to morse
+	var to string
+	if convertToMorse {
+		to = internal.MorseToMorse
+	} else {
+		to = internal.MorseToText
+	}
+	body := internalmessage.Fields{
+		"to":     internalmessage.NewString(to),
+		"stream": internalmessage.NewString(internal.MorseStreamStart),
+	}
+
+	// Set the alphabet, the server uses the international one if it is empty
+	if alphabet != "" {
+		body["alphabet"] = internalmessage.NewString(alphabet)
+	}
+
+	// Start the stream, the server confirms it in a frame
+	conn, reader, _, err := StartFramedSession(
+		address,
+		encoding,
+		internal.MorseHeader,
+		body,
+		"stream",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &MorseStream{conn: conn, reader: reader, encoding: encoding}, nil
+}
+
+// Send sends a chunk to be converted
+func (m *MorseStream) Send(chunk string) error {
+	if _, err := m.conn.Write([]byte(chunk)); err != nil {
+		return fmt.Errorf("error sending chunk: %v", err.Error())
+	}
+	return nil
+}
+
+// Stop sends the stop message, the server writes the rest of the conversion and closes the connection
+func (m *MorseStream) Stop() error {
+	return m.Send(internal.MorseStreamStop)
+}
+
+// Receive reads the converted chunks until the server closes the connection, an error message of the server ends the conversion
+func (m *MorseStream) Receive(outputFn func(output string)) error {
+	for {
+		// Read the next frame
+		frame, err := internalmessage.ReadFrame(m.reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading output: %v", err.Error())
+		}
+		fields, err := internalmessage.Parse(m.encoding, &frame)
+		if err != nil {
+			return fmt.Errorf("error decoding output: %v", err.Error())
+		}
+
+		// Check if it is an error message
+		if fields.Has("error") {
+			message, err := fields.GetString("error")
+			if err != nil {
+				return fmt.Errorf("error decoding output: %v", err.Error())
+			}
+			return fmt.Errorf("error converting the stream: %v", message)
+		}
+
+		// Get the converted chunk
+		output, err := fields.GetString("output")
+		if err != nil {
+			return fmt.Errorf("error decoding output: %v", err.Error())
+		}
+		outputFn(output)
+	}
+}
+
+// Close closes the connection
+func (m *MorseStream) Close() error {
+	return m.conn.Close()
+}
@@ -0,0 +1,378 @@
+package client
+
+import (
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncAction is the action of a synchronization operation
+type SyncAction string
+
+const (
+	// SyncMkdir creates a remote directory that only exists locally
+	SyncMkdir SyncAction = "mkdir"
+
+	// SyncUpload uploads a local file that does not exist remotely
+	SyncUpload SyncAction = "upload"
+
+	// SyncUpdate uploads a local file whose checksum is different from the remote one
+	SyncUpdate SyncAction = "update"
+
+	// SyncDelete removes a remote file that does not exist locally
+	SyncDelete SyncAction = "delete"
+
+	// SyncRmdir removes a remote directory that does not exist locally
+	SyncRmdir SyncAction = "rmdir"
+
+	// SyncConflict is a path that is a file on one side and a directory on the other, it is reported but not synchronized
+	SyncConflict SyncAction = "conflict"
+)
+
+type (
+	// SyncOperation is an operation that makes a remote path match the local one
+	SyncOperation struct {
+		// Action is the action of the operation
+		Action SyncAction
+
+		// LocalPath is the path of the local file or directory, empty for the deletions
+		LocalPath string
+
+		// RemotePath is the path of the remote file or directory
+		RemotePath string
+
+		// Size is the size in bytes of the local file of the uploads
+		Size int64
+	}
+
+	// SyncEntry is a file or directory of a side of a synchronization, with a path relative to the synchronized directory
+	SyncEntry struct {
+		// IsDir is true if it is a directory
+		IsDir bool
+
+		// Size is the size in bytes of the file
+		Size int64
+
+		// Checksum is the checksum of the file, empty if it is unknown
+		Checksum string
+	}
+)
+
+// String returns the operation as a line of the diff report
+func (s *SyncOperation) String() string {
+	switch s.Action {
+	case SyncUpload, SyncUpdate:
+		return fmt.Sprintf("%-8s %s (%d bytes)", s.Action, s.RemotePath, s.Size)
+	default:
+		return fmt.Sprintf("%-8s %s", s.Action, s.RemotePath)
+	}
+}
+
+// ListLocalEntries lists the files and directories of a local directory recursively, by their slash-separated path relative to it, with the checksums of the files
+func ListLocalEntries(directory string) (map[string]*SyncEntry, error) {
+	entries := make(map[string]*SyncEntry)
+	err := filepath.WalkDir(
+		directory, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			relativePath, err := filepath.Rel(directory, filePath)
+			if err != nil || relativePath == "." {
+				return err
+			}
+			relativePath = filepath.ToSlash(relativePath)
+
+			// Add the directory
+			if entry.IsDir() {
+				entries[relativePath] = &SyncEntry{IsDir: true}
+				return nil
+			}
+
+			// Skip the special files, like the symbolic links
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			// Add the file with its checksum
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			entries[relativePath] = &SyncEntry{
+				Size:     int64(len(content)),
+				Checksum: internalstorage.Checksum(content),
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListRemoteEntries lists the files and directories of a remote directory recursively with the list files message, by their path relative to it. An empty directory is the root
+func ListRemoteEntries(
+	protocol string,
+	encoding internalmessage.Encoding,
+	directory string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (map[string]*SyncEntry, error) {
+	entries := make(map[string]*SyncEntry)
+	directories := []string{directory}
+	for len(directories) > 0 {
+		// Get the next directory
+		currentDirectory := directories[len(directories)-1]
+		directories = directories[:len(directories)-1]
+
+		// List its files and directories
+		fields, err := SendTransferRequest(
+			protocol,
+			encoding,
+			internal.ListFilesHeader,
+			internalmessage.Fields{
+				"directory": internalmessage.NewString(currentDirectory),
+			},
+			sendMessage,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %v", currentDirectory, err.Error())
+		}
+		files, err := fields.GetList("files")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			fileFields, err := file.AsObject()
+			if err != nil {
+				return nil, err
+			}
+			name, err := fileFields.GetString("name")
+			if err != nil {
+				return nil, err
+			}
+			isDir, err := fileFields.GetBool("directory")
+			if err != nil {
+				return nil, err
+			}
+			size, err := fileFields.GetInt("size")
+			if err != nil {
+				return nil, err
+			}
+
+			// The checksum is missing for the files written before the checksums were stored
+			var checksum string
+			if fileFields.Has("checksum") {
+				if checksum, err = fileFields.GetString("checksum"); err != nil {
+					return nil, err
+				}
+			}
+
+			// Get the path relative to the synchronized directory
+			relativePath := name
+			if directory != "" {
+				relativePath = strings.TrimPrefix(name, strings.TrimSuffix(directory, "/")+"/")
+			}
+			entries[relativePath] = &SyncEntry{IsDir: isDir, Size: size, Checksum: checksum}
+			if isDir {
+				directories = append(directories, name)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// isInsideConflict checks if a relative path is inside one of the conflicting paths
+func isInsideConflict(relativePath string, conflicts []string) bool {
+	for _, conflict := range conflicts {
+		if strings.HasPrefix(relativePath, conflict+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanSync compares the local and the remote entries and returns the operations that make the remote directory match the local one. The remote extras are only deleted if deleteExtras is true. The directories are created before their files, and removed after them. The paths inside a conflict are left as they are on both sides
+func PlanSync(
+	localDirectory, remoteDirectory string,
+	localEntries, remoteEntries map[string]*SyncEntry,
+	deleteExtras bool,
+) []SyncOperation {
+	// Get the sorted paths, the parents are sorted before their children
+	localPaths := make([]string, 0, len(localEntries))
+	for relativePath := range localEntries {
+		localPaths = append(localPaths, relativePath)
+	}
+	sort.Strings(localPaths)
+	remotePaths := make([]string, 0, len(remoteEntries))
+	for relativePath := range remoteEntries {
+		remotePaths = append(remotePaths, relativePath)
+	}
+	sort.Strings(remotePaths)
+
+	// Get the operations of the local entries
+	var operations []SyncOperation
+	var conflicts []string
+	for _, relativePath := range localPaths {
+		if isInsideConflict(relativePath, conflicts) {
+			continue
+		}
+		localEntry := localEntries[relativePath]
+		remoteEntry, exists := remoteEntries[relativePath]
+		operation := SyncOperation{
+			LocalPath:  filepath.Join(localDirectory, filepath.FromSlash(relativePath)),
+			RemotePath: path.Join(remoteDirectory, relativePath),
+			Size:       localEntry.Size,
+		}
+		switch {
+		case exists && remoteEntry.IsDir != localEntry.IsDir:
+			operation.Action = SyncConflict
+			conflicts = append(conflicts, relativePath)
+		case localEntry.IsDir && !exists:
+			operation.Action = SyncMkdir
+		case localEntry.IsDir:
+			continue
+		case !exists:
+			operation.Action = SyncUpload
+		case remoteEntry.Checksum != localEntry.Checksum:
+			operation.Action = SyncUpdate
+		default:
+			continue
+		}
+		operations = append(operations, operation)
+	}
+
+	// Get the deletions of the remote extras, the children are removed before their parents
+	if !deleteExtras {
+		return operations
+	}
+	for i := len(remotePaths) - 1; i >= 0; i-- {
+		relativePath := remotePaths[i]
+		if _, exists := localEntries[relativePath]; exists || isInsideConflict(relativePath, conflicts) {
+			continue
+		}
+		operation := SyncOperation{
+			Action:     SyncDelete,
+			RemotePath: path.Join(remoteDirectory, relativePath),
+		}
+		if remoteEntries[relativePath].IsDir {
+			operation.Action = SyncRmdir
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// ApplySync applies the operations of a synchronization in order, the files are uploaded in chunks. The conflicts are skipped, and the first failed operation stops the synchronization
+func ApplySync(
+	protocol string,
+	encoding internalmessage.Encoding,
+	operations []SyncOperation,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+	operationFn func(operation *SyncOperation, response string),
+) error {
+	for i := range operations {
+		operation := &operations[i]
+
+		var response string
+		var err error
+		switch operation.Action {
+		case SyncMkdir:
+			response, err = SendMkdirMessage(protocol, encoding, operation.RemotePath, sendMessage)
+		case SyncUpload, SyncUpdate:
+			var content []byte
+			if content, err = os.ReadFile(operation.LocalPath); err == nil {
+				_, response, err = UploadFile(
+					protocol,
+					encoding,
+					operation.RemotePath,
+					content,
+					sendMessage,
+					nil,
+				)
+			}
+		case SyncDelete:
+			response, err = SendRemoveFileMessage(protocol, encoding, operation.RemotePath, sendMessage)
+		case SyncRmdir:
+			response, err = SendRmdirMessage(protocol, encoding, operation.RemotePath, sendMessage)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error applying %s: %v", operation.String(), err.Error())
+		}
+
+		// Decode the response, the errors of the server are strings
+		decodedResponse, err := DecodeResponse(encoding, response)
+		if err != nil {
+			return err
+		}
+		if operationFn != nil {
+			operationFn(operation, decodedResponse)
+		}
+	}
+	return nil
+}
+
+// SyncDirectory synchronizes a local directory to a remote directory, an empty remote directory is the root. On a dry run, the operations are only planned
+func SyncDirectory(
+	protocol string,
+	encoding internalmessage.Encoding,
+	localDirectory, remoteDirectory string,
+	deleteExtras, dryRun bool,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+	operationFn func(operation *SyncOperation, response string),
+) ([]SyncOperation, error) {
+	// List the local and the remote entries, the remote directory is created if it does not exist
+	localEntries, err := ListLocalEntries(localDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error listing local directory: %v", err.Error())
+	}
+	remoteEntries := make(map[string]*SyncEntry)
+	remoteExists := true
+	if remoteDirectory != "" {
+		if dryRun {
+			// Check if the remote directory exists, without creating it
+			_, err = SendTransferRequest(
+				protocol,
+				encoding,
+				internal.StatHeader,
+				internalmessage.Fields{
+					"filename": internalmessage.NewString(remoteDirectory),
+				},
+				sendMessage,
+			)
+			remoteExists = err == nil
+		} else if _, err = SendMkdirMessage(protocol, encoding, remoteDirectory, sendMessage); err != nil {
+			return nil, err
+		}
+	}
+	if remoteExists {
+		if remoteEntries, err = ListRemoteEntries(protocol, encoding, remoteDirectory, sendMessage); err != nil {
+			return nil, err
+		}
+	}
+
+	// Plan and apply the operations
+	operations := PlanSync(localDirectory, remoteDirectory, localEntries, remoteEntries, deleteExtras)
+	if dryRun {
+		return operations, nil
+	}
+	return operations, ApplySync(protocol, encoding, operations, sendMessage, operationFn)
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalserver "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/server"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	internaltransfer "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/transfer"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestServer handles the requests with the server handlers, on an in-memory file storage that is returned with the send message function
+func newTestServer(t *testing.T) (
+	internalstorage.Storage,
+	func(protocol string, message string) (string, error),
+) {
+	t.Helper()
+	fileStorage, uploads := internalloader.FileStorage, internalloader.Uploads
+	t.Cleanup(
+		func() {
+			internalloader.FileStorage, internalloader.Uploads = fileStorage, uploads
+		},
+	)
+	internalloader.FileStorage = internalstorage.NewMemoryStorage()
+	var err error
+	if internalloader.Uploads, err = internaltransfer.NewManager(t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return internalloader.FileStorage, func(protocol string, message string) (string, error) {
+		var response strings.Builder
+		internalserver.HandleIncomingData(
+			slog.Default(),
+			func(message string) { response.WriteString(message) },
+			nil,
+			nil,
+			&message,
+			nil,
+		)
+		return response.String(), nil
+	}
+}
+
+// writeLocalFiles writes the files of a local directory, the names ending with a slash are directories
+func writeLocalFiles(t *testing.T, directory string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(directory, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(filePath, 0755); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// writeRemoteFiles writes the files of a storage, the names ending with a slash are directories. They are written in order, so the directories are created before their files
+func writeRemoteFiles(t *testing.T, storage internalstorage.Storage, files map[string]string) {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := files[name]
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = storage.Mkdir(strings.TrimSuffix(name, "/"))
+		} else {
+			err = storage.WriteFile(name, []byte(content), nil)
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// getOperationLines returns the lines of the diff report of the operations
+func getOperationLines(operations []SyncOperation) []string {
+	lines := make([]string, len(operations))
+	for i := range operations {
+		lines[i] = operations[i].String()
+	}
+	return lines
+}
+
+// TestPlanSync tests the operations planned for the differences between the local and the remote entries
+func TestPlanSync(t *testing.T) {
+	file := func(checksum string) *SyncEntry {
+		return &SyncEntry{Size: int64(len(checksum)), Checksum: checksum}
+	}
+	directory := &SyncEntry{IsDir: true}
+
+	tests := []struct {
+		name          string
+		localEntries  map[string]*SyncEntry
+		remoteEntries map[string]*SyncEntry
+		deleteExtras  bool
+		expected      []string
+	}{
+		{
+			"equal",
+			map[string]*SyncEntry{"a": directory, "a/b.txt": file("1")},
+			map[string]*SyncEntry{"a": directory, "a/b.txt": file("1")},
+			true,
+			[]string{},
+		},
+		{
+			"new and changed files",
+			map[string]*SyncEntry{"a": directory, "a/b.txt": file("1"), "c.txt": file("22")},
+			map[string]*SyncEntry{"c.txt": file("33")},
+			false,
+			[]string{"mkdir    backup/a", "upload   backup/a/b.txt (1 bytes)", "update   backup/c.txt (2 bytes)"},
+		},
+		{
+			"missing remote checksum",
+			map[string]*SyncEntry{"c.txt": file("1")},
+			map[string]*SyncEntry{"c.txt": {Size: 1}},
+			false,
+			[]string{"update   backup/c.txt (1 bytes)"},
+		},
+		{
+			"extras kept",
+			map[string]*SyncEntry{},
+			map[string]*SyncEntry{"a": directory, "a/b.txt": file("1")},
+			false,
+			[]string{},
+		},
+		{
+			"extras deleted before their directories",
+			map[string]*SyncEntry{"a": directory},
+			map[string]*SyncEntry{"a": directory, "a/b": directory, "a/b/c.txt": file("1"), "d.txt": file("1")},
+			true,
+			[]string{"delete   backup/d.txt", "delete   backup/a/b/c.txt", "rmdir    backup/a/b"},
+		},
+		{
+			"local file and remote directory",
+			map[string]*SyncEntry{"a": file("1"), "b.txt": file("1")},
+			map[string]*SyncEntry{"a": directory, "a/c.txt": file("1")},
+			true,
+			[]string{"conflict backup/a", "upload   backup/b.txt (1 bytes)"},
+		},
+		{
+			"local directory and remote file",
+			map[string]*SyncEntry{"a": directory, "a/c.txt": file("1"), "a/d": directory},
+			map[string]*SyncEntry{"a": file("1")},
+			true,
+			[]string{"conflict backup/a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operations := PlanSync("local", "backup", test.localEntries, test.remoteEntries, test.deleteExtras)
+			if lines := getOperationLines(operations); !reflect.DeepEqual(lines, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, lines)
+			}
+		})
+	}
+}
+
+// TestListLocalEntries tests the entries of a local directory, without its special files
+func TestListLocalEntries(t *testing.T) {
+	directory := t.TempDir()
+	writeLocalFiles(t, directory, map[string]string{"a/": "", "a/b.txt": "hello", "c.txt": ""})
+	if err := os.Symlink(filepath.Join(directory, "c.txt"), filepath.Join(directory, "link")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := ListLocalEntries(directory)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]*SyncEntry{
+		"a":       {IsDir: true},
+		"a/b.txt": {Size: 5, Checksum: internalstorage.Checksum([]byte("hello"))},
+		"c.txt":   {Checksum: internalstorage.Checksum(nil)},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %d entries, got %d", len(expected), len(entries))
+		for relativePath, entry := range entries {
+			t.Errorf("got %s: %+v", relativePath, *entry)
+		}
+	}
+}
+
+// TestSyncDirectory tests the synchronization of a local directory to a remote one through the server handlers, with the remote extras deleted and a conflict left as it is
+func TestSyncDirectory(t *testing.T) {
+	fileStorage, sendMessage := newTestServer(t)
+	localDirectory := t.TempDir()
+	writeLocalFiles(
+		t, localDirectory, map[string]string{
+			"a/new.txt":   "new",
+			"same.txt":    "same",
+			"changed.txt": "changed",
+			"c":           "local file",
+		},
+	)
+	writeRemoteFiles(
+		t, fileStorage, map[string]string{
+			"backup/":             "",
+			"backup/same.txt":     "same",
+			"backup/changed.txt":  "old",
+			"backup/extra.txt":    "extra",
+			"backup/old/":         "",
+			"backup/old/file.txt": "old",
+			"backup/c/":           "",
+			"backup/c/inner.txt":  "inner",
+		},
+	)
+	expected := []string{
+		"mkdir    backup/a",
+		"upload   backup/a/new.txt (3 bytes)",
+		"conflict backup/c",
+		"update   backup/changed.txt (7 bytes)",
+		"delete   backup/old/file.txt",
+		"rmdir    backup/old",
+		"delete   backup/extra.txt",
+	}
+
+	// Check the dry run does not change the remote directory
+	operations, err := SyncDirectory("tcp", internalmessage.JSONEncoding, localDirectory, "backup", true, true, sendMessage, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := getOperationLines(operations); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+	if _, err = fileStorage.Stat("backup/extra.txt"); err != nil {
+		t.Errorf("expected the dry run to keep the extra file, got %v", err)
+	}
+
+	// Synchronize the directory
+	var applied []string
+	operations, err = SyncDirectory(
+		"tcp", internalmessage.JSONEncoding, localDirectory, "backup", true, false, sendMessage,
+		func(operation *SyncOperation, response string) {
+			applied = append(applied, operation.String())
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := getOperationLines(operations); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+	if len(applied) != len(expected)-1 {
+		t.Errorf("expected every operation but the conflict to be applied, got %q", applied)
+	}
+
+	// Check the remote files
+	for name, content := range map[string]string{
+		"backup/a/new.txt":   "new",
+		"backup/same.txt":    "same",
+		"backup/changed.txt": "changed",
+		"backup/c/inner.txt": "inner",
+	} {
+		if remoteContent, err := fileStorage.ReadFile(name); err != nil || string(remoteContent) != content {
+			t.Errorf("expected %q in %s, got %q, %v", content, name, remoteContent, err)
+		}
+	}
+	for _, name := range []string{"backup/extra.txt", "backup/old"} {
+		if _, err = fileStorage.Stat(name); err == nil {
+			t.Errorf("expected %s to be deleted", name)
+		}
+	}
+
+	// Check a second synchronization only has the conflict
+	operations, err = SyncDirectory("tcp", internalmessage.JSONEncoding, localDirectory, "backup", true, true, sendMessage, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := getOperationLines(operations); !reflect.DeepEqual(lines, []string{"conflict backup/c"}) {
+		t.Errorf("expected only the conflict, got %q", lines)
+	}
+}
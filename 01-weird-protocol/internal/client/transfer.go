@@ -0,0 +1,309 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+)
+
+const (
+	// TransferChunkSize is the size in bytes of the chunks of the uploads and downloads
+	TransferChunkSize = internal.MaxChunkSize
+
+	// MaxTransferRetries is the number of consecutive failed requests after which a transfer stops
+	MaxTransferRetries = 5
+)
+
+// SendTransferRequest sends a request of a transfer and parses its response, the responses that are not objects are the errors of the server
+func SendTransferRequest(
+	protocol string,
+	encoding internalmessage.Encoding,
+	header string,
+	body internalmessage.Fields,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (internalmessage.Fields, error) {
+	response, err := SendRequest(protocol, encoding, NewRequest(header, body), sendMessage)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := internalmessage.Parse(encoding, &response)
+	if err != nil {
+		decodedResponse, _ := DecodeResponse(encoding, response)
+		return nil, fmt.Errorf("server error: %v", decodedResponse)
+	}
+	return fields, nil
+}
+
+// BeginUpload begins an upload session of a content and returns its ID
+func BeginUpload(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	content []byte,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (string, error) {
+	fields, err := SendTransferRequest(
+		protocol,
+		encoding,
+		internal.UploadHeader,
+		internalmessage.Fields{
+			"action":   internalmessage.NewString(internal.UploadBegin),
+			"filename": internalmessage.NewString(filename),
+			"size":     internalmessage.NewInt(int64(len(content))),
+			"checksum": internalmessage.NewString(internalstorage.Checksum(content)),
+		},
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error beginning upload: %v", err.Error())
+	}
+	return fields.GetString("session")
+}
+
+// GetUploadOffset queries the committed offset of an upload session
+func GetUploadOffset(
+	protocol string,
+	encoding internalmessage.Encoding,
+	session string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (int64, error) {
+	fields, err := SendTransferRequest(
+		protocol,
+		encoding,
+		internal.UploadHeader,
+		internalmessage.Fields{
+			"action":  internalmessage.NewString(internal.UploadStatus),
+			"session": internalmessage.NewString(session),
+		},
+		sendMessage,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error querying upload offset: %v", err.Error())
+	}
+	return fields.GetInt("offset")
+}
+
+// ResumeUpload sends the chunks of a content from the committed offset of an upload session and commits it. A failed chunk is retried from the offset queried to the server, until the retries are exhausted
+func ResumeUpload(
+	protocol string,
+	encoding internalmessage.Encoding,
+	session string,
+	content []byte,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+	progressFn func(offset, size int64),
+) (response string, err error) {
+	size := int64(len(content))
+	offset, err := GetUploadOffset(protocol, encoding, session, sendMessage)
+	if err != nil {
+		return "", err
+	}
+
+	// Send the chunks
+	retries := 0
+	for offset < size {
+		chunk := content[offset:min(offset+TransferChunkSize, size)]
+		fields, err := SendTransferRequest(
+			protocol,
+			encoding,
+			internal.UploadHeader,
+			internalmessage.Fields{
+				"action":   internalmessage.NewString(internal.UploadChunk),
+				"session":  internalmessage.NewString(session),
+				"offset":   internalmessage.NewInt(offset),
+				"data":     internalmessage.NewString(base64.StdEncoding.EncodeToString(chunk)),
+				"checksum": internalmessage.NewString(internalstorage.Checksum(chunk)),
+			},
+			sendMessage,
+		)
+		if err == nil {
+			offset, err = fields.GetInt("offset")
+		}
+		if err != nil {
+			// Query the committed offset to retry
+			retries++
+			if retries > MaxTransferRetries {
+				return "", fmt.Errorf("error sending chunk at offset %d: %v", offset, err.Error())
+			}
+			if queriedOffset, err := GetUploadOffset(protocol, encoding, session, sendMessage); err == nil {
+				offset = queriedOffset
+			}
+			continue
+		}
+		retries = 0
+		if progressFn != nil {
+			progressFn(offset, size)
+		}
+	}
+
+	// Commit the upload
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.UploadHeader,
+			internalmessage.Fields{
+				"action":  internalmessage.NewString(internal.UploadCommit),
+				"session": internalmessage.NewString(session),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error committing upload: %v", err.Error())
+	}
+	return response, nil
+}
+
+// UploadFile uploads a content in chunks with a new upload session. The session is returned with the error, so a failed upload can be resumed
+func UploadFile(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	content []byte,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+	progressFn func(offset, size int64),
+) (session, response string, err error) {
+	session, err = BeginUpload(protocol, encoding, filename, content, sendMessage)
+	if err != nil {
+		return "", "", err
+	}
+	response, err = ResumeUpload(protocol, encoding, session, content, sendMessage, progressFn)
+	return session, response, err
+}
+
+// AbortUpload aborts an upload session
+func AbortUpload(
+	protocol string,
+	encoding internalmessage.Encoding,
+	session string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+) (response string, err error) {
+	response, err = SendRequest(
+		protocol,
+		encoding,
+		NewRequest(
+			internal.UploadHeader,
+			internalmessage.Fields{
+				"action":  internalmessage.NewString(internal.UploadAbort),
+				"session": internalmessage.NewString(session),
+			},
+		),
+		sendMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error aborting upload: %v", err.Error())
+	}
+	return response, nil
+}
+
+// DownloadFile downloads a file in chunks and verifies its checksum, or its size if it has no stored checksum. A failed chunk is retried from its offset, until the retries are exhausted
+func DownloadFile(
+	protocol string,
+	encoding internalmessage.Encoding,
+	filename string,
+	sendMessage func(protocol string, message string) (
+		response string,
+		err error,
+	),
+	progressFn func(offset, size int64),
+) ([]byte, error) {
+	var content []byte
+	var checksum string
+	var size int64
+	retries := 0
+	for {
+		fields, err := SendTransferRequest(
+			protocol,
+			encoding,
+			internal.DownloadHeader,
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+				"offset":   internalmessage.NewInt(int64(len(content))),
+				"length":   internalmessage.NewInt(TransferChunkSize),
+			},
+			sendMessage,
+		)
+		var chunk []byte
+		var eof bool
+		if err == nil {
+			chunk, size, eof, err = readDownloadChunk(fields, &checksum)
+		}
+		if err != nil {
+			retries++
+			if retries > MaxTransferRetries {
+				return nil, fmt.Errorf("error downloading chunk at offset %d: %v", len(content), err.Error())
+			}
+			continue
+		}
+		retries = 0
+		content = append(content, chunk...)
+		if progressFn != nil {
+			progressFn(int64(len(content)), size)
+		}
+		if eof {
+			break
+		}
+	}
+
+	// Verify the file, the files without a stored checksum are only verified by their size
+	if checksum == "" {
+		if int64(len(content)) != size {
+			return nil, fmt.Errorf("error verifying download: received %d of %d bytes", len(content), size)
+		}
+		return content, nil
+	}
+	if err := internalstorage.VerifyChecksum(content, checksum); err != nil {
+		return nil, fmt.Errorf("error verifying download: %v", err.Error())
+	}
+	return content, nil
+}
+
+// readDownloadChunk reads the data, the file size and the end of file flag of a download response. The checksum of the file is kept from the first chunk, a different one means the file changed during the download
+func readDownloadChunk(fields internalmessage.Fields, checksum *string) (
+	chunk []byte,
+	size int64,
+	eof bool,
+	err error,
+) {
+	fileChecksum, err := fields.GetString("checksum")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if *checksum == "" {
+		*checksum = fileChecksum
+	} else if *checksum != fileChecksum {
+		return nil, 0, false, fmt.Errorf("the file changed during the download")
+	}
+	if size, err = fields.GetInt("size"); err != nil {
+		return nil, 0, false, err
+	}
+	if eof, err = fields.GetBool("eof"); err != nil {
+		return nil, 0, false, err
+	}
+	data, err := fields.GetString("data")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	chunk, err = base64.StdEncoding.DecodeString(data)
+	return chunk, size, eof, err
+}
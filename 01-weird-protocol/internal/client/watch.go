@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"net"
+)
+
+// Watcher is a watch of the changes of the files on a persistent TCP connection, the server pushes the events as frames
+type Watcher struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	encoding internalmessage.Encoding
+}
+
+// StartFramedSession sends a request that opens a framed session on a persistent TCP connection, and reads the confirmation of the server, which must have the given field. The writing side is kept open since closing it ends the session
+func StartFramedSession(
+	address *net.TCPAddr,
+	encoding internalmessage.Encoding,
+	header string,
+	body internalmessage.Fields,
+	confirmationField string,
+) (net.Conn, *bufio.Reader, internalmessage.Fields, error) {
+	// Encode the request
+	message, err := internalmessage.Encode(encoding, NewRequest(header, body))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error encoding request: %v", err.Error())
+	}
+
+	// Connect to the TCP server
+	conn, err := DialTCP(address)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error connecting to TCP server: %v", err.Error())
+	}
+	reader := bufio.NewReader(conn)
+
+	// Send the request, framed with its length
+	if _, err = conn.Write([]byte(internalmessage.EncodeFrame(message))); err != nil {
+		_ = conn.Close()
+		return nil, nil, nil, fmt.Errorf("error sending message: %v", err.Error())
+	}
+
+	// Check if the response is framed, the errors before the session starts are not
+	firstByte, err := reader.Peek(1)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, nil, fmt.Errorf("error reading response: %v", err.Error())
+	}
+	if firstByte[0] != 0 {
+		response, _ := io.ReadAll(reader)
+		_ = conn.Close()
+		decodedResponse, _ := DecodeResponse(encoding, string(response))
+		return nil, nil, nil, fmt.Errorf("error starting %s: %v", header, decodedResponse)
+	}
+
+	// Read the confirmation of the server
+	response, err := internalmessage.ReadFrame(reader)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, nil, fmt.Errorf("error reading response: %v", err.Error())
+	}
+	fields, err := internalmessage.Parse(encoding, &response)
+	if err != nil || !fields.Has(confirmationField) {
+		_ = conn.Close()
+		decodedResponse, _ := DecodeResponse(encoding, response)
+		return nil, nil, nil, fmt.Errorf("error starting %s: %v", header, decodedResponse)
+	}
+	return conn, reader, fields, nil
+}
+
+// ReceiveFrames reads the frames of a framed session until the connection is closed, they are decoded before being passed to the frame function
+func ReceiveFrames(
+	reader io.Reader,
+	encoding internalmessage.Encoding,
+	frameFn func(frame string),
+) error {
+	for {
+		frame, err := internalmessage.ReadFrame(reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading frame: %v", err.Error())
+		}
+		decodedFrame, err := DecodeResponse(encoding, frame)
+		if err != nil {
+			return err
+		}
+		frameFn(decodedFrame)
+	}
+}
+
+// StartWatch starts a watch of the changes of the files, an empty directory watches all of them. The server confirms it before pushing the events
+func StartWatch(
+	address *net.TCPAddr,
+	encoding internalmessage.Encoding,
+	directory string,
+) (*Watcher, error) {
+	conn, reader, _, err := StartFramedSession(
+		address,
+		encoding,
+		internal.WatchHeader,
+		internalmessage.Fields{
+			"directory": internalmessage.NewString(directory),
+		},
+		"watch",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{conn: conn, reader: reader, encoding: encoding}, nil
+}
+
+// Receive reads the pushed events until the connection is closed, they are decoded before being passed to the event function
+func (w *Watcher) Receive(eventFn func(event string)) error {
+	return ReceiveFrames(w.reader, w.encoding, eventFn)
+}
+
+// Close stops the watch and closes the connection
+func (w *Watcher) Close() error {
+	return w.conn.Close()
+}
@@ -10,18 +10,163 @@ const (
 	// MorseToMorse is the morse body 'to' field for the morse code
 	MorseToMorse = "morse"
 
+	// MorseToAudio is the morse body 'to' field for the morse code rendered as a WAV file
+	MorseToAudio = "audio"
+
+	// MorseFromAudio is the morse body 'from' field for the morse code of a WAV file, decoded to text
+	MorseFromAudio = "audio"
+
+	// MorseStreamStart is the morse body 'stream' field to start a streaming conversion on a persistent TCP connection
+	MorseStreamStart = "start"
+
+	// MorseStreamStop is the message that stops a streaming conversion, the end of transmission control character
+	MorseStreamStop = "\x04"
+
 	// AddFileHeader is the header for adding a file
 	AddFileHeader = "addfile"
 
 	// RemoveFileHeader is the header for removing a file
 	RemoveFileHeader = "removefile"
 
+	// GetFileHeader is the header for getting the content of a file
+	GetFileHeader = "getfile"
+
+	// ListFilesHeader is the header for listing the files of a directory
+	ListFilesHeader = "listfiles"
+
+	// StatHeader is the header for getting the information of a file or directory
+	StatHeader = "stat"
+
+	// VerifyHeader is the header for verifying the content of the files with their stored checksums
+	VerifyHeader = "verify"
+
+	// UploadHeader is the header for the actions of the resumable upload sessions
+	UploadHeader = "upload"
+
+	// DownloadHeader is the header for downloading a chunk of a file
+	DownloadHeader = "download"
+
+	// WatchHeader is the header for watching the changes of the files on a persistent TCP connection
+	WatchHeader = "watch"
+
+	// ChatHeader is the header for opening a chat session on a persistent TCP connection, the chat headers are sent inside it
+	ChatHeader = "chat"
+
+	// BroadcastHeader is the header for sending a chat message to all the sessions, or to the sessions of a room
+	BroadcastHeader = "broadcast"
+
+	// PrivateHeader is the header for sending a chat message to a session
+	PrivateHeader = "private"
+
+	// EchoHeader is the header for sending a message back to its sender
+	EchoHeader = "echo"
+
+	// JoinHeader is the header for joining a chat room
+	JoinHeader = "join"
+
+	// LeaveHeader is the header for leaving a chat room
+	LeaveHeader = "leave"
+
+	// MkdirHeader is the header for creating a directory
+	MkdirHeader = "mkdir"
+
+	// RmdirHeader is the header for removing an empty directory
+	RmdirHeader = "rmdir"
+
+	// RenameHeader is the header for renaming a file or directory
+	RenameHeader = "rename"
+
+	// MoveHeader is the header for moving a file or directory into a directory
+	MoveHeader = "move"
+
+	// QuotaHeader is the header for the usage and the limits of the file storage
+	QuotaHeader = "quota"
+
 	// MailHeader is the header for the mail
 	MailHeader = "mail"
+
+	// HelloHeader is the header for the version negotiation
+	HelloHeader = "hello"
+
+	// CapabilitiesHeader is the header for the server capabilities
+	CapabilitiesHeader = "capabilities"
 )
 
+// Headers are the headers supported by the server
+var Headers = []string{
+	MorseHeader,
+	AddFileHeader,
+	RemoveFileHeader,
+	GetFileHeader,
+	ListFilesHeader,
+	StatHeader,
+	VerifyHeader,
+	UploadHeader,
+	DownloadHeader,
+	WatchHeader,
+	ChatHeader,
+	BroadcastHeader,
+	PrivateHeader,
+	EchoHeader,
+	JoinHeader,
+	LeaveHeader,
+	MkdirHeader,
+	RmdirHeader,
+	RenameHeader,
+	MoveHeader,
+	QuotaHeader,
+	MailHeader,
+	HelloHeader,
+	CapabilitiesHeader,
+}
+
+const (
+	// LegacyProtocolVersion is the version of the requests without a version field
+	LegacyProtocolVersion = 1
+
+	// ProtocolVersion is the current version of the protocol, it added typed values, lists and the JSON and CBOR encodings
+	ProtocolVersion = 2
+)
+
+// ProtocolVersions are the versions supported by the server
+var ProtocolVersions = []int64{LegacyProtocolVersion, ProtocolVersion}
+
+const (
+	// UploadBegin is the upload body 'action' field to begin a session
+	UploadBegin = "begin"
+
+	// UploadChunk is the upload body 'action' field to send a chunk at an offset
+	UploadChunk = "chunk"
+
+	// UploadStatus is the upload body 'action' field to query the committed offset, to resume a session
+	UploadStatus = "status"
+
+	// UploadCommit is the upload body 'action' field to write the file of a session
+	UploadCommit = "commit"
+
+	// UploadAbort is the upload body 'action' field to abort a session
+	UploadAbort = "abort"
+
+	// MaxChunkSize is the maximum size in bytes of the chunks of the uploads and downloads, so they fit in a UDP datagram once encoded in base64
+	MaxChunkSize = 32 * 1024
+)
+
+// FileContentBase64 is the file 'encoding' field for the content of a binary file, encoded in base64
+const FileContentBase64 = "base64"
+
 // Ports
 const (
-	TCPPort = 8080
-	UDPPort = 8081
+	TCPPort       = 8080
+	UDPPort       = 8081
+	WebSocketPort = 8082
+	QUICPort      = 8084
 )
+
+// QUICProtocol is the application protocol negotiated by the QUIC connections
+const QUICProtocol = "weird-protocol"
+
+// WebSocketPath is the path of the HTTP endpoint that is upgraded to a WebSocket connection
+const WebSocketPath = "/ws"
+
+// MaxDatagramSize is the maximum size of the payload of a UDP datagram
+const MaxDatagramSize = 65507
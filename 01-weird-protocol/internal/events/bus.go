@@ -0,0 +1,124 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// SubscriptionBufferSize is the number of events a subscription can hold before the new ones are dropped
+const SubscriptionBufferSize = 64
+
+type (
+	// Event is an event published to the bus
+	Event struct {
+		// Topic is the topic of the event
+		Topic string
+
+		// Type is the type of the event
+		Type string
+
+		// Actor is the one that caused the event
+		Actor string
+
+		// Time is the time of the event
+		Time time.Time
+
+		// Data is the data of the event, it depends on its topic
+		Data map[string]any
+	}
+
+	// Subscription is a subscription to a topic of the bus
+	Subscription struct {
+		bus     *Bus
+		topic   string
+		events  chan *Event
+		mutex   sync.Mutex
+		dropped int64
+	}
+
+	// Bus is an in-process publish/subscribe event bus, the events are delivered to the subscriptions of their topic without blocking the publisher
+	Bus struct {
+		mutex         sync.RWMutex
+		subscriptions map[string]map[*Subscription]struct{}
+	}
+)
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe subscribes to a topic
+func (b *Bus) Subscribe(topic string) *Subscription {
+	subscription := &Subscription{
+		bus:    b,
+		topic:  topic,
+		events: make(chan *Event, SubscriptionBufferSize),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subscriptions[topic]; !ok {
+		b.subscriptions[topic] = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[topic][subscription] = struct{}{}
+	return subscription
+}
+
+// Publish publishes an event to the subscriptions of its topic. The event is dropped for the subscriptions whose buffer is full
+func (b *Bus) Publish(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for subscription := range b.subscriptions[event.Topic] {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.mutex.Lock()
+			subscription.dropped++
+			subscription.mutex.Unlock()
+		}
+	}
+}
+
+// Subscribers returns the number of subscriptions of a topic
+func (b *Bus) Subscribers(topic string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.subscriptions[topic])
+}
+
+// Events returns the channel of the events of the subscription, it is closed when the subscription is cancelled
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Dropped returns and resets the number of events dropped since the last call because the buffer was full
+func (s *Subscription) Dropped() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// Cancel cancels the subscription and closes its channel
+func (s *Subscription) Cancel() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+	subscriptions, ok := s.bus.subscriptions[s.topic]
+	if !ok {
+		return
+	}
+	if _, ok = subscriptions[s]; !ok {
+		return
+	}
+	delete(subscriptions, s)
+	if len(subscriptions) == 0 {
+		delete(s.bus.subscriptions, s.topic)
+	}
+	close(s.events)
+}
@@ -1,11 +1,25 @@
 package loader
 
 import (
+	"crypto/tls"
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/mailersend/mailersend-go"
 	goloaderenv "github.com/ralvarezdev/go-loader/env"
-	gomorse "github.com/ralvarezdev/go-morse"
-	gomorseinternational "github.com/ralvarezdev/go-morse/international"
+	internalcertificate "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/certificate"
+	internalchat "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/chat"
+	internalevents "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/events"
+	internalmorse "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/morse"
+	internalserial "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/serial"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	internaltransfer "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/transfer"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -17,6 +31,123 @@ const (
 
 	// MailerSendName is the name of the mailer send service
 	MailerSendName string = "Weird Protocol"
+
+	// MorseAlphabetsFolder is the folder of the custom morse code alphabets
+	MorseAlphabetsFolder = "alphabets"
+
+	// EnvFilesRoot is the key for the root directory of the files in the environment variables
+	EnvFilesRoot = "FILES_ROOT"
+
+	// DefaultFilesRoot is the default root directory of the files
+	DefaultFilesRoot = "files"
+
+	// EnvStorageBackend is the key for the storage backend of the files in the environment variables, the local one by default
+	EnvStorageBackend = "STORAGE_BACKEND"
+
+	// EnvS3Endpoint is the key for the endpoint of the S3-compatible object store in the environment variables
+	EnvS3Endpoint = "S3_ENDPOINT"
+
+	// EnvS3AccessKey is the key for the access key of the S3-compatible object store in the environment variables
+	EnvS3AccessKey = "S3_ACCESS_KEY"
+
+	// EnvS3SecretKey is the key for the secret key of the S3-compatible object store in the environment variables
+	EnvS3SecretKey = "S3_SECRET_KEY"
+
+	// EnvS3Bucket is the key for the bucket of the S3-compatible object store in the environment variables
+	EnvS3Bucket = "S3_BUCKET"
+
+	// EnvS3Region is the key for the optional region of the S3-compatible object store in the environment variables
+	EnvS3Region = "S3_REGION"
+
+	// EnvS3UseSSL is the key for the optional HTTPS flag of the S3-compatible object store in the environment variables
+	EnvS3UseSSL = "S3_USE_SSL"
+
+	// EnvUsers is the key for the optional users in the environment variables, as comma-separated name:token pairs
+	EnvUsers = "USERS"
+
+	// EnvQuotaMaxFileSize is the key for the optional maximum size in bytes of a shared file in the environment variables
+	EnvQuotaMaxFileSize = "QUOTA_MAX_FILE_SIZE"
+
+	// EnvQuotaMaxBytes is the key for the optional maximum size in bytes of the shared files in the environment variables
+	EnvQuotaMaxBytes = "QUOTA_MAX_BYTES"
+
+	// EnvQuotaMaxFiles is the key for the optional maximum number of shared files in the environment variables
+	EnvQuotaMaxFiles = "QUOTA_MAX_FILES"
+
+	// EnvUserQuotaMaxFileSize is the key for the optional maximum size in bytes of a file of each user in the environment variables
+	EnvUserQuotaMaxFileSize = "USER_QUOTA_MAX_FILE_SIZE"
+
+	// EnvUserQuotaMaxBytes is the key for the optional maximum size in bytes of the files of each user in the environment variables
+	EnvUserQuotaMaxBytes = "USER_QUOTA_MAX_BYTES"
+
+	// EnvUserQuotaMaxFiles is the key for the optional maximum number of files of each user in the environment variables
+	EnvUserQuotaMaxFiles = "USER_QUOTA_MAX_FILES"
+
+	// EnvUploadsRoot is the key for the optional directory of the temporary files of the upload sessions in the environment variables
+	EnvUploadsRoot = "UPLOADS_ROOT"
+
+	// DefaultUploadsFolder is the folder of the temporary directory of the system with the temporary files of the upload sessions
+	DefaultUploadsFolder = "weird-protocol-uploads"
+
+	// EnvWebSocketOrigins is the key for the optional origins allowed to open a WebSocket connection in the environment variables, as a comma-separated list. An asterisk allows any origin, and only the same origin is allowed by default
+	EnvWebSocketOrigins = "WEBSOCKET_ORIGINS"
+
+	// EnvHTTPGatewayAddress is the key for the optional address of the HTTP gateway in the environment variables, like :8083. The gateway is disabled if it is missing
+	EnvHTTPGatewayAddress = "HTTP_GATEWAY_ADDRESS"
+
+	// EnvSerialDevice is the key for the optional TTY device of the serial transport in the environment variables, the transport is disabled if it is missing
+	EnvSerialDevice = "SERIAL_DEVICE"
+
+	// EnvSerialBaudRate is the key for the optional baud rate of the serial transport in the environment variables
+	EnvSerialBaudRate = "SERIAL_BAUD_RATE"
+
+	// EnvSerialParity is the key for the optional parity of the serial transport in the environment variables, none by default
+	EnvSerialParity = "SERIAL_PARITY"
+
+	// EnvSerialStopBits is the key for the optional stop bits of the serial transport in the environment variables, one by default
+	EnvSerialStopBits = "SERIAL_STOP_BITS"
+
+	// EnvUnixSocketPath is the key for the optional path of the Unix domain stream socket in the environment variables, the socket is disabled if it is missing
+	EnvUnixSocketPath = "UNIX_SOCKET_PATH"
+
+	// EnvUnixDatagramSocketPath is the key for the optional path of the Unix domain datagram socket in the environment variables, the socket is disabled if it is missing
+	EnvUnixDatagramSocketPath = "UNIX_DATAGRAM_SOCKET_PATH"
+
+	// EnvUnixSocketMode is the key for the optional octal permissions of the Unix domain socket files in the environment variables
+	EnvUnixSocketMode = "UNIX_SOCKET_MODE"
+
+	// EnvUnixSocketAuth is the key for the optional flag in the environment variables that authenticates the peers of the Unix domain sockets as the user with the name of their system user
+	EnvUnixSocketAuth = "UNIX_SOCKET_AUTH"
+
+	// EnvTLSCertFile is the key for the optional certificate file of the TCP and QUIC listeners in the environment variables. The TCP listener uses TLS if it is set, and the QUIC listener uses a self-signed certificate if it is missing
+	EnvTLSCertFile = "TLS_CERT_FILE"
+
+	// EnvTLSKeyFile is the key for the optional private key file of the certificate of the TCP and QUIC listeners in the environment variables
+	EnvTLSKeyFile = "TLS_KEY_FILE"
+
+	// EnvTLSSelfSignedCertFile is the key for the optional file in the environment variables where the self-signed certificate of the QUIC listener is written, the clients trust it by using the file as their CA file
+	EnvTLSSelfSignedCertFile = "TLS_SELF_SIGNED_CERT_FILE"
+
+	// DefaultUnixSocketMode is the default permissions of the Unix domain socket files, only the owner and its group can connect
+	DefaultUnixSocketMode = 0o660
+
+	// EnvLogLevel is the key for the optional level of the logs in the environment variables: debug, info, warn or error. It is info by default
+	EnvLogLevel = "LOG_LEVEL"
+
+	// EnvLogFormat is the key for the optional format of the logs in the environment variables: text or json. It is text by default
+	EnvLogFormat = "LOG_FORMAT"
+
+	// EnvLogFile is the key for the optional file the logs are appended to in the environment variables, they are written to the standard error by default
+	EnvLogFile = "LOG_FILE"
+
+	// TextLogFormat is the format of the logs as key=value pairs
+	TextLogFormat = "text"
+
+	// JSONLogFormat is the format of the logs as JSON objects, one per line
+	JSONLogFormat = "json"
+
+	// UsersDirectory is the directory of the file storage with the home directories of the users
+	UsersDirectory = "users"
 )
 
 var (
@@ -35,8 +166,74 @@ var (
 	// MailerSendClient is the client for the mailer send service
 	MailerSendClient *mailersend.Mailersend
 
-	// MorseCodeHandler is the handler for the morse code service
-	MorseCodeHandler *gomorse.MorseCodeHandler
+	// MorseConverters are the morse code converters by alphabet name
+	MorseConverters map[string]*internalmorse.Converter
+
+	// FilesRoot is the root directory of the files
+	FilesRoot string
+
+	// StorageBackend is the storage backend of the files
+	StorageBackend string
+
+	// FileStorage is the storage of the shared files of the anonymous requests, the home directories of the users are hidden from it
+	FileStorage internalstorage.Storage
+
+	// FileQuota is the quota of the shared files, outside the home directories of the users
+	FileQuota *internalstorage.Quota
+
+	// UserFileQuota is the quota of the home directory of each user
+	UserFileQuota *internalstorage.Quota
+
+	// Users are the names of the users by their token
+	Users map[string]string
+
+	// UserFileStorages are the storages of the home directories of the users by their name
+	UserFileStorages map[string]internalstorage.Storage
+
+	// UploadsRoot is the directory of the temporary files of the upload sessions
+	UploadsRoot string
+
+	// Uploads is the manager of the upload sessions
+	Uploads *internaltransfer.Manager
+
+	// WebSocketOrigins are the origins allowed to open a WebSocket connection, only the same origin is allowed if it is empty
+	WebSocketOrigins []string
+
+	// HTTPGatewayAddress is the address of the HTTP gateway, it is disabled if it is empty
+	HTTPGatewayAddress string
+
+	// SerialConfig is the configuration of the serial transport, it is disabled if it is nil
+	SerialConfig *internalserial.Config
+
+	// UnixSocketPath is the path of the Unix domain stream socket, it is disabled if it is empty
+	UnixSocketPath string
+
+	// UnixDatagramSocketPath is the path of the Unix domain datagram socket, it is disabled if it is empty
+	UnixDatagramSocketPath string
+
+	// UnixSocketMode is the permissions of the Unix domain socket files
+	UnixSocketMode os.FileMode = DefaultUnixSocketMode
+
+	// UnixSocketAuth is true if the peers of the Unix domain sockets are authenticated by their credentials
+	UnixSocketAuth bool
+
+	// TLSConfig is the TLS configuration of the TCP and QUIC listeners
+	TLSConfig *tls.Config
+
+	// TCPTLS is true if the TCP listener uses TLS, when a certificate is configured. The QUIC listener always uses it
+	TCPTLS bool
+
+	// LogLevel is the level of the logs
+	LogLevel slog.Level
+
+	// LogFormat is the format of the logs
+	LogFormat = TextLogFormat
+
+	// Events is the event bus of the server
+	Events = internalevents.NewBus()
+
+	// Chat is the hub of the chat sessions, its messages are routed through the event bus
+	Chat = internalchat.NewHub(Events)
 )
 
 // Load loads the loader
@@ -50,6 +247,13 @@ func Load() {
 	)
 	Loader = loader
 
+	// Load the logger, it is the default one of the slog package
+	logger, err := LoadLogger()
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(logger)
+
 	// Load the environment variables
 	for env, dest := range map[string]*string{
 		EnvMailerSendAPIKey: &MailerSendAPIKey,
@@ -69,10 +273,294 @@ func Load() {
 	// Set the email for the mailer send service
 	MailerSendEmail = "noreply@" + MailerSendDomain
 
-	// Create the Morse code handler
-	morseCodeHandler, err := gomorseinternational.NewMorseCodeHandler()
+	// Create the Morse code converters of the international and custom alphabets
+	morseConverters, err := internalmorse.LoadAlphabetsFolder(
+		MorseAlphabetsFolder,
+	)
+	if err != nil {
+		panic(err)
+	}
+	MorseConverters = morseConverters
+
+	// Create the file storage
+	fileStorage, err := LoadFileStorage()
+	if err != nil {
+		panic(err)
+	}
+
+	// Load the quotas of the shared files and of the home directories
+	if FileQuota, err = LoadQuota(
+		EnvQuotaMaxFileSize,
+		EnvQuotaMaxBytes,
+		EnvQuotaMaxFiles,
+	); err != nil {
+		panic(err)
+	}
+	if UserFileQuota, err = LoadQuota(
+		EnvUserQuotaMaxFileSize,
+		EnvUserQuotaMaxBytes,
+		EnvUserQuotaMaxFiles,
+	); err != nil {
+		panic(err)
+	}
+
+	// Hide the home directories from the shared files, so each quota has its own files
+	sharedStorage, err := internalstorage.NewExcludeStorage(
+		fileStorage,
+		UsersDirectory,
+	)
 	if err != nil {
 		panic(err)
 	}
-	MorseCodeHandler = morseCodeHandler
+	if FileQuota.IsUnlimited() {
+		FileStorage = sharedStorage
+	} else {
+		FileStorage = internalstorage.NewQuotaStorage(sharedStorage, FileQuota)
+	}
+
+	// Load the users and create their home directories
+	if Users, err = LoadUsers(); err != nil {
+		panic(err)
+	}
+	UserFileStorages = make(map[string]internalstorage.Storage)
+	for _, name := range Users {
+		homeStorage, err := internalstorage.NewSubStorage(
+			fileStorage,
+			path.Join(UsersDirectory, name),
+		)
+		if err != nil {
+			panic(err)
+		}
+		UserFileStorages[name] = internalstorage.NewQuotaStorage(
+			homeStorage,
+			UserFileQuota,
+		)
+	}
+
+	// Create the upload sessions manager, its root is optional
+	if err = Loader.LoadVariable(EnvUploadsRoot, &UploadsRoot); err != nil {
+		UploadsRoot = filepath.Join(os.TempDir(), DefaultUploadsFolder)
+	}
+	if Uploads, err = internaltransfer.NewManager(UploadsRoot); err != nil {
+		panic(err)
+	}
+
+	// Load the address of the HTTP gateway, it is optional
+	_ = Loader.LoadVariable(EnvHTTPGatewayAddress, &HTTPGatewayAddress)
+
+	// Load the configuration of the serial transport, it is optional
+	if SerialConfig, err = LoadSerialConfig(); err != nil {
+		panic(err)
+	}
+
+	// Load the Unix domain sockets, they are optional
+	_ = Loader.LoadVariable(EnvUnixSocketPath, &UnixSocketPath)
+	_ = Loader.LoadVariable(EnvUnixDatagramSocketPath, &UnixDatagramSocketPath)
+	var unixSocketMode string
+	if err = Loader.LoadVariable(EnvUnixSocketMode, &unixSocketMode); err == nil {
+		mode, err := strconv.ParseUint(unixSocketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			panic(fmt.Errorf("invalid %s: %s", EnvUnixSocketMode, unixSocketMode))
+		}
+		UnixSocketMode = os.FileMode(mode)
+	}
+	var unixSocketAuth string
+	if err = Loader.LoadVariable(EnvUnixSocketAuth, &unixSocketAuth); err == nil {
+		UnixSocketAuth = unixSocketAuth == "true"
+	}
+
+	// Load the TLS configuration, the certificate is optional
+	var tlsCertFile, tlsKeyFile string
+	_ = Loader.LoadVariable(EnvTLSCertFile, &tlsCertFile)
+	_ = Loader.LoadVariable(EnvTLSKeyFile, &tlsKeyFile)
+	if TLSConfig, err = internalcertificate.NewServerConfig(tlsCertFile, tlsKeyFile); err != nil {
+		panic(err)
+	}
+	TCPTLS = tlsCertFile != ""
+
+	// Write the self-signed certificate, so the clients can verify it
+	var tlsSelfSignedCertFile string
+	if err = Loader.LoadVariable(EnvTLSSelfSignedCertFile, &tlsSelfSignedCertFile); err == nil && tlsCertFile == "" {
+		if err = internalcertificate.WriteCertificateFile(&TLSConfig.Certificates[0], tlsSelfSignedCertFile); err != nil {
+			panic(err)
+		}
+	}
+
+	// Load the allowed WebSocket origins, they are optional
+	var webSocketOrigins string
+	if err = Loader.LoadVariable(EnvWebSocketOrigins, &webSocketOrigins); err == nil {
+		for _, origin := range strings.Split(webSocketOrigins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				WebSocketOrigins = append(WebSocketOrigins, origin)
+			}
+		}
+	}
+}
+
+// LoadLogger loads the logger of the operator logs, with the optional level, format and file
+func LoadLogger() (*slog.Logger, error) {
+	// Get the level
+	var value string
+	if err := Loader.LoadVariable(EnvLogLevel, &value); err == nil {
+		if err = LogLevel.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvLogLevel, value)
+		}
+	}
+	options := &slog.HandlerOptions{Level: LogLevel}
+
+	// Get the output
+	var output io.Writer = os.Stderr
+	var logFile string
+	if err := Loader.LoadVariable(EnvLogFile, &logFile); err == nil {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		output = file
+	}
+
+	// Get the handler of the format
+	_ = Loader.LoadVariable(EnvLogFormat, &LogFormat)
+	switch LogFormat {
+	case TextLogFormat:
+		return slog.New(slog.NewTextHandler(output, options)), nil
+	case JSONLogFormat:
+		return slog.New(slog.NewJSONHandler(output, options)), nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown log format %s, expected: %s, %s",
+			LogFormat,
+			TextLogFormat,
+			JSONLogFormat,
+		)
+	}
+}
+
+// LoadQuota loads the optional limits of a quota, the missing limits are unlimited
+func LoadQuota(maxFileSizeEnv, maxBytesEnv, maxFilesEnv string) (
+	*internalstorage.Quota,
+	error,
+) {
+	quota := &internalstorage.Quota{}
+	for env, dest := range map[string]*int64{
+		maxFileSizeEnv: &quota.MaxFileSize,
+		maxBytesEnv:    &quota.MaxBytes,
+		maxFilesEnv:    &quota.MaxFiles,
+	} {
+		var value string
+		if err := Loader.LoadVariable(env, &value); err != nil {
+			continue
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", env, value)
+		}
+		*dest = limit
+	}
+	return quota, nil
+}
+
+// LoadUsers loads the optional users, as comma-separated name:token pairs
+func LoadUsers() (map[string]string, error) {
+	users := make(map[string]string)
+	var value string
+	if err := Loader.LoadVariable(EnvUsers, &value); err != nil {
+		return users, nil
+	}
+
+	names := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("invalid %s pair: %s", EnvUsers, pair)
+		}
+		if _, err := internalstorage.CleanPath(name); err != nil || strings.ContainsAny(name, "/\\") {
+			return nil, fmt.Errorf("invalid user name: %s", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicated user name: %s", name)
+		}
+		if _, ok = users[token]; ok {
+			return nil, fmt.Errorf("duplicated token of the user %s", name)
+		}
+		names[name] = true
+		users[token] = name
+	}
+	return users, nil
+}
+
+// LoadSerialConfig loads the optional configuration of the serial transport, it is nil if there is no device
+func LoadSerialConfig() (*internalserial.Config, error) {
+	config := &internalserial.Config{BaudRate: internalserial.DefaultBaudRate}
+	if err := Loader.LoadVariable(EnvSerialDevice, &config.Device); err != nil {
+		return nil, nil
+	}
+
+	// Get the baud rate
+	var value string
+	if err := Loader.LoadVariable(EnvSerialBaudRate, &value); err == nil {
+		baudRate, err := strconv.Atoi(value)
+		if err != nil || baudRate <= 0 {
+			return nil, fmt.Errorf("invalid %s: %s", EnvSerialBaudRate, value)
+		}
+		config.BaudRate = baudRate
+	}
+
+	// Get the parity and the stop bits
+	var err error
+	value = ""
+	_ = Loader.LoadVariable(EnvSerialParity, &value)
+	if config.Parity, err = internalserial.ParseParity(value); err != nil {
+		return nil, err
+	}
+	value = ""
+	_ = Loader.LoadVariable(EnvSerialStopBits, &value)
+	if config.StopBits, err = internalserial.ParseStopBits(value); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadFileStorage creates the file storage of the configured backend
+func LoadFileStorage() (internalstorage.Storage, error) {
+	// Get the backend, the local one by default
+	if err := Loader.LoadVariable(EnvStorageBackend, &StorageBackend); err != nil {
+		StorageBackend = internalstorage.LocalBackend
+	}
+
+	switch StorageBackend {
+	case internalstorage.LocalBackend:
+		// Get the root, it is optional
+		if err := Loader.LoadVariable(EnvFilesRoot, &FilesRoot); err != nil {
+			FilesRoot = DefaultFilesRoot
+		}
+		return internalstorage.NewLocalStorage(FilesRoot)
+	case internalstorage.MemoryBackend:
+		return internalstorage.NewMemoryStorage(), nil
+	case internalstorage.S3Backend:
+		// Get the object store configuration
+		var config internalstorage.S3Config
+		for env, dest := range map[string]*string{
+			EnvS3Endpoint:  &config.Endpoint,
+			EnvS3AccessKey: &config.AccessKey,
+			EnvS3SecretKey: &config.SecretKey,
+			EnvS3Bucket:    &config.Bucket,
+		} {
+			if err := Loader.LoadVariable(env, dest); err != nil {
+				return nil, err
+			}
+		}
+		_ = Loader.LoadVariable(EnvS3Region, &config.Region)
+		var useSSL string
+		if err := Loader.LoadVariable(EnvS3UseSSL, &useSSL); err == nil {
+			config.UseSSL = useSSL == "true"
+		}
+		return internalstorage.NewS3Storage(&config)
+	default:
+		return nil, fmt.Errorf(
+			"unknown storage backend %s, expected: %s",
+			StorageBackend,
+			strings.Join(internalstorage.Backends, ", "),
+		)
+	}
 }
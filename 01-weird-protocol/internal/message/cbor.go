@@ -0,0 +1,113 @@
+package message
+
+import (
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"math/big"
+	"reflect"
+)
+
+// CBORMap is the CBOR major type of the maps
+const CBORMap = 5
+
+var (
+	// CBOREncMode is the CBOR encoding mode, maps are sorted to get the same bytes for the same value
+	CBOREncMode, _ = cbor.EncOptions{
+		Sort: cbor.SortCoreDeterministic,
+	}.EncMode()
+
+	// CBORDecMode is the CBOR decoding mode. Maps must have text string keys without duplicates, dates are read as RFC 3339 strings, and NaN and infinity are rejected as they can not be written in the other encodings
+	CBORDecMode, _ = cbor.DecOptions{
+		DupMapKey:      cbor.DupMapKeyEnforcedAPF,
+		IntDec:         cbor.IntDecConvertSignedOrFail,
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+		TimeTagToAny:   cbor.TimeTagToRFC3339Nano,
+		NaN:            cbor.NaNDecodeForbidden,
+		Inf:            cbor.InfDecodeForbidden,
+	}.DecMode()
+)
+
+// IsCBORMap checks if the byte is the initial byte of a CBOR map
+func IsCBORMap(c byte) bool {
+	return c>>5 == CBORMap
+}
+
+// FromCBOR converts a value decoded by the CBOR decoder to a value of the message format. Bignums are read as integers if they fit in 64 bits, and the other tags are rejected as the protocol does not define them
+func FromCBOR(decoded any) (*Value, error) {
+	switch v := decoded.(type) {
+	case nil:
+		return NewNull(), nil
+	case bool:
+		return NewBool(v), nil
+	case int64:
+		return NewInt(v), nil
+	case big.Int:
+		if !v.IsInt64() {
+			return nil, fmt.Errorf("integer %s is out of range", v.String())
+		}
+		return NewInt(v.Int64()), nil
+	case float64:
+		return NewFloat(v), nil
+	case string:
+		return NewString(v), nil
+	case []byte:
+		return NewBytes(v), nil
+	case []any:
+		items := make([]*Value, 0, len(v))
+		for _, item := range v {
+			value, err := FromCBOR(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return NewList(items...), nil
+	case map[string]any:
+		fields := make(Fields, len(v))
+		for key, item := range v {
+			value, err := FromCBOR(item)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+		}
+		return NewObject(fields), nil
+	case cbor.Tag:
+		return nil, fmt.Errorf("unsupported tag %d", v.Number)
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", decoded)
+	}
+}
+
+// DecodeCBOR decodes a CBOR value
+func DecodeCBOR(data *string) (*Value, error) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, fmt.Errorf("data is nil")
+	}
+
+	// Decode the data, the decoder fails if there is any data after the value
+	var decoded any
+	if err := CBORDecMode.Unmarshal([]byte(*data), &decoded); err != nil {
+		return nil, err
+	}
+	return FromCBOR(decoded)
+}
+
+// ParseCBOR parses a message encoded in CBOR
+func ParseCBOR(data *string) (Fields, error) {
+	value, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	return value.AsObject()
+}
+
+// EncodeCBOR encodes a value in CBOR
+func EncodeCBOR(value *Value) (string, error) {
+	encoded, err := CBOREncMode.Marshal(value.ToAny())
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,120 @@
+package message
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// TestParseCBOR tests the values read by the CBOR decoder
+func TestParseCBOR(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		key       string
+		valueType ValueType
+		value     any
+	}{
+		{"integer", "a1616101", "a", IntValue, int64(1)},
+		{"negative integer", "a1616120", "a", IntValue, int64(-1)},
+		{"text string", "a161616161", "a", StringValue, "a"},
+		{"byte string", "a16162420102", "b", BytesValue, []byte{1, 2}},
+		{"half float", "a16166f93c00", "f", FloatValue, 1.0},
+		{"double float", "a16166fb3ff8000000000000", "f", FloatValue, 1.5},
+		{"null", "a1616ef6", "n", NullValue, nil},
+		{"true", "a16162f5", "b", BoolValue, true},
+		{"indefinite length map", "bf616101ff", "a", IntValue, int64(1)},
+		{"indefinite length text string", "a161617f61616162ff", "a", StringValue, "ab"},
+		{"indefinite length array", "a161619f0102ff", "a", ListValue, []any{int64(1), int64(2)}},
+		{"bignum", "a1616ec24105", "n", IntValue, int64(5)},
+		{"date", "a16164c074323031332d30332d32315432303a30343a30305a", "d", StringValue, "2013-03-21T20:04:00Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := decodeHex(t, test.data)
+			fields, err := ParseCBOR(&data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, err := fields.Get(test.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value.Type != test.valueType {
+				t.Errorf("expected a %s, got a %s", test.valueType, value.Type)
+			}
+			if !reflect.DeepEqual(value.ToAny(), test.value) {
+				t.Errorf("expected %#v, got %#v", test.value, value.ToAny())
+			}
+		})
+	}
+}
+
+// TestParseCBORErrors tests the data rejected by the CBOR decoder
+func TestParseCBORErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not a map", "01"},
+		{"truncated map", "a26161"},
+		{"duplicated key", "a2616101616102"},
+		{"data after the map", "a161610100"},
+		{"integer key", "a10102"},
+		{"unsupported tag", "a16174d86401"},
+		{"big bignum", "a1616ec249010000000000000000"},
+		{"NaN", "a16166f97e00"},
+		{"infinity", "a16166f97c00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := decodeHex(t, test.data)
+			if _, err := ParseCBOR(&data); err == nil {
+				t.Errorf("expected an error for %s", test.data)
+			}
+		})
+	}
+}
+
+// TestEncodeCBORRoundTrip tests that the encoded values are decoded back as the same values
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	value := NewObject(
+		Fields{
+			"string": NewString(`say "hi"`),
+			"bytes":  NewBytes([]byte{0, 1, 255}),
+			"int":    NewInt(-1 << 40),
+			"float":  NewFloat(0.1),
+			"bool":   NewBool(true),
+			"null":   NewNull(),
+			"list":   NewList(NewInt(1), NewList()),
+			"object": NewObject(Fields{"key": NewString("value")}),
+		},
+	)
+	encoded, err := EncodeCBOR(value)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if DetectEncoding(&encoded) != CBOREncoding {
+		t.Errorf("expected the encoding to be detected as CBOR")
+	}
+	decoded, err := DecodeCBOR(&encoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.ToAny(), value.ToAny()) {
+		t.Errorf("expected %#v, got %#v", value.ToAny(), decoded.ToAny())
+	}
+	if bytes, _ := decoded.Fields.Get("bytes"); bytes.Type != BytesValue {
+		t.Errorf("expected a byte string, got a %s", bytes.Type)
+	}
+}
+
+// decodeHex decodes the hexadecimal data of a test
+func decodeHex(t *testing.T, data string) string {
+	t.Helper()
+	decoded, err := hex.DecodeString(data)
+	if err != nil {
+		t.Fatalf("invalid hexadecimal data %s: %v", data, err)
+	}
+	return string(decoded)
+}
@@ -0,0 +1,74 @@
+package message
+
+import (
+	"fmt"
+)
+
+// Encoding is the encoding of the messages of a connection
+type Encoding string
+
+const (
+	// TextEncoding is the key value text format of the protocol
+	TextEncoding Encoding = "text"
+
+	// JSONEncoding is the JSON encoding
+	JSONEncoding Encoding = "json"
+
+	// CBOREncoding is the compact binary encoding, RFC 8949
+	CBOREncoding Encoding = "cbor"
+)
+
+// Encodings are the supported encodings
+var Encodings = []Encoding{TextEncoding, JSONEncoding, CBOREncoding}
+
+// IsBinary checks if the encoding is a binary encoding, which should not be written as text
+func (e Encoding) IsBinary() bool {
+	return e == CBOREncoding
+}
+
+// DetectEncoding detects the encoding of a message by its leading byte. CBOR messages start with the initial byte of a map, which is never an ASCII character, and JSON messages start with a curly brace, which is never the first character of a text message
+func DetectEncoding(data *string) Encoding {
+	// Check if the data is nil or empty
+	if data == nil || len(*data) == 0 {
+		return TextEncoding
+	}
+
+	// Check if it is a CBOR map
+	if IsCBORMap((*data)[0]) {
+		return CBOREncoding
+	}
+
+	pos := SkipUntilANonSpacingCharacter(data, 0)
+	if pos < len(*data) && (*data)[pos] == '{' {
+		return JSONEncoding
+	}
+	return TextEncoding
+}
+
+// Parse parses a message with the given encoding
+func Parse(encoding Encoding, data *string) (Fields, error) {
+	switch encoding {
+	case TextEncoding:
+		return ParseText(data)
+	case JSONEncoding:
+		return ParseJSON(data)
+	case CBOREncoding:
+		return ParseCBOR(data)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// Encode encodes a value with the given encoding
+func Encode(encoding Encoding, value *Value) (string, error) {
+	switch encoding {
+	case TextEncoding:
+		return EncodeText(value)
+	case JSONEncoding:
+		return EncodeJSON(value)
+	case CBOREncoding:
+		return EncodeCBOR(value)
+	default:
+		return "", fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
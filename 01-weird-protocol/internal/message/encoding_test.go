@@ -0,0 +1,112 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+// BenchmarkRequests are the requests used to compare the encodings
+var BenchmarkRequests = []struct {
+	Name    string
+	Request *Value
+}{
+	{
+		Name: "morse",
+		Request: newBenchmarkRequest(
+			"morse",
+			Fields{
+				"message": NewString("... --- ... / ... --- ..."),
+				"to":      NewString("text"),
+			},
+		),
+	},
+	{
+		Name: "addfile",
+		Request: newBenchmarkRequest(
+			"addfile",
+			Fields{
+				"filename": NewString("report.txt"),
+				"content":  NewString(strings.Repeat("weird protocol ", 64)),
+			},
+		),
+	},
+	{
+		Name: "mail",
+		Request: newBenchmarkRequest(
+			"mail",
+			Fields{
+				"subject": NewString("Weekly report"),
+				"message": NewString("The report is attached"),
+				"to": NewList(
+					NewObject(
+						Fields{
+							"name":  NewString("Alice"),
+							"email": NewString("alice@example.com"),
+						},
+					),
+					NewObject(
+						Fields{
+							"name":  NewString("Bob"),
+							"email": NewString("bob@example.com"),
+						},
+					),
+				),
+			},
+		),
+	},
+}
+
+// newBenchmarkRequest creates a request with the given header and body
+func newBenchmarkRequest(header string, body Fields) *Value {
+	return NewObject(
+		Fields{
+			"version": NewInt(2),
+			"header":  NewString(header),
+			"body":    NewObject(body),
+		},
+	)
+}
+
+// BenchmarkParse compares the parse cost and the bytes on the wire of each encoding
+func BenchmarkParse(b *testing.B) {
+	for _, request := range BenchmarkRequests {
+		for _, encoding := range Encodings {
+			b.Run(request.Name+"/"+string(encoding), func(b *testing.B) {
+				// Encode the request and check that it can be parsed
+				data, err := Encode(encoding, request.Request)
+				if err != nil {
+					b.Fatalf("error encoding the request: %v", err)
+				}
+				if _, err = Parse(encoding, &data); err != nil {
+					b.Fatalf("error parsing the request: %v", err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _ = Parse(encoding, &data)
+				}
+				b.ReportMetric(float64(len(data)), "wire-bytes")
+			})
+		}
+	}
+}
+
+// BenchmarkEncode compares the encode cost of each encoding
+func BenchmarkEncode(b *testing.B) {
+	for _, request := range BenchmarkRequests {
+		for _, encoding := range Encodings {
+			b.Run(request.Name+"/"+string(encoding), func(b *testing.B) {
+				var data string
+				var err error
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if data, err = Encode(encoding, request.Request); err != nil {
+						b.Fatalf("error encoding the request: %v", err)
+					}
+				}
+				b.ReportMetric(float64(len(data)), "wire-bytes")
+			})
+		}
+	}
+}
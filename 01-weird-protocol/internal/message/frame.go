@@ -0,0 +1,45 @@
+package message
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// FrameHeaderSize is the size in bytes of the header of a frame, its big-endian payload size
+	FrameHeaderSize = 4
+
+	// MaxFrameSize is the maximum size in bytes of the payload of a frame. The frames start with a zero byte, as their size is less than 16 MiB, so they are distinguished from the unframed messages of the encodings
+	MaxFrameSize = 1 << 20
+)
+
+// ErrFrameTooLarge is the error for a frame whose size exceeds the maximum, which is also read when the stream is not framed
+var ErrFrameTooLarge = fmt.Errorf("frame too large, the maximum is %d bytes", MaxFrameSize)
+
+// EncodeFrame encodes a message as a frame, prefixing it with its size
+func EncodeFrame(message string) string {
+	header := make([]byte, FrameHeaderSize)
+	binary.BigEndian.PutUint32(header, uint32(len(message)))
+	return string(header) + message
+}
+
+// ReadFrame reads the message of a frame
+func ReadFrame(reader io.Reader) (string, error) {
+	// Read the header
+	header := make([]byte, FrameHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxFrameSize {
+		return "", ErrFrameTooLarge
+	}
+
+	// Read the message
+	message := make([]byte, size)
+	if _, err := io.ReadFull(reader, message); err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
@@ -0,0 +1,135 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SkipJSONSeparators skips the spacing characters, colons and commas that the JSON decoder leaves before a value
+func SkipJSONSeparators(data *string, pos int) int {
+	for pos < len(*data) {
+		c := (*data)[pos]
+		if !IsSpacingCharacter(c) && c != ':' && c != ',' {
+			break
+		}
+		pos++
+	}
+	return pos
+}
+
+// ReadJSONValue reads the next JSON value from the decoder
+func ReadJSONValue(decoder *json.Decoder, data *string) (*Value, error) {
+	// Get the position of the value and its first token
+	pos := SkipJSONSeparators(data, int(decoder.InputOffset()))
+	token, err := decoder.Token()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, MissingAtPositionError("value", pos)
+		}
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			// Get the fields until the closing curly brace
+			fields := make(Fields)
+			for decoder.More() {
+				keyPos := SkipJSONSeparators(data, int(decoder.InputOffset()))
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := keyToken.(string)
+
+				// Check if the key is duplicated
+				if _, ok := fields[key]; ok {
+					return nil, fmt.Errorf(
+						"duplicated field %s at position %d",
+						key,
+						keyPos,
+					)
+				}
+
+				// Get the value
+				value, err := ReadJSONValue(decoder, data)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = value
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, err
+			}
+			return &Value{Type: ObjectValue, Pos: pos, Fields: fields}, nil
+		case '[':
+			// Get the items until the closing square bracket
+			items := make([]*Value, 0)
+			for decoder.More() {
+				item, err := ReadJSONValue(decoder, data)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, err
+			}
+			return &Value{Type: ListValue, Pos: pos, Items: items}, nil
+		}
+	case string:
+		return &Value{Type: StringValue, Raw: t, Pos: pos}, nil
+	case json.Number:
+		return ReadScalar(t.String(), pos), nil
+	case bool:
+		return &Value{Type: BoolValue, Raw: strconv.FormatBool(t), Pos: pos}, nil
+	case nil:
+		return &Value{Type: NullValue, Raw: "null", Pos: pos}, nil
+	}
+	return nil, fmt.Errorf("unexpected token at position %d", pos)
+}
+
+// ParseJSON parses a message written in JSON
+func ParseJSON(data *string) (Fields, error) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, fmt.Errorf("data is nil")
+	}
+
+	// Create the decoder, numbers are kept as they were written to classify them as integers or floats
+	decoder := json.NewDecoder(strings.NewReader(*data))
+	decoder.UseNumber()
+
+	// Get the message, it must be an object
+	value, err := ReadJSONValue(decoder, data)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := value.AsObject()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if there is any data after the message
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf(
+			"unexpected data at position %d",
+			SkipJSONSeparators(data, int(decoder.InputOffset())),
+		)
+	}
+	return fields, nil
+}
+
+// EncodeJSON encodes a value in JSON
+func EncodeJSON(value *Value) (string, error) {
+	encoded, err := json.Marshal(value.ToAny())
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,77 @@
+package message
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReadLegacyValue reads a value of the version 1 grammar. Its scalars are untyped strings, its quoted strings end at the next double quote without escapes, and it has no lists
+func ReadLegacyValue(data *string, pos int) (
+	value *Value,
+	finalPos int,
+	err error,
+) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	// Check if there is a value
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	if pos >= dataLen {
+		return nil, -1, MissingAtPositionError("value", pos)
+	}
+	valuePos := pos
+
+	// Check the type of the value by its first character
+	switch (*data)[pos] {
+	case '"':
+		// Get the string until the next double quote
+		length := strings.IndexByte((*data)[pos+1:], '"')
+		if length < 0 {
+			return nil, -1, MissingAtPositionError("\"", dataLen)
+		}
+		return &Value{
+			Type: StringValue,
+			Raw:  (*data)[pos+1 : pos+1+length],
+			Pos:  valuePos,
+		}, pos + length + 2, nil
+	case '{':
+		// Get the fields until the closing curly brace
+		fields, finalPos, err := ReadKeyValues(
+			data,
+			pos+1,
+			true,
+			ReadLegacyValue,
+		)
+		if err != nil {
+			return nil, -1, err
+		}
+		return &Value{
+			Type:   ObjectValue,
+			Pos:    valuePos,
+			Fields: fields,
+		}, finalPos, nil
+	}
+
+	// Get the unquoted string until a comma or the closing curly brace of its object
+	for pos < dataLen {
+		c := (*data)[pos]
+		if c == ',' || c == '}' {
+			break
+		}
+		pos++
+	}
+	raw := strings.TrimRight((*data)[valuePos:pos], " \n\t\r")
+	if raw == "" {
+		return nil, -1, MissingAtPositionError("value", valuePos)
+	}
+	return &Value{Type: StringValue, Raw: raw, Pos: valuePos}, pos, nil
+}
+
+// ParseLegacyText parses a message written in the text format of the version 1 grammar
+func ParseLegacyText(data *string) (Fields, error) {
+	fields, _, err := ReadKeyValues(data, 0, false, ReadLegacyValue)
+	return fields, err
+}
@@ -0,0 +1,65 @@
+package message
+
+import (
+	"testing"
+)
+
+// TestParseLegacyText tests that the values of the version 1 grammar are read as untyped strings, without escapes
+func TestParseLegacyText(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		raw  string
+	}{
+		{"quoted string", `value: "hello world"`, "hello world"},
+		{"unquoted string", `value: hello world`, "hello world"},
+		{"null", `value: null`, "null"},
+		{"boolean", `value: true`, "true"},
+		{"integer", `value: 42`, "42"},
+		{"list", `value: [1`, "[1"},
+		{"trailing backslash", `value: "C:\dir\"`, `C:\dir\`},
+		{"escaped backslash", `value: "a\\b"`, `a\\b`},
+		{"trailing spaces", "value: hello  \n", "hello"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := ParseLegacyText(&test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, err := fields.GetString("value")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != test.raw {
+				t.Errorf("expected %q, got %q", test.raw, value)
+			}
+		})
+	}
+}
+
+// TestParseLegacyTextBaseline tests a request written by the baseline client, with nested objects and an unquoted subject
+func TestParseLegacyTextBaseline(t *testing.T) {
+	data := "header: \"mail\",\nbody: {\n\tsubject: null,\n\tmessage: \"say \\\",\n\tto: {\n\t\tname: \"Ana\",\n\t\temail: \"ana@example.com\"\n\t}\n}"
+	fields, err := ParseLegacyText(&data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := fields.GetObject("body")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject, err := body.GetString("subject"); err != nil || subject != "null" {
+		t.Errorf("expected null as a string, got %q, %v", subject, err)
+	}
+	if message, err := body.GetString("message"); err != nil || message != `say \` {
+		t.Errorf("expected a trailing backslash, got %q, %v", message, err)
+	}
+	to, err := body.GetObject("to")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if email, err := to.GetString("email"); err != nil || email != "ana@example.com" {
+		t.Errorf("expected the email, got %q, %v", email, err)
+	}
+}
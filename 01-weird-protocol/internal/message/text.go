@@ -0,0 +1,386 @@
+package message
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueReader reads a value of a grammar, returning the position after it
+type ValueReader func(data *string, pos int) (value *Value, finalPos int, err error)
+
+// FloatPattern is the numeric syntax of the unquoted floating point numbers. strconv.ParseFloat also accepts NaN, Inf and hexadecimal numbers, which are read as strings instead
+var FloatPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// MissingAtPositionError is the error for a missing string at a position
+func MissingAtPositionError(str string, pos int) error {
+	return fmt.Errorf("'%s' is missing in the data at position %d", str, pos)
+}
+
+// IsSpacingCharacter checks if the character is a spacing character
+func IsSpacingCharacter(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t' || c == '\r'
+}
+
+// SkipUntilANonSpacingCharacter skips until a non-spacing character is found
+func SkipUntilANonSpacingCharacter(data *string, pos int) int {
+	// Check if the data is nil
+	if data == nil {
+		return pos
+	}
+
+	// Skip until a non-spacing character is found
+	for pos < len(*data) && IsSpacingCharacter((*data)[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// ReadScalar classifies an unquoted value as an integer, a float, a boolean, null or a string
+func ReadScalar(raw string, pos int) *Value {
+	value := &Value{Raw: raw, Pos: pos}
+	switch raw {
+	case "true", "false":
+		value.Type = BoolValue
+	case "null":
+		value.Type = NullValue
+	default:
+		if _, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			value.Type = IntValue
+		} else if FloatPattern.MatchString(raw) {
+			value.Type = FloatValue
+		} else {
+			value.Type = StringValue
+		}
+	}
+	return value
+}
+
+// ReadQuotedString reads a string until the closing double quote. A backslash escapes a double quote or another backslash, and is kept as it is before any other character
+func ReadQuotedString(data *string, pos int) (raw string, finalPos int, err error) {
+	// Check if the data is nil
+	if data == nil {
+		return "", -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	var builder strings.Builder
+	for pos < dataLen {
+		c := (*data)[pos]
+		switch {
+		case c == '"':
+			return builder.String(), pos + 1, nil
+		case c == '\\' && pos+1 < dataLen && ((*data)[pos+1] == '"' || (*data)[pos+1] == '\\'):
+			builder.WriteByte((*data)[pos+1])
+			pos += 2
+		default:
+			builder.WriteByte(c)
+			pos++
+		}
+	}
+	return "", -1, MissingAtPositionError("\"", dataLen)
+}
+
+// QuoteString writes a string between double quotes, escaping its double quotes and backslashes
+func QuoteString(str string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		if str[i] == '"' || str[i] == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(str[i])
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// ReadValue reads a value
+func ReadValue(data *string, pos int) (value *Value, finalPos int, err error) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	// Check if there is a value
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	if pos >= dataLen {
+		return nil, -1, MissingAtPositionError("value", pos)
+	}
+	valuePos := pos
+
+	// Check the type of the value by its first character
+	switch (*data)[pos] {
+	case '"':
+		// Get the string until the closing double quote
+		raw, finalPos, err := ReadQuotedString(data, pos+1)
+		if err != nil {
+			return nil, -1, err
+		}
+		return &Value{
+			Type: StringValue,
+			Raw:  raw,
+			Pos:  valuePos,
+		}, finalPos, nil
+	case '{':
+		// Get the fields until the closing curly brace
+		fields, finalPos, err := ReadKeyValues(data, pos+1, true, ReadValue)
+		if err != nil {
+			return nil, -1, err
+		}
+		return &Value{
+			Type:   ObjectValue,
+			Pos:    valuePos,
+			Fields: fields,
+		}, finalPos, nil
+	case '[':
+		// Get the items until the closing square bracket
+		items, finalPos, err := ReadListItems(data, pos+1)
+		if err != nil {
+			return nil, -1, err
+		}
+		return &Value{
+			Type:  ListValue,
+			Pos:   valuePos,
+			Items: items,
+		}, finalPos, nil
+	}
+
+	// Get the unquoted value until a comma or a closing character
+	for pos < dataLen {
+		c := (*data)[pos]
+		if c == ',' || c == '}' || c == ']' {
+			break
+		}
+		pos++
+	}
+	raw := strings.TrimRight((*data)[valuePos:pos], " \n\t\r")
+	if raw == "" {
+		return nil, -1, MissingAtPositionError("value", valuePos)
+	}
+	return ReadScalar(raw, valuePos), pos, nil
+}
+
+// ReadListItems reads the items of a list until the closing square bracket
+func ReadListItems(data *string, pos int) (
+	items []*Value,
+	finalPos int,
+	err error,
+) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	// Check if it is an empty list
+	items = make([]*Value, 0)
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	if pos < dataLen && (*data)[pos] == ']' {
+		return items, pos + 1, nil
+	}
+
+	for {
+		// Get the item
+		item, finalPos, err := ReadValue(data, pos)
+		if err != nil {
+			return nil, -1, err
+		}
+		items = append(items, item)
+
+		// Check if the next character is a comma or the closing square bracket
+		pos = SkipUntilANonSpacingCharacter(data, finalPos)
+		if pos >= dataLen {
+			return nil, -1, MissingAtPositionError("]", pos)
+		}
+		if (*data)[pos] == ']' {
+			return items, pos + 1, nil
+		}
+		if (*data)[pos] != ',' {
+			return nil, -1, MissingAtPositionError(",", pos)
+		}
+		pos++
+	}
+}
+
+// ReadKeyValue reads a key value pair, its value is read with the value reader of the grammar
+func ReadKeyValue(
+	data *string,
+	pos int,
+	readValueFn ValueReader,
+) (
+	key string,
+	value *Value,
+	finalPos int,
+	err error,
+) {
+	// Check if the data is nil
+	if data == nil {
+		return "", nil, -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	// Get the key
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	keyPos := pos
+	for pos < dataLen && !IsSpacingCharacter((*data)[pos]) && (*data)[pos] != ':' {
+		pos++
+	}
+	key = (*data)[keyPos:pos]
+	if key == "" {
+		return "", nil, -1, MissingAtPositionError("key", keyPos)
+	}
+
+	// Check if the key is followed by a colon
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	if pos >= dataLen || (*data)[pos] != ':' {
+		return key, nil, -1, MissingAtPositionError(":", pos)
+	}
+
+	// Get the value
+	value, finalPos, err = readValueFn(data, pos+1)
+	if err != nil {
+		return key, nil, -1, err
+	}
+	return key, value, finalPos, nil
+}
+
+// ReadKeyValues reads key value pairs until the end of the data, or until the closing curly brace if it is a nested object
+func ReadKeyValues(
+	data *string,
+	pos int,
+	isNestedObject bool,
+	readValueFn ValueReader,
+) (fields Fields, finalPos int, err error) {
+	// Check if the data is nil
+	if data == nil {
+		return nil, -1, fmt.Errorf("data is nil")
+	}
+	dataLen := len(*data)
+
+	// Check if it is an empty object
+	fields = make(Fields)
+	pos = SkipUntilANonSpacingCharacter(data, pos)
+	if isNestedObject && pos < dataLen && (*data)[pos] == '}' {
+		return fields, pos + 1, nil
+	}
+
+	for {
+		// Get the key and value
+		keyPos := SkipUntilANonSpacingCharacter(data, pos)
+		key, value, finalPos, err := ReadKeyValue(data, pos, readValueFn)
+		if err != nil {
+			return nil, -1, err
+		}
+
+		// Check if the key is duplicated
+		if _, ok := fields[key]; ok {
+			return nil, -1, fmt.Errorf(
+				"duplicated field %s at position %d",
+				key,
+				keyPos,
+			)
+		}
+		fields[key] = value
+
+		// Check if the next character is a comma, the closing curly brace or the end of the data
+		pos = SkipUntilANonSpacingCharacter(data, finalPos)
+		if pos >= dataLen {
+			if isNestedObject {
+				return nil, -1, MissingAtPositionError("}", pos)
+			}
+			return fields, pos, nil
+		}
+		if (*data)[pos] == '}' {
+			if !isNestedObject {
+				return nil, -1, fmt.Errorf(
+					"unexpected data after the %s",
+					key,
+				)
+			}
+			return fields, pos + 1, nil
+		}
+		if (*data)[pos] != ',' {
+			return nil, -1, MissingAtPositionError(",", pos)
+		}
+		pos++
+	}
+}
+
+// ParseText parses a message written in the text format
+func ParseText(data *string) (Fields, error) {
+	fields, _, err := ReadKeyValues(data, 0, false, ReadValue)
+	return fields, err
+}
+
+// WriteTextValue writes a value in the text format
+func WriteTextValue(builder *strings.Builder, value *Value, indent int) error {
+	switch value.Type {
+	case StringValue:
+		builder.WriteString(QuoteString(value.Raw))
+	case BytesValue:
+		builder.WriteString(QuoteString(base64.StdEncoding.EncodeToString([]byte(value.Raw))))
+	case ListValue:
+		builder.WriteString("[")
+		for i, item := range value.Items {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			if err := WriteTextValue(builder, item, indent); err != nil {
+				return err
+			}
+		}
+		builder.WriteString("]")
+	case ObjectValue:
+		builder.WriteString("{\n")
+		if err := WriteTextFields(builder, value.Fields, indent+1); err != nil {
+			return err
+		}
+		builder.WriteString("\n" + strings.Repeat("\t", indent) + "}")
+	default:
+		builder.WriteString(value.Raw)
+	}
+	return nil
+}
+
+// WriteTextFields writes the key value pairs of an object in the text format, sorted by key
+func WriteTextFields(builder *strings.Builder, fields Fields, indent int) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		if i > 0 {
+			builder.WriteString(",\n")
+		}
+		builder.WriteString(strings.Repeat("\t", indent) + key + ": ")
+		if err := WriteTextValue(builder, fields[key], indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeText encodes a value in the text format. A top level object is written as its key value pairs, and a top level string is written as it is, so plain responses keep being readable
+func EncodeText(value *Value) (string, error) {
+	var builder strings.Builder
+	switch value.Type {
+	case StringValue:
+		return value.Raw, nil
+	case ObjectValue:
+		if err := WriteTextFields(&builder, value.Fields, 0); err != nil {
+			return "", err
+		}
+	default:
+		if err := WriteTextValue(&builder, value, 0); err != nil {
+			return "", err
+		}
+	}
+	return builder.String(), nil
+}
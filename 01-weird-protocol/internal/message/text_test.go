@@ -0,0 +1,257 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseText tests the types of the values read by the text parser
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		valueType ValueType
+		raw       string
+	}{
+		{"quoted string", `value: "hello world"`, StringValue, "hello world"},
+		{"unquoted string", `value: hello`, StringValue, "hello"},
+		{"integer", `value: 42`, IntValue, "42"},
+		{"negative integer", `value: -7`, IntValue, "-7"},
+		{"float", `value: 3.14`, FloatValue, "3.14"},
+		{"float with exponent", `value: 1e-3`, FloatValue, "1e-3"},
+		{"float without integer part", `value: .5`, FloatValue, ".5"},
+		{"quoted number", `value: "42"`, StringValue, "42"},
+		{"true", `value: true`, BoolValue, "true"},
+		{"false", `value: false`, BoolValue, "false"},
+		{"null", `value: null`, NullValue, "null"},
+		{"NaN", `value: NaN`, StringValue, "NaN"},
+		{"Inf", `value: Inf`, StringValue, "Inf"},
+		{"negative infinity", `value: -infinity`, StringValue, "-infinity"},
+		{"hexadecimal float", `value: 0x1p-2`, StringValue, "0x1p-2"},
+		{"trailing spaces", "value: hello  \n", StringValue, "hello"},
+		{"escaped double quote", `value: "say \"hi\""`, StringValue, `say "hi"`},
+		{"escaped backslash", `value: "a\\b"`, StringValue, `a\b`},
+		{"unescaped backslash", `value: "C:\dir"`, StringValue, `C:\dir`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := ParseText(&test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, err := fields.Get("value")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value.Type != test.valueType {
+				t.Errorf("expected a %s, got a %s", test.valueType, value.Type)
+			}
+			if value.Raw != test.raw {
+				t.Errorf("expected %q, got %q", test.raw, value.Raw)
+			}
+		})
+	}
+}
+
+// TestParseTextNested tests the lists and nested objects read by the text parser
+func TestParseTextNested(t *testing.T) {
+	data := `header: echo, body: {items: [1, "two", 3.5, [], {}], nested: {key: value}}`
+	fields, err := ParseText(&data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := fields.GetObject("body")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, err := body.GetList("items")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedTypes := []ValueType{IntValue, StringValue, FloatValue, ListValue, ObjectValue}
+	if len(items) != len(expectedTypes) {
+		t.Fatalf("expected %d items, got %d", len(expectedTypes), len(items))
+	}
+	for i, item := range items {
+		if item.Type != expectedTypes[i] {
+			t.Errorf("item %d: expected a %s, got a %s", i, expectedTypes[i], item.Type)
+		}
+	}
+	nested, err := body.GetObject("nested")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, err := nested.GetString("key"); err != nil || value != "value" {
+		t.Errorf("expected value, got %q, %v", value, err)
+	}
+}
+
+// TestParseTextErrors tests the data rejected by the text parser
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ``},
+		{"missing colon", `header echo`},
+		{"missing value", `header:`},
+		{"unterminated string", `header: "echo`},
+		{"unterminated list", `items: [1, 2`},
+		{"unterminated object", `body: {key: value`},
+		{"missing comma", `body: {key: "value" other: 1}`},
+		{"duplicated field", `header: echo, header: echo`},
+		{"unexpected closing brace", `header: echo}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseText(&test.data); err == nil {
+				t.Errorf("expected an error for %q", test.data)
+			}
+		})
+	}
+}
+
+// TestFieldsAccessors tests the typed accessors of the fields
+func TestFieldsAccessors(t *testing.T) {
+	data := `text: hello, number: 42, ratio: 0.5, flag: true, empty: null, items: [1], object: {key: value}`
+	fields, err := ParseText(&data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Check the accessors of the expected types
+	if value, err := fields.GetString("text"); err != nil || value != "hello" {
+		t.Errorf("GetString: got %q, %v", value, err)
+	}
+	if value, err := fields.GetString("number"); err != nil || value != "42" {
+		t.Errorf("GetString of an integer: got %q, %v", value, err)
+	}
+	if value, err := fields.GetInt("number"); err != nil || value != 42 {
+		t.Errorf("GetInt: got %d, %v", value, err)
+	}
+	if value, err := fields.GetFloat("ratio"); err != nil || value != 0.5 {
+		t.Errorf("GetFloat: got %v, %v", value, err)
+	}
+	if value, err := fields.GetFloat("number"); err != nil || value != 42 {
+		t.Errorf("GetFloat of an integer: got %v, %v", value, err)
+	}
+	if value, err := fields.GetBool("flag"); err != nil || !value {
+		t.Errorf("GetBool: got %v, %v", value, err)
+	}
+	if items, err := fields.GetList("items"); err != nil || len(items) != 1 {
+		t.Errorf("GetList: got %v, %v", items, err)
+	}
+	if object, err := fields.GetObject("object"); err != nil || !object.Has("key") {
+		t.Errorf("GetObject: got %v, %v", object, err)
+	}
+	if fields.Has("empty") {
+		t.Errorf("Has: expected a null field to be missing")
+	}
+
+	// Check the accessors of the unexpected types
+	if _, err := fields.GetInt("text"); err == nil {
+		t.Errorf("GetInt of a string: expected an error")
+	}
+	if _, err := fields.GetInt("ratio"); err == nil {
+		t.Errorf("GetInt of a float: expected an error")
+	}
+	if _, err := fields.GetBool("number"); err == nil {
+		t.Errorf("GetBool of an integer: expected an error")
+	}
+	if _, err := fields.GetString("items"); err == nil {
+		t.Errorf("GetString of a list: expected an error")
+	}
+	if _, err := fields.GetObject("text"); err == nil {
+		t.Errorf("GetObject of a string: expected an error")
+	}
+	if _, err := fields.GetString("missing"); err == nil {
+		t.Errorf("GetString of a missing field: expected an error")
+	}
+
+	// Check the required and allowed fields
+	if err := fields.Require("text", "number"); err != nil {
+		t.Errorf("Require: unexpected error: %v", err)
+	}
+	if err := fields.Require("text", "missing"); err == nil {
+		t.Errorf("Require: expected an error")
+	}
+	if err := fields.Allow("text"); err == nil {
+		t.Errorf("Allow: expected an error")
+	}
+}
+
+// TestEncodeTextRoundTrip tests that the encoded values are parsed back as the same values
+func TestEncodeTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *Value
+	}{
+		{"string", NewString("hello world")},
+		{"numeric string", NewString("42")},
+		{"boolean string", NewString("true")},
+		{"NaN string", NewString("NaN")},
+		{"empty string", NewString("")},
+		{"multiline string", NewString("first line\nsecond line")},
+		{"double quote", NewString(`"`)},
+		{"quoted string", NewString(`say "hi"`)},
+		{"backslashes", NewString(`a\b\\c\`)},
+		{"escape sequence", NewString(`\"`)},
+		{"integer", NewInt(-42)},
+		{"float", NewFloat(3.25)},
+		{"float with exponent", NewFloat(1e21)},
+		{"boolean", NewBool(false)},
+		{"null", NewNull()},
+		{"empty list", NewList()},
+		{"list", NewList(NewInt(1), NewString("two"), NewList(NewBool(true)))},
+		{"empty object", NewObject(nil)},
+		{"object", NewObject(Fields{"key": NewString("value"), "number": NewInt(1)})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Encode the value as a field, so strings are quoted
+			encoded, err := EncodeText(NewObject(Fields{"value": test.value}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Parse it back
+			fields, err := ParseText(&encoded)
+			if err != nil {
+				t.Fatalf("unexpected error parsing %q: %v", encoded, err)
+			}
+			value, err := fields.Get("value")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(value.ToAny(), test.value.ToAny()) || value.Type != test.value.Type {
+				t.Errorf("expected %#v, got %#v from %q", test.value.ToAny(), value.ToAny(), encoded)
+			}
+		})
+	}
+}
+
+// TestEncodeTextTopLevel tests the values written at the top level by the text encoder
+func TestEncodeTextTopLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    *Value
+		expected string
+	}{
+		{"string", NewString("File added successfully"), "File added successfully"},
+		{"integer", NewInt(7), "7"},
+		{"list", NewList(NewString("a"), NewInt(1)), `["a", 1]`},
+		{"list with a double quote", NewList(NewString(`"`)), `["\""]`},
+		{"object", NewObject(Fields{"b": NewInt(2), "a": NewString("x")}), "a: \"x\",\nb: 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := EncodeText(test.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if encoded != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, encoded)
+			}
+		})
+	}
+}
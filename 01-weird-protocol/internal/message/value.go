@@ -0,0 +1,349 @@
+package message
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type of value in the message format
+type ValueType int
+
+const (
+	// StringValue is a quoted string, or an unquoted value that is not a number, a boolean or null
+	StringValue ValueType = iota
+
+	// IntValue is an integer number
+	IntValue
+
+	// FloatValue is a floating point number
+	FloatValue
+
+	// BoolValue is a boolean, true or false
+	BoolValue
+
+	// NullValue is the null value
+	NullValue
+
+	// ListValue is a list of values between square brackets
+	ListValue
+
+	// ObjectValue is a nested object between curly braces
+	ObjectValue
+
+	// BytesValue is a byte string, only the binary encodings have them. The other encodings write them as base64 strings
+	BytesValue
+)
+
+// String returns the name of the value type
+func (v ValueType) String() string {
+	switch v {
+	case StringValue:
+		return "string"
+	case IntValue:
+		return "integer"
+	case FloatValue:
+		return "float"
+	case BoolValue:
+		return "boolean"
+	case NullValue:
+		return "null"
+	case ListValue:
+		return "list"
+	case ObjectValue:
+		return "nested object"
+	case BytesValue:
+		return "byte string"
+	default:
+		return "unknown"
+	}
+}
+
+type (
+	// Value is a parsed value of the message format
+	Value struct {
+		// Type is the type of the value
+		Type ValueType
+
+		// Raw is the raw text of a scalar value, without the double quotes, or the bytes of a byte string
+		Raw string
+
+		// Pos is the position of the value in the data
+		Pos int
+
+		// Items are the values of a list
+		Items []*Value
+
+		// Fields are the fields of a nested object
+		Fields Fields
+	}
+
+	// Fields are the key value pairs of an object. The fields of a body that a handler does not read are ignored, so the clients can send the optional fields of a newer protocol version to an older server. The fields of the request itself are checked with Allow
+	Fields map[string]*Value
+)
+
+// TypeError is the error for a value that is not of the expected type
+func TypeError(expected ValueType, pos int) error {
+	// Check if the position is known, it is not for the encodings that do not track it
+	if pos < 0 {
+		return fmt.Errorf("expected a %s", expected)
+	}
+	return fmt.Errorf("expected a %s at position %d", expected, pos)
+}
+
+// IsNull checks if the value is null
+func (v *Value) IsNull() bool {
+	return v == nil || v.Type == NullValue
+}
+
+// AsString returns the value as a string. Numbers and booleans are returned as they were written, so unquoted values keep working as strings
+func (v *Value) AsString() (string, error) {
+	switch v.Type {
+	case StringValue, IntValue, FloatValue, BoolValue:
+		return v.Raw, nil
+	default:
+		return "", TypeError(StringValue, v.Pos)
+	}
+}
+
+// AsBytes returns the value as bytes, strings are also accepted
+func (v *Value) AsBytes() ([]byte, error) {
+	if v.Type != BytesValue && v.Type != StringValue {
+		return nil, TypeError(BytesValue, v.Pos)
+	}
+	return []byte(v.Raw), nil
+}
+
+// AsInt returns the value as an integer
+func (v *Value) AsInt() (int64, error) {
+	if v.Type != IntValue {
+		return 0, TypeError(IntValue, v.Pos)
+	}
+	return strconv.ParseInt(v.Raw, 10, 64)
+}
+
+// AsFloat returns the value as a floating point number, integers are also accepted
+func (v *Value) AsFloat() (float64, error) {
+	if v.Type != FloatValue && v.Type != IntValue {
+		return 0, TypeError(FloatValue, v.Pos)
+	}
+	return strconv.ParseFloat(v.Raw, 64)
+}
+
+// AsBool returns the value as a boolean
+func (v *Value) AsBool() (bool, error) {
+	if v.Type != BoolValue {
+		return false, TypeError(BoolValue, v.Pos)
+	}
+	return v.Raw == "true", nil
+}
+
+// AsList returns the items of a list value
+func (v *Value) AsList() ([]*Value, error) {
+	if v.Type != ListValue {
+		return nil, TypeError(ListValue, v.Pos)
+	}
+	return v.Items, nil
+}
+
+// AsObject returns the fields of a nested object value
+func (v *Value) AsObject() (Fields, error) {
+	if v.Type != ObjectValue {
+		return nil, TypeError(ObjectValue, v.Pos)
+	}
+	return v.Fields, nil
+}
+
+// Require checks that all the given fields are present
+func (f Fields) Require(keys ...string) error {
+	var missingFields []string
+	for _, key := range keys {
+		if _, ok := f[key]; !ok {
+			missingFields = append(missingFields, key)
+		}
+	}
+	if len(missingFields) > 0 {
+		return fmt.Errorf(
+			"missing fields: %s",
+			strings.Join(missingFields, ", "),
+		)
+	}
+	return nil
+}
+
+// Allow checks that there are no fields other than the given ones
+func (f Fields) Allow(keys ...string) error {
+	var unexpectedFields []string
+	for key := range f {
+		if !slices.Contains(keys, key) {
+			unexpectedFields = append(unexpectedFields, key)
+		}
+	}
+	if len(unexpectedFields) > 0 {
+		sort.Strings(unexpectedFields)
+		return fmt.Errorf(
+			"unexpected fields: %s",
+			strings.Join(unexpectedFields, ", "),
+		)
+	}
+	return nil
+}
+
+// Get returns the value of a field
+func (f Fields) Get(key string) (*Value, error) {
+	value, ok := f[key]
+	if !ok {
+		return nil, fmt.Errorf("missing fields: %s", key)
+	}
+	return value, nil
+}
+
+// Has checks if a field is present and is not null
+func (f Fields) Has(key string) bool {
+	value, ok := f[key]
+	return ok && !value.IsNull()
+}
+
+// GetString returns the value of a field as a string
+func (f Fields) GetString(key string) (string, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return value.AsString()
+}
+
+// GetInt returns the value of a field as an integer
+func (f Fields) GetInt(key string) (int64, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return value.AsInt()
+}
+
+// GetFloat returns the value of a field as a floating point number
+func (f Fields) GetFloat(key string) (float64, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return value.AsFloat()
+}
+
+// GetBool returns the value of a field as a boolean
+func (f Fields) GetBool(key string) (bool, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return value.AsBool()
+}
+
+// GetList returns the items of a list field
+func (f Fields) GetList(key string) ([]*Value, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return value.AsList()
+}
+
+// GetObject returns the fields of a nested object field
+func (f Fields) GetObject(key string) (Fields, error) {
+	value, err := f.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return value.AsObject()
+}
+
+// NewString creates a new string value
+func NewString(value string) *Value {
+	return &Value{Type: StringValue, Raw: value, Pos: -1}
+}
+
+// NewInt creates a new integer value
+func NewInt(value int64) *Value {
+	return &Value{Type: IntValue, Raw: strconv.FormatInt(value, 10), Pos: -1}
+}
+
+// NewFloat creates a new floating point number value
+func NewFloat(value float64) *Value {
+	return &Value{
+		Type: FloatValue,
+		Raw:  strconv.FormatFloat(value, 'g', -1, 64),
+		Pos:  -1,
+	}
+}
+
+// NewBool creates a new boolean value
+func NewBool(value bool) *Value {
+	return &Value{Type: BoolValue, Raw: strconv.FormatBool(value), Pos: -1}
+}
+
+// NewBytes creates a new byte string value
+func NewBytes(value []byte) *Value {
+	return &Value{Type: BytesValue, Raw: string(value), Pos: -1}
+}
+
+// NewNull creates a new null value
+func NewNull() *Value {
+	return &Value{Type: NullValue, Raw: "null", Pos: -1}
+}
+
+// NewList creates a new list value
+func NewList(items ...*Value) *Value {
+	if items == nil {
+		items = make([]*Value, 0)
+	}
+	return &Value{Type: ListValue, Pos: -1, Items: items}
+}
+
+// NewObject creates a new nested object value
+func NewObject(fields Fields) *Value {
+	if fields == nil {
+		fields = make(Fields)
+	}
+	return &Value{Type: ObjectValue, Pos: -1, Fields: fields}
+}
+
+// ToAny converts the value to its Go representation, used by the encoders of the standard library and third party packages
+func (v *Value) ToAny() any {
+	switch v.Type {
+	case IntValue:
+		value, err := v.AsInt()
+		if err != nil {
+			return v.Raw
+		}
+		return value
+	case FloatValue:
+		value, err := v.AsFloat()
+		if err != nil {
+			return v.Raw
+		}
+		return value
+	case BoolValue:
+		return v.Raw == "true"
+	case NullValue:
+		return nil
+	case BytesValue:
+		return []byte(v.Raw)
+	case ListValue:
+		items := make([]any, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = item.ToAny()
+		}
+		return items
+	case ObjectValue:
+		fields := make(map[string]any, len(v.Fields))
+		for key, value := range v.Fields {
+			fields[key] = value.ToAny()
+		}
+		return fields
+	default:
+		return v.Raw
+	}
+}
@@ -0,0 +1,147 @@
+package morse
+
+import (
+	"encoding/json"
+	"fmt"
+	gomorseinternational "github.com/ralvarezdev/go-morse/international"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// InternationalAlphabet is the name of the international alphabet, provided by go-morse
+	InternationalAlphabet = "international"
+
+	// DefaultLetterSeparator is the default separator between the codes of the letters of a word
+	DefaultLetterSeparator = " "
+
+	// DefaultWordSeparator is the default separator between the words
+	DefaultWordSeparator = " / "
+
+	// AlphabetFileExtension is the extension of the alphabet files
+	AlphabetFileExtension = ".json"
+)
+
+type (
+	// Separators are the separators of the letters and words of the morse code
+	Separators struct {
+		Letter string `json:"letter"`
+		Word   string `json:"word"`
+	}
+
+	// Alphabet is a morse code alphabet
+	Alphabet struct {
+		// Name is the name of the alphabet
+		Name string `json:"name"`
+
+		// Characters are the codes of the characters
+		Characters map[string]string `json:"characters"`
+
+		// Prosigns are the codes of the procedural signals
+		Prosigns map[string]string `json:"prosigns"`
+
+		// Separators are the default separators of the alphabet, alphabets whose codes contain spaces need different ones
+		Separators Separators `json:"separators"`
+	}
+)
+
+// NewInternationalAlphabet creates the international alphabet from the go-morse tables
+func NewInternationalAlphabet() *Alphabet {
+	alphabet := &Alphabet{
+		Name:       InternationalAlphabet,
+		Characters: make(map[string]string),
+		Prosigns:   make(map[string]string),
+		Separators: Separators{
+			Letter: DefaultLetterSeparator,
+			Word:   DefaultWordSeparator,
+		},
+	}
+	for _, character := range *gomorseinternational.Alphabet {
+		alphabet.Characters[string(character.GetUnicode())] = character.GetCode()
+	}
+	for _, signal := range *gomorseinternational.ProvisionalSignals {
+		alphabet.Prosigns[signal.GetSignal()] = signal.GetCode()
+	}
+	return alphabet
+}
+
+// LoadAlphabetFile loads an alphabet from a JSON file, its name defaults to the filename without the extension
+func LoadAlphabetFile(path string) (*Alphabet, error) {
+	// Read the file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the alphabet
+	var alphabet Alphabet
+	if err = json.Unmarshal(content, &alphabet); err != nil {
+		return nil, fmt.Errorf("error decoding alphabet %s: %v", path, err)
+	}
+	if alphabet.Name == "" {
+		alphabet.Name = strings.TrimSuffix(
+			filepath.Base(path),
+			AlphabetFileExtension,
+		)
+	}
+	if alphabet.Separators.Letter == "" {
+		alphabet.Separators.Letter = DefaultLetterSeparator
+	}
+	if alphabet.Separators.Word == "" {
+		alphabet.Separators.Word = DefaultWordSeparator
+	}
+
+	// Check that the characters are single characters
+	for character := range alphabet.Characters {
+		if utf8.RuneCountInString(character) != 1 {
+			return nil, fmt.Errorf(
+				"invalid character %q in alphabet %s, expected a single character",
+				character,
+				alphabet.Name,
+			)
+		}
+	}
+	return &alphabet, nil
+}
+
+// LoadAlphabetsFolder loads the converters of the international alphabet and of the alphabet files in the given folder, if it exists
+func LoadAlphabetsFolder(folder string) (map[string]*Converter, error) {
+	// Create the international alphabet converter
+	converters := make(map[string]*Converter)
+	converter, err := NewConverter(NewInternationalAlphabet())
+	if err != nil {
+		return nil, err
+	}
+	converters[InternationalAlphabet] = converter
+
+	// Get the alphabet files
+	paths, err := filepath.Glob(
+		filepath.Join(folder, "*"+AlphabetFileExtension),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		// Load the alphabet
+		alphabet, err := LoadAlphabetFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if the alphabet name is duplicated
+		if _, ok := converters[alphabet.Name]; ok {
+			return nil, fmt.Errorf("duplicated alphabet: %s", alphabet.Name)
+		}
+
+		// Create the converter
+		converter, err = NewConverter(alphabet)
+		if err != nil {
+			return nil, err
+		}
+		converters[alphabet.Name] = converter
+	}
+	return converters, nil
+}
@@ -0,0 +1,109 @@
+package morse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeAlphabetFile writes an alphabet file in a folder
+func writeAlphabetFile(t *testing.T, folder, filename, content string) string {
+	t.Helper()
+	path := filepath.Join(folder, filename)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+// TestLoadAlphabetFile tests the defaults of the alphabets loaded from a file
+func TestLoadAlphabetFile(t *testing.T) {
+	alphabet, err := LoadAlphabetFile("../../alphabets/american.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alphabet.Name != "american" {
+		t.Errorf("expected the american alphabet, got %s", alphabet.Name)
+	}
+	if alphabet.Separators != (Separators{Letter: "|", Word: DefaultWordSeparator}) {
+		t.Errorf("expected the separators of the file, got %+v", alphabet.Separators)
+	}
+	if code := alphabet.Characters["L"]; code != "_" {
+		t.Errorf("expected the long dash code of L, got %q", code)
+	}
+
+	// Check the name and separators of a file without them
+	path := writeAlphabetFile(t, t.TempDir(), "short.json", `{"characters": {"A": ".-"}}`)
+	alphabet, err = LoadAlphabetFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alphabet.Name != "short" {
+		t.Errorf("expected the name of the file, got %s", alphabet.Name)
+	}
+	if alphabet.Separators != (Separators{Letter: DefaultLetterSeparator, Word: DefaultWordSeparator}) {
+		t.Errorf("expected the default separators, got %+v", alphabet.Separators)
+	}
+}
+
+// TestLoadAlphabetFileErrors tests the alphabet files rejected by the loader
+func TestLoadAlphabetFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid JSON", `{"characters": `},
+		{"multiple characters", `{"characters": {"AB": ".-"}}`},
+		{"empty character", `{"characters": {"": ".-"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeAlphabetFile(t, t.TempDir(), "invalid.json", test.content)
+			if _, err := LoadAlphabetFile(path); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+// TestLoadAlphabetsFolder tests the converters loaded from a folder, with the international alphabet
+func TestLoadAlphabetsFolder(t *testing.T) {
+	converters, err := LoadAlphabetsFolder("../../alphabets")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{InternationalAlphabet, "american"} {
+		if _, ok := converters[name]; !ok {
+			t.Errorf("expected the %s alphabet", name)
+		}
+	}
+
+	// Check a missing folder only has the international alphabet
+	converters, err = LoadAlphabetsFolder(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(converters) != 1 || converters[InternationalAlphabet] == nil {
+		t.Errorf("expected only the international alphabet, got %d", len(converters))
+	}
+}
+
+// TestLoadAlphabetsFolderErrors tests the folders with invalid or duplicated alphabets
+func TestLoadAlphabetsFolderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"duplicated name", `{"name": "international", "characters": {"A": ".-"}}`},
+		{"duplicated code", `{"characters": {"A": ".-", "B": ".-"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			folder := t.TempDir()
+			writeAlphabetFile(t, folder, "invalid.json", test.content)
+			if _, err := LoadAlphabetsFolder(folder); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
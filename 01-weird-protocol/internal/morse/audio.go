@@ -0,0 +1,222 @@
+package morse
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultWPM is the default speed in words per minute
+	DefaultWPM = 20
+
+	// MinWPM is the minimum speed in words per minute
+	MinWPM = 5
+
+	// MaxWPM is the maximum speed in words per minute
+	MaxWPM = 60
+
+	// DefaultFrequency is the default tone frequency in hertz
+	DefaultFrequency = 600
+
+	// MinFrequency is the minimum tone frequency in hertz
+	MinFrequency = 100
+
+	// MaxFrequency is the maximum tone frequency in hertz
+	MaxFrequency = 4000
+
+	// DefaultSampleRate is the default sample rate in hertz
+	DefaultSampleRate = 8000
+
+	// MinSampleRate is the minimum sample rate in hertz
+	MinSampleRate = 8000
+
+	// MaxSampleRate is the maximum sample rate in hertz
+	MaxSampleRate = 48000
+
+	// MaxAudioDuration is the maximum duration of the rendered audio in seconds
+	MaxAudioDuration = 120
+
+	// RampDuration is the duration in seconds of the fade in and fade out of each tone, it avoids the clicks at its edges
+	RampDuration = 0.005
+
+	// Amplitude is the amplitude of the tone, relative to the maximum one
+	Amplitude = 0.8
+
+	// StandardWordUnits is the number of dot units of the standard word, PARIS, with its following word space
+	StandardWordUnits = 50
+
+	// StandardWordSpaceUnits is the number of dot units of the spaces between the letters and after the standard word
+	StandardWordSpaceUnits = 19
+)
+
+// Durations of the code elements and the spaces, in dot units
+const (
+	DotUnits               = 1
+	DashUnits              = 3
+	LongDashUnits          = 6
+	ElementSpaceUnits      = 1
+	IntraCharacterGapUnits = 2
+	LetterSpaceUnits       = 3
+	WordSpaceUnits         = 7
+)
+
+// AudioOptions are the options of the audio rendering
+type AudioOptions struct {
+	// WPM is the character speed in words per minute, using PARIS as the standard word
+	WPM float64
+
+	// FarnsworthWPM is the effective speed in words per minute, the characters are sent at WPM but the spaces between them are stretched. Zero disables the Farnsworth spacing
+	FarnsworthWPM float64
+
+	// Frequency is the tone frequency in hertz
+	Frequency float64
+
+	// SampleRate is the sample rate in hertz
+	SampleRate int
+}
+
+// NewDefaultAudioOptions creates the default audio options
+func NewDefaultAudioOptions() *AudioOptions {
+	return &AudioOptions{
+		WPM:        DefaultWPM,
+		Frequency:  DefaultFrequency,
+		SampleRate: DefaultSampleRate,
+	}
+}
+
+// Validate checks that the audio options are in range
+func (a *AudioOptions) Validate() error {
+	if a.WPM < MinWPM || a.WPM > MaxWPM {
+		return fmt.Errorf(
+			"wpm must be between %d and %d",
+			MinWPM,
+			MaxWPM,
+		)
+	}
+	if a.FarnsworthWPM != 0 && (a.FarnsworthWPM < MinWPM || a.FarnsworthWPM > a.WPM) {
+		return fmt.Errorf(
+			"farnsworth wpm must be between %d and the wpm",
+			MinWPM,
+		)
+	}
+	if a.SampleRate < MinSampleRate || a.SampleRate > MaxSampleRate {
+		return fmt.Errorf(
+			"sample rate must be between %d and %d",
+			MinSampleRate,
+			MaxSampleRate,
+		)
+	}
+	if a.Frequency < MinFrequency || a.Frequency > MaxFrequency || a.Frequency >= float64(a.SampleRate)/2 {
+		return fmt.Errorf(
+			"frequency must be between %d and %d, and below half of the sample rate",
+			MinFrequency,
+			MaxFrequency,
+		)
+	}
+	return nil
+}
+
+// GetSpaceDurations returns the durations in seconds of a dot, and of the spaces between letters and words, which are stretched with the Farnsworth spacing
+func (a *AudioOptions) GetSpaceDurations() (
+	dotDuration, letterSpaceDuration, wordSpaceDuration float64,
+) {
+	// Get the dot duration, the standard word has 50 dot units
+	dotDuration = 60 / (StandardWordUnits * a.WPM)
+	letterSpaceDuration = LetterSpaceUnits * dotDuration
+	wordSpaceDuration = WordSpaceUnits * dotDuration
+
+	// Stretch the spaces to reach the effective speed, the standard word has 19 dot units of spaces between its letters and words
+	if a.FarnsworthWPM != 0 && a.FarnsworthWPM < a.WPM {
+		delay := (60*a.WPM - 37.2*a.FarnsworthWPM) / (a.WPM * a.FarnsworthWPM)
+		letterSpaceDuration = LetterSpaceUnits * delay / StandardWordSpaceUnits
+		wordSpaceDuration = WordSpaceUnits * delay / StandardWordSpaceUnits
+	}
+	return dotDuration, letterSpaceDuration, wordSpaceDuration
+}
+
+// RenderAudio renders the codes of the words to PCM samples. Dots, dashes, long dashes and the gaps inside the codes of some alphabets, written as a space, are supported
+func RenderAudio(words [][]string, options *AudioOptions) ([]int16, error) {
+	// Check the options
+	if options == nil {
+		options = NewDefaultAudioOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	dotDuration, letterSpaceDuration, wordSpaceDuration := options.GetSpaceDurations()
+	sampleRate := float64(options.SampleRate)
+
+	// Get the tones and silences as durations, the positive ones are tones. The audio starts and ends with the silence of a word space
+	var durations []float64
+	var totalDuration float64
+	appendDuration := func(duration float64) {
+		durations = append(durations, duration)
+		totalDuration += math.Abs(duration)
+	}
+	for _, codes := range words {
+		appendDuration(-wordSpaceDuration)
+		for j, code := range codes {
+			if j > 0 {
+				appendDuration(-letterSpaceDuration)
+			}
+			var previous rune
+			for k, element := range code {
+				// The gaps inside the codes replace the space between the elements
+				if k > 0 && element != ' ' && previous != ' ' {
+					appendDuration(-ElementSpaceUnits * dotDuration)
+				}
+				previous = element
+				switch element {
+				case '.':
+					appendDuration(DotUnits * dotDuration)
+				case '-':
+					appendDuration(DashUnits * dotDuration)
+				case '_':
+					appendDuration(LongDashUnits * dotDuration)
+				case ' ':
+					appendDuration(-IntraCharacterGapUnits * dotDuration)
+				default:
+					return nil, fmt.Errorf(
+						"unsupported code element %q in %s",
+						element,
+						code,
+					)
+				}
+			}
+		}
+	}
+
+	appendDuration(-wordSpaceDuration)
+
+	// Check the total duration
+	if totalDuration > MaxAudioDuration {
+		return nil, fmt.Errorf(
+			"the audio would last %.1f seconds, the maximum is %d",
+			totalDuration,
+			MaxAudioDuration,
+		)
+	}
+
+	// Render the samples
+	samples := make([]int16, 0, int(totalDuration*sampleRate)+1)
+	rampSamples := RampDuration * sampleRate
+	for _, duration := range durations {
+		length := int(math.Round(math.Abs(duration) * sampleRate))
+		if duration < 0 {
+			samples = append(samples, make([]int16, length)...)
+			continue
+		}
+		for n := 0; n < length; n++ {
+			// Get the envelope of the tone with a raised cosine ramp at its edges
+			envelope := 1.0
+			if edge := math.Min(float64(n), float64(length-1-n)); edge < rampSamples {
+				envelope = 0.5 - 0.5*math.Cos(math.Pi*edge/rampSamples)
+			}
+			sample := Amplitude * envelope * math.Sin(
+				2*math.Pi*options.Frequency*float64(n)/sampleRate,
+			)
+			samples = append(samples, int16(sample*math.MaxInt16))
+		}
+	}
+	return samples, nil
+}
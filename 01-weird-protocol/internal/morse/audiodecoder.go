@@ -0,0 +1,493 @@
+package morse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// EnvelopeFrameDuration is the duration in seconds of the frames of the envelope
+	EnvelopeFrameDuration = 0.004
+
+	// MinRunDuration is the minimum duration in seconds of a tone or a silence, the shorter ones are considered noise
+	MinRunDuration = 0.01
+
+	// FrequencyDetectionStep is the step in hertz of the search of the tone frequency
+	FrequencyDetectionStep = 10
+
+	// MaxFrequencyDetectionFrames is the maximum number of frames used to search the tone frequency
+	MaxFrequencyDetectionFrames = 256
+
+	// MinSignalToNoise is the minimum ratio between the envelope of the tones and the one of the noise
+	MinSignalToNoise = 3
+
+	// MinSignalLevel is the minimum envelope of the tones, relative to the maximum amplitude
+	MinSignalLevel = 0.01
+
+	// TrackingRate is the weight of each element on the estimation of the dot duration, it allows to follow the changes of speed
+	TrackingRate = 0.2
+
+	// DotDashThreshold is the duration in dot units that separates the dots from the dashes
+	DotDashThreshold = 2
+
+	// DashLongDashThreshold is the duration in dot units that separates the dashes from the long dashes
+	DashLongDashThreshold = 4.5
+
+	// ElementGapThreshold is the duration in dot units that separates the spaces between the elements from the spaces between the letters
+	ElementGapThreshold = 2
+
+	// IntraCharacterElementGapThreshold is the duration in dot units that separates the spaces between the elements from the gaps inside the codes
+	IntraCharacterElementGapThreshold = 1.5
+
+	// IntraCharacterLetterGapThreshold is the duration in dot units that separates the gaps inside the codes from the spaces between the letters
+	IntraCharacterLetterGapThreshold = 2.5
+
+	// WordGapThreshold is the duration in dot units that separates the spaces between the letters from the spaces between the words, when they cannot be told apart by their durations
+	WordGapThreshold = 5
+
+	// MinGapClustersRatio is the minimum ratio between the durations of the spaces between the words and the letters to tell them apart by their durations
+	MinGapClustersRatio = 1.8
+)
+
+type (
+	// AudioDecodeOptions are the options of the detection of the codes of an audio
+	AudioDecodeOptions struct {
+		// LongDashes enables the detection of long dashes, written as an underscore
+		LongDashes bool
+
+		// IntraCharacterGaps enables the detection of the gaps inside the codes, written as a space
+		IntraCharacterGaps bool
+	}
+
+	// DetectedCodes are the codes detected in an audio
+	DetectedCodes struct {
+		// Words are the codes of each word
+		Words [][]string
+
+		// WPM is the detected character speed in words per minute
+		WPM float64
+
+		// Frequency is the detected tone frequency in hertz
+		Frequency float64
+
+		// Confidence is how close the durations of the elements are to the expected ones, from 0 to 1
+		Confidence float64
+	}
+
+	// AudioDecoding is the result of decoding an audio
+	AudioDecoding struct {
+		// Text is the decoded text
+		Text string
+
+		// WPM is the detected character speed in words per minute
+		WPM float64
+
+		// Frequency is the detected tone frequency in hertz
+		Frequency float64
+
+		// Confidence is the confidence of the decoding, from 0 to 1
+		Confidence float64
+	}
+
+	// run is a tone or a silence of the audio
+	run struct {
+		tone     bool
+		duration float64
+	}
+)
+
+// percentile returns the value at the given percentile of the values, from 0 to 1
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// splitClusters splits the values in two clusters by their logarithm, and returns the centers of the clusters
+func splitClusters(values []float64) (low, high float64) {
+	low, high = math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	for i := 0; i < 10 && low < high; i++ {
+		threshold := math.Sqrt(low * high)
+		var lowSum, highSum float64
+		var lowCount, highCount int
+		for _, value := range values {
+			if value < threshold {
+				lowSum += math.Log(value)
+				lowCount++
+			} else {
+				highSum += math.Log(value)
+				highCount++
+			}
+		}
+		low, high = math.Exp(lowSum/float64(lowCount)), math.Exp(highSum/float64(highCount))
+	}
+	return low, high
+}
+
+// getScore returns how close a duration in dot units is to the expected one, from 0 to 1
+func getScore(units, expectedUnits float64) float64 {
+	return math.Max(0, 1-math.Abs(math.Log(units/expectedUnits))/math.Ln2)
+}
+
+// DetectFrequency detects the tone frequency, searching the frequency with the highest power in the loudest frames
+func DetectFrequency(samples []float64, frameSize, sampleRate int) float64 {
+	// Get the energy of the frames
+	energies := make([]float64, 0, len(samples)/frameSize)
+	for start := 0; start+frameSize <= len(samples); start += frameSize {
+		var energy float64
+		for _, sample := range samples[start : start+frameSize] {
+			energy += sample * sample
+		}
+		energies = append(energies, energy)
+	}
+	if len(energies) == 0 {
+		return 0
+	}
+
+	// Get the frames with half of the peak energy, spread along the audio
+	threshold := percentile(energies, 0.99) / 2
+	var loudFrames []int
+	for i, energy := range energies {
+		if energy >= threshold {
+			loudFrames = append(loudFrames, i)
+		}
+	}
+	if step := len(loudFrames) / MaxFrequencyDetectionFrames; step > 1 {
+		spreadFrames := make([]int, 0, MaxFrequencyDetectionFrames)
+		for i := 0; i < len(loudFrames); i += step {
+			spreadFrames = append(spreadFrames, loudFrames[i])
+		}
+		loudFrames = spreadFrames
+	}
+
+	// Search the frequency with the highest power
+	var detectedFrequency, highestPower float64
+	for frequency := float64(MinFrequency); frequency <= MaxFrequency && frequency < float64(sampleRate)/2; frequency += FrequencyDetectionStep {
+		var power float64
+		step := 2 * math.Pi * frequency / float64(sampleRate)
+		for _, frame := range loudFrames {
+			var inPhase, quadrature float64
+			for n, sample := range samples[frame*frameSize : (frame+1)*frameSize] {
+				inPhase += sample * math.Cos(step*float64(n))
+				quadrature += sample * math.Sin(step*float64(n))
+			}
+			power += inPhase*inPhase + quadrature*quadrature
+		}
+		if power > highestPower {
+			detectedFrequency, highestPower = frequency, power
+		}
+	}
+	return detectedFrequency
+}
+
+// GetEnvelope returns the envelope of the tone of the given frequency for each frame, mixing the samples with the tone to reject the noise of the other frequencies
+func GetEnvelope(samples []float64, frameSize, sampleRate int, frequency float64) []float64 {
+	envelope := make([]float64, 0, len(samples)/frameSize)
+	step := 2 * math.Pi * frequency / float64(sampleRate)
+	for start := 0; start+frameSize <= len(samples); start += frameSize {
+		var inPhase, quadrature float64
+		for n, sample := range samples[start : start+frameSize] {
+			phase := step * float64(start+n)
+			inPhase += sample * math.Cos(phase)
+			quadrature += sample * math.Sin(phase)
+		}
+		envelope = append(envelope, 2*math.Hypot(inPhase, quadrature)/float64(frameSize))
+	}
+
+	// Smooth the envelope with a moving average of three frames
+	smoothed := make([]float64, len(envelope))
+	for i := range envelope {
+		var sum float64
+		var count int
+		for j := max(0, i-1); j <= min(len(envelope)-1, i+1); j++ {
+			sum += envelope[j]
+			count++
+		}
+		smoothed[i] = sum / float64(count)
+	}
+	return smoothed
+}
+
+// getRuns returns the tones and silences of the envelope, using two thresholds to avoid the flickering around them. The short runs are merged with the previous one, and the leading and trailing silences are removed
+func getRuns(envelope []float64, frameDuration float64) ([]run, error) {
+	// Get the thresholds from the levels of the noise and the tones
+	noise := percentile(envelope, 0.2)
+	peak := percentile(envelope, 0.99)
+	if peak < MinSignalLevel || peak < noise*MinSignalToNoise {
+		return nil, fmt.Errorf("no morse code tones were detected")
+	}
+	highThreshold := noise + 0.5*(peak-noise)
+	lowThreshold := noise + 0.3*(peak-noise)
+
+	// Get the runs
+	var runs []run
+	tone := false
+	for _, level := range envelope {
+		if tone && level < lowThreshold {
+			tone = false
+		} else if !tone && level > highThreshold {
+			tone = true
+		}
+
+		// Merge the short runs with the previous one
+		last := len(runs) - 1
+		if last >= 0 && runs[last].tone == tone {
+			runs[last].duration += frameDuration
+			continue
+		}
+		if last >= 1 && runs[last].duration < MinRunDuration {
+			runs[last-1].duration += runs[last].duration + frameDuration
+			runs = runs[:last]
+			continue
+		}
+		runs = append(runs, run{tone: tone, duration: frameDuration})
+	}
+
+	// Remove the leading and trailing silences
+	for len(runs) > 0 && !runs[0].tone {
+		runs = runs[1:]
+	}
+	for len(runs) > 0 && !runs[len(runs)-1].tone {
+		runs = runs[:len(runs)-1]
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no morse code tones were detected")
+	}
+	return runs, nil
+}
+
+// getInitialDotDuration estimates the dot duration from the durations of the tones, or from the shortest silence when all the tones have a similar duration
+func getInitialDotDuration(runs []run) float64 {
+	var tones []float64
+	shortestSilence := math.Inf(1)
+	for _, r := range runs {
+		if r.tone {
+			tones = append(tones, r.duration)
+		} else {
+			shortestSilence = math.Min(shortestSilence, r.duration)
+		}
+	}
+
+	// Check if there are dots and dashes
+	low, high := splitClusters(tones)
+	if high/low >= DotDashThreshold {
+		return low
+	}
+
+	// Check if all the tones are dashes, comparing them with the spaces between the elements
+	if low/shortestSilence >= DotDashThreshold {
+		return low / DashUnits
+	}
+	return low
+}
+
+// correctBias corrects the runs for the detection thresholds, which shorten the tones and lengthen the silences by the same duration. It is estimated from the dots and the spaces between the elements
+func correctBias(runs []run, dotDuration float64) {
+	var tonesSum, gapsSum float64
+	var tonesCount, gapsCount int
+	for _, r := range runs {
+		if r.duration/dotDuration >= DotDashThreshold {
+			continue
+		}
+		if r.tone {
+			tonesSum += r.duration
+			tonesCount++
+		} else {
+			gapsSum += r.duration
+			gapsCount++
+		}
+	}
+	if tonesCount == 0 || gapsCount == 0 {
+		return
+	}
+
+	// Get the bias, it cannot be greater than half of a dot
+	bias := (gapsSum/float64(gapsCount) - tonesSum/float64(tonesCount)) / 2
+	bias = math.Max(0, math.Min(bias, dotDuration/2))
+	for i := range runs {
+		if runs[i].tone {
+			runs[i].duration += bias
+		} else {
+			runs[i].duration -= bias
+		}
+	}
+}
+
+// DetectCodes detects the morse codes of an audio. The dot duration is estimated from the durations of the tones and is updated with each element, so the speed can change along the audio
+func DetectCodes(
+	samples []float64,
+	sampleRate int,
+	options *AudioDecodeOptions,
+) (*DetectedCodes, error) {
+	if options == nil {
+		options = &AudioDecodeOptions{}
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate %d", sampleRate)
+	}
+
+	// Get the envelope of the tone
+	frameSize := max(1, int(EnvelopeFrameDuration*float64(sampleRate)))
+	frameDuration := float64(frameSize) / float64(sampleRate)
+	frequency := DetectFrequency(samples, frameSize, sampleRate)
+	if frequency < MinFrequency || frequency > MaxFrequency {
+		return nil, fmt.Errorf("no morse code tones were detected")
+	}
+	envelope := GetEnvelope(samples, frameSize, sampleRate, frequency)
+	if len(envelope) == 0 {
+		return nil, fmt.Errorf("the audio is empty")
+	}
+
+	// Get the tones and silences
+	runs, err := getRuns(envelope, frameDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	// Correct the durations of the runs
+	correctBias(runs, getInitialDotDuration(runs))
+
+	// Get the thresholds of the spaces between the elements and the letters, the gaps inside the codes are between them
+	elementGapThreshold := float64(ElementGapThreshold)
+	letterGapThreshold := float64(ElementGapThreshold)
+	if options.IntraCharacterGaps {
+		elementGapThreshold = IntraCharacterElementGapThreshold
+		letterGapThreshold = IntraCharacterLetterGapThreshold
+	}
+
+	// Classify the tones and the spaces between the elements, tracking the dot duration
+	dotDuration := getInitialDotDuration(runs)
+	elements := make([]string, len(runs))
+	gapUnits := make([]float64, len(runs))
+	var dotDurationsSum, scoresSum float64
+	var tonesCount, scoresCount int
+	for i, r := range runs {
+		units := r.duration / dotDuration
+		if !r.tone {
+			gapUnits[i] = units
+			if units < elementGapThreshold {
+				scoresSum += getScore(units, ElementSpaceUnits)
+				scoresCount++
+				dotDuration += TrackingRate * (r.duration/ElementSpaceUnits - dotDuration)
+			}
+			continue
+		}
+
+		// Get the element and its expected duration
+		element, expectedUnits := ".", float64(DotUnits)
+		if units >= DashLongDashThreshold && options.LongDashes {
+			element, expectedUnits = "_", LongDashUnits
+		} else if units >= DotDashThreshold {
+			element, expectedUnits = "-", DashUnits
+		}
+		elements[i] = element
+		scoresSum += getScore(units, expectedUnits)
+		scoresCount++
+
+		// Update the dot duration
+		dotDuration += TrackingRate * (r.duration/expectedUnits - dotDuration)
+		dotDurationsSum += dotDuration
+		tonesCount++
+	}
+
+	// Get the threshold between the spaces of the letters and the words, they are stretched with the Farnsworth spacing
+	var letterGaps []float64
+	for i, r := range runs {
+		if !r.tone && gapUnits[i] >= letterGapThreshold {
+			letterGaps = append(letterGaps, gapUnits[i])
+		}
+	}
+	wordGapThreshold := float64(WordGapThreshold)
+	if len(letterGaps) > 0 {
+		if low, high := splitClusters(letterGaps); high/low >= MinGapClustersRatio {
+			wordGapThreshold = math.Sqrt(low * high)
+		}
+	}
+
+	// Group the elements into codes and words
+	var words [][]string
+	var codes []string
+	var code strings.Builder
+	for i, r := range runs {
+		switch {
+		case r.tone:
+			code.WriteString(elements[i])
+		case gapUnits[i] < elementGapThreshold:
+		case gapUnits[i] < letterGapThreshold:
+			code.WriteByte(' ')
+		default:
+			codes = append(codes, code.String())
+			code.Reset()
+			if gapUnits[i] >= wordGapThreshold {
+				words = append(words, codes)
+				codes = nil
+			}
+		}
+	}
+	codes = append(codes, code.String())
+	words = append(words, codes)
+
+	return &DetectedCodes{
+		Words:      words,
+		WPM:        60 / (StandardWordUnits * dotDurationsSum / float64(tonesCount)),
+		Frequency:  frequency,
+		Confidence: scoresSum / float64(scoresCount),
+	}, nil
+}
+
+// DecodeAudio decodes the morse code of an audio to text. The confidence of the detected codes is reduced by the ones that are not in the alphabet
+func (c *Converter) DecodeAudio(
+	samples []float64,
+	sampleRate int,
+	options *Options,
+) (*AudioDecoding, error) {
+	// Get the separators
+	separators, err := c.GetSeparators(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check which code elements are used by the alphabet
+	var audioOptions AudioDecodeOptions
+	for code := range c.codeToCharacter {
+		audioOptions.LongDashes = audioOptions.LongDashes || strings.Contains(code, "_")
+		audioOptions.IntraCharacterGaps = audioOptions.IntraCharacterGaps || strings.Contains(code, " ")
+	}
+
+	// Detect the codes
+	detectedCodes, err := DetectCodes(samples, sampleRate, &audioOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Join the codes with the separators and count the known ones
+	var knownCodes, totalCodes int
+	joinedWords := make([]string, len(detectedCodes.Words))
+	for i, codes := range detectedCodes.Words {
+		for _, code := range codes {
+			if c.HasCode(code) {
+				knownCodes++
+			}
+			totalCodes++
+		}
+		joinedWords[i] = strings.Join(codes, separators.Letter)
+	}
+
+	// Decode the codes
+	text, err := c.Decode(strings.Join(joinedWords, separators.Word), options)
+	if err != nil {
+		return nil, err
+	}
+	return &AudioDecoding{
+		Text:       text,
+		WPM:        math.Round(detectedCodes.WPM*10) / 10,
+		Frequency:  math.Round(detectedCodes.Frequency),
+		Confidence: math.Round(detectedCodes.Confidence*float64(knownCodes)/float64(totalCodes)*100) / 100,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package morse
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// renderWAV renders the text to a WAV file with white noise of the given level, relative to the maximum amplitude
+func renderWAV(
+	t *testing.T,
+	converter *Converter,
+	text string,
+	options *AudioOptions,
+	noise float64,
+) []byte {
+	t.Helper()
+	words, err := converter.EncodeWords(text, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	samples, err := RenderAudio(words, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Add the noise with a fixed seed, so the test is repeatable
+	random := rand.New(rand.NewPCG(1, 2))
+	for i, sample := range samples {
+		noisy := float64(sample) + noise*math.MaxInt16*random.NormFloat64()
+		samples[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, noisy)))
+	}
+	return EncodeWAV(samples, options.SampleRate)
+}
+
+// TestDecodeAudio tests that the audio rendered at several speeds and with noise is decoded back to its text
+func TestDecodeAudio(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter func(t *testing.T) *Converter
+		text      string
+		options   AudioOptions
+		noise     float64
+	}{
+		{"default", newTestConverter, "SOS HELP", AudioOptions{WPM: DefaultWPM, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}, 0},
+		{"slow", newTestConverter, "PARIS 73", AudioOptions{WPM: 8, Frequency: 500, SampleRate: DefaultSampleRate}, 0},
+		{"fast", newTestConverter, "CQ CQ DE EA1", AudioOptions{WPM: 40, Frequency: 800, SampleRate: 16000}, 0},
+		{"farnsworth", newTestConverter, "THE QUICK FOX", AudioOptions{WPM: 20, FarnsworthWPM: 10, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}, 0},
+		{"very slow farnsworth", newTestConverter, "MORSE CODE", AudioOptions{WPM: 18, FarnsworthWPM: 5, Frequency: 700, SampleRate: DefaultSampleRate}, 0},
+		{"noise", newTestConverter, "SOS HELP", AudioOptions{WPM: DefaultWPM, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}, 0.3},
+		{"noise and farnsworth", newTestConverter, "THE QUICK FOX", AudioOptions{WPM: 25, FarnsworthWPM: 12, Frequency: 900, SampleRate: 22050}, 0.3},
+		{"american", newAmericanConverter, "HELLO COY 0", AudioOptions{WPM: 15, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}, 0},
+		{"american with noise", newAmericanConverter, "HELLO COY 0", AudioOptions{WPM: 15, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}, 0.2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter := test.converter(t)
+			wav := renderWAV(t, converter, test.text, &test.options, test.noise)
+			samples, sampleRate, err := DecodeWAV(wav)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			decoding, err := converter.DecodeAudio(samples, sampleRate, &Options{Strict: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decoding.Text != test.text {
+				t.Errorf("expected %q, got %q", test.text, decoding.Text)
+			}
+			if math.Abs(decoding.WPM-test.options.WPM) > test.options.WPM*0.15 {
+				t.Errorf("expected about %.0f wpm, got %.1f", test.options.WPM, decoding.WPM)
+			}
+			if math.Abs(decoding.Frequency-test.options.Frequency) > test.options.Frequency*0.05 {
+				t.Errorf("expected about %.0f Hz, got %.0f", test.options.Frequency, decoding.Frequency)
+			}
+		})
+	}
+}
+
+// TestDecodeAudioSpeedChange tests that the audio whose speed changes along it is decoded, following the dot duration
+func TestDecodeAudioSpeedChange(t *testing.T) {
+	converter := newTestConverter(t)
+	var samples []float64
+	for _, wpm := range []float64{15, 18, 22} {
+		options := &AudioOptions{WPM: wpm, Frequency: DefaultFrequency, SampleRate: DefaultSampleRate}
+		wavSamples, _, err := DecodeWAV(renderWAV(t, converter, "PARIS", options, 0.1))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		samples = append(samples, wavSamples...)
+		samples = append(samples, make([]float64, int(WordSpaceUnits*60/(StandardWordUnits*wpm)*DefaultSampleRate))...)
+	}
+	decoding, err := converter.DecodeAudio(samples, DefaultSampleRate, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoding.Text != "PARIS PARIS PARIS" {
+		t.Errorf("expected the three words, got %q", decoding.Text)
+	}
+}
+
+// TestDecodeAudioErrors tests the audios without morse code tones
+func TestDecodeAudioErrors(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	noise := make([]float64, DefaultSampleRate)
+	for i := range noise {
+		noise[i] = 0.3 * random.NormFloat64()
+	}
+	tests := []struct {
+		name       string
+		samples    []float64
+		sampleRate int
+	}{
+		{"silence", make([]float64, DefaultSampleRate), DefaultSampleRate},
+		{"empty", nil, DefaultSampleRate},
+		{"noise", noise, DefaultSampleRate},
+		{"invalid sample rate", noise, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newTestConverter(t).DecodeAudio(test.samples, test.sampleRate, nil); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
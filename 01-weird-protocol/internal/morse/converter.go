@@ -0,0 +1,319 @@
+package morse
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	// Options are the options of a conversion
+	Options struct {
+		// Separators are the separators of the letters and words, the empty ones default to the separators of the alphabet
+		Separators Separators
+
+		// Prosigns enables the procedural signals, written as their name between angle brackets, e.g. <END>
+		Prosigns bool
+
+		// Strict reports the unknown characters and codes instead of dropping them
+		Strict bool
+	}
+
+	// UnknownCharacter is a character or code that is not in the alphabet
+	UnknownCharacter struct {
+		// Value is the character or code
+		Value string
+
+		// Pos is the position of the character or code in the converted message
+		Pos int
+	}
+
+	// Converter converts text to morse code and morse code to text with an alphabet. It replaces the handler of go-morse, which has fixed separators, no prosigns conversion and silently converts the unknown characters to empty codes, so only its international tables are used
+	Converter struct {
+		alphabet        *Alphabet
+		characterToCode map[rune]string
+		codeToCharacter map[string]rune
+		prosignToCode   map[string]string
+		codeToProsign   map[string]string
+	}
+)
+
+// UnknownCharactersError is the error for the unknown characters or codes of a strict conversion
+func UnknownCharactersError(unknownCharacters []UnknownCharacter) error {
+	descriptions := make([]string, len(unknownCharacters))
+	for i, unknownCharacter := range unknownCharacters {
+		descriptions[i] = fmt.Sprintf(
+			"'%s' at position %d",
+			unknownCharacter.Value,
+			unknownCharacter.Pos,
+		)
+	}
+	return fmt.Errorf(
+		"unknown characters: %s",
+		strings.Join(descriptions, ", "),
+	)
+}
+
+// NewConverter creates a new converter for the given alphabet
+func NewConverter(alphabet *Alphabet) (*Converter, error) {
+	// Check if the alphabet is nil
+	if alphabet == nil {
+		return nil, fmt.Errorf("alphabet is nil")
+	}
+
+	converter := &Converter{
+		alphabet:        alphabet,
+		characterToCode: make(map[rune]string),
+		codeToCharacter: make(map[string]rune),
+		prosignToCode:   make(map[string]string),
+		codeToProsign:   make(map[string]string),
+	}
+
+	// Populate the characters maps
+	for characterStr, code := range alphabet.Characters {
+		character, _ := utf8.DecodeRuneInString(characterStr)
+		if code == "" {
+			return nil, fmt.Errorf(
+				"empty code for character %q in alphabet %s",
+				character,
+				alphabet.Name,
+			)
+		}
+		if _, ok := converter.codeToCharacter[code]; ok {
+			return nil, fmt.Errorf(
+				"code already exists in alphabet %s: %s",
+				alphabet.Name,
+				code,
+			)
+		}
+		converter.characterToCode[character] = code
+		converter.codeToCharacter[code] = character
+	}
+
+	// Populate the prosigns maps, their codes can be the same as the code of a character
+	for prosign, code := range alphabet.Prosigns {
+		if code == "" {
+			return nil, fmt.Errorf(
+				"empty code for prosign %s in alphabet %s",
+				prosign,
+				alphabet.Name,
+			)
+		}
+		if _, ok := converter.codeToProsign[code]; ok {
+			return nil, fmt.Errorf(
+				"prosign code already exists in alphabet %s: %s",
+				alphabet.Name,
+				code,
+			)
+		}
+		converter.prosignToCode[strings.ToUpper(prosign)] = code
+		converter.codeToProsign[code] = strings.ToUpper(prosign)
+	}
+	return converter, nil
+}
+
+// GetAlphabet returns the alphabet of the converter
+func (c *Converter) GetAlphabet() *Alphabet {
+	return c.alphabet
+}
+
+// HasCode checks if the code is the code of a character or a prosign of the alphabet
+func (c *Converter) HasCode(code string) bool {
+	if _, ok := c.codeToCharacter[code]; ok {
+		return true
+	}
+	_, ok := c.codeToProsign[code]
+	return ok
+}
+
+// EncodeCharacter encodes a character, trying with its uppercase if it is not in the alphabet
+func (c *Converter) EncodeCharacter(character rune) (string, bool) {
+	code, ok := c.characterToCode[character]
+	if !ok {
+		code, ok = c.characterToCode[unicode.ToUpper(character)]
+	}
+	return code, ok
+}
+
+// EncodeProsign encodes a prosign by its name
+func (c *Converter) EncodeProsign(prosign string) (string, bool) {
+	code, ok := c.prosignToCode[strings.ToUpper(prosign)]
+	return code, ok
+}
+
+// DecodeCode decodes the code of a character, or of a prosign if they are enabled. When a prosign has the same code as a character, it is decoded as the character
+func (c *Converter) DecodeCode(code string, prosigns bool) (string, bool) {
+	if character, ok := c.codeToCharacter[code]; ok {
+		return string(character), true
+	}
+	if prosign, ok := c.codeToProsign[code]; ok && prosigns {
+		return "<" + prosign + ">", true
+	}
+	return "", false
+}
+
+// GetSeparators returns the separators of a conversion, and checks that they are not part of any code
+func (c *Converter) GetSeparators(options *Options) (*Separators, error) {
+	// Get the separators, defaulting to the separators of the alphabet
+	separators := c.alphabet.Separators
+	if options != nil {
+		if options.Separators.Letter != "" {
+			separators.Letter = options.Separators.Letter
+		}
+		if options.Separators.Word != "" {
+			separators.Word = options.Separators.Word
+		}
+	}
+	if separators.Letter == separators.Word {
+		return nil, fmt.Errorf(
+			"the letter and word separators must be different: %q",
+			separators.Letter,
+		)
+	}
+
+	// Check that the separators are not part of any code
+	for _, separator := range []string{separators.Letter, separators.Word} {
+		for code := range c.codeToCharacter {
+			if strings.Contains(code, separator) {
+				return nil, fmt.Errorf(
+					"separator %q is part of the code %s",
+					separator,
+					code,
+				)
+			}
+		}
+	}
+	return &separators, nil
+}
+
+// EncodeWords encodes the text to the morse codes of each of its words
+func (c *Converter) EncodeWords(text string, options *Options) (
+	[][]string,
+	error,
+) {
+	prosigns := options != nil && options.Prosigns
+	strict := options != nil && options.Strict
+
+	// Encode the text, a word ends with any spacing character
+	var words [][]string
+	var codes []string
+	var unknownCharacters []UnknownCharacter
+	for pos := 0; pos < len(text); {
+		character, size := utf8.DecodeRuneInString(text[pos:])
+
+		// Check if the character is a space
+		if unicode.IsSpace(character) {
+			if len(codes) > 0 {
+				words = append(words, codes)
+				codes = nil
+			}
+			pos += size
+			continue
+		}
+
+		// Check if it is a prosign
+		if prosigns && character == '<' {
+			if end := strings.IndexByte(text[pos:], '>'); end != -1 {
+				if code, ok := c.EncodeProsign(text[pos+1 : pos+end]); ok {
+					codes = append(codes, code)
+					pos += end + 1
+					continue
+				}
+			}
+		}
+
+		// Get the code of the character
+		if code, ok := c.EncodeCharacter(character); ok {
+			codes = append(codes, code)
+		} else {
+			unknownCharacters = append(
+				unknownCharacters,
+				UnknownCharacter{Value: string(character), Pos: pos},
+			)
+		}
+		pos += size
+	}
+	if len(codes) > 0 {
+		words = append(words, codes)
+	}
+
+	// Check if there are unknown characters on a strict conversion
+	if strict && len(unknownCharacters) > 0 {
+		return nil, UnknownCharactersError(unknownCharacters)
+	}
+	return words, nil
+}
+
+// Encode encodes the text to morse code
+func (c *Converter) Encode(text string, options *Options) (string, error) {
+	// Get the separators
+	separators, err := c.GetSeparators(options)
+	if err != nil {
+		return "", err
+	}
+
+	// Get the codes of the words
+	words, err := c.EncodeWords(text, options)
+	if err != nil {
+		return "", err
+	}
+
+	// Join the codes with the separators
+	joinedWords := make([]string, len(words))
+	for i, codes := range words {
+		joinedWords[i] = strings.Join(codes, separators.Letter)
+	}
+	return strings.Join(joinedWords, separators.Word), nil
+}
+
+// Decode decodes the morse code to text
+func (c *Converter) Decode(morseCode string, options *Options) (string, error) {
+	// Get the separators
+	separators, err := c.GetSeparators(options)
+	if err != nil {
+		return "", err
+	}
+	prosigns := options != nil && options.Prosigns
+	strict := options != nil && options.Strict
+
+	// Decode the morse code word by word
+	var words []string
+	var unknownCharacters []UnknownCharacter
+	wordPos := 0
+	for _, word := range strings.Split(morseCode, separators.Word) {
+		var characters strings.Builder
+		codePos := wordPos
+		for _, code := range strings.Split(word, separators.Letter) {
+			// Get the code without the surrounding spaces
+			trimmedCode := strings.TrimSpace(code)
+			if trimmedCode != "" {
+				if character, ok := c.DecodeCode(trimmedCode, prosigns); ok {
+					characters.WriteString(character)
+				} else {
+					unknownCharacters = append(
+						unknownCharacters,
+						UnknownCharacter{
+							Value: trimmedCode,
+							Pos: codePos + strings.Index(
+								code,
+								trimmedCode,
+							),
+						},
+					)
+				}
+			}
+			codePos += len(code) + len(separators.Letter)
+		}
+		if characters.Len() > 0 {
+			words = append(words, characters.String())
+		}
+		wordPos += len(word) + len(separators.Word)
+	}
+
+	// Check if there are unknown codes on a strict conversion
+	if strict && len(unknownCharacters) > 0 {
+		return "", UnknownCharactersError(unknownCharacters)
+	}
+	return strings.Join(words, " "), nil
+}
@@ -0,0 +1,184 @@
+package morse
+
+import (
+	"strings"
+	"testing"
+)
+
+// newAmericanConverter creates a converter with the American alphabet of the alphabets folder, its codes have spaces and long dashes
+func newAmericanConverter(t *testing.T) *Converter {
+	t.Helper()
+	alphabet, err := LoadAlphabetFile("../../alphabets/american.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	converter, err := NewConverter(alphabet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return converter
+}
+
+// TestConverterEncode tests the conversions of text to morse code of each alphabet
+func TestConverterEncode(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter func(t *testing.T) *Converter
+		text      string
+		options   *Options
+		expected  string
+	}{
+		{"international", newTestConverter, "SOS help", nil, "... --- ... / .... . .-.. .--."},
+		{"international with line breaks", newTestConverter, " sos\n\tsos ", nil, "... --- ... / ... --- ..."},
+		{"international unknown characters", newTestConverter, "s~o", nil, "... ---"},
+		{"american", newAmericanConverter, "hello 0", nil, "....|.|_|_|. . / __"},
+		{"prosign", newTestConverter, "<END>", &Options{Prosigns: true}, "...-.-"},
+		{"prosign with a space", newTestConverter, "<distress signal>", &Options{Prosigns: true}, "...---..."},
+		{"prosign disabled", newTestConverter, "<END>", nil, ". -. -.."},
+		{"unknown prosign", newTestConverter, "<AB>", &Options{Prosigns: true}, ".- -..."},
+		{"custom separators", newTestConverter, "sos sos", &Options{Separators: Separators{Letter: ",", Word: ";"}}, "...,---,...;...,---,..."},
+		{"custom letter separator", newTestConverter, "sos sos", &Options{Separators: Separators{Letter: "|"}}, "...|---|... / ...|---|..."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := test.converter(t).Encode(test.text, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if encoded != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, encoded)
+			}
+		})
+	}
+}
+
+// TestConverterDecode tests the conversions of morse code to text of each alphabet
+func TestConverterDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter func(t *testing.T) *Converter
+		morseCode string
+		options   *Options
+		expected  string
+	}{
+		{"international", newTestConverter, "... --- ... / .... . .-.. .--.", nil, "SOS HELP"},
+		{"international extra spaces", newTestConverter, " ...  ---  ... ", nil, "SOS"},
+		{"international unknown codes", newTestConverter, "... ........ ---", nil, "SO"},
+		{"american", newAmericanConverter, "....|.|_|_|. . / __", nil, "HELLO 0"},
+		{"american codes with spaces", newAmericanConverter, ".. .|. .|.. ..", nil, "COY"},
+		{"prosign", newTestConverter, "...-.- / ...---...", &Options{Prosigns: true}, "<END> <DISTRESS SIGNAL>"},
+		{"prosign disabled", newTestConverter, "... ...-.-", nil, "S"},
+		{"prosign with the code of a character", newTestConverter, "-.-", &Options{Prosigns: true}, "K"},
+		{"custom separators", newTestConverter, "...,---,...;...,---,...", &Options{Separators: Separators{Letter: ",", Word: ";"}}, "SOS SOS"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := test.converter(t).Decode(test.morseCode, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decoded != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, decoded)
+			}
+		})
+	}
+}
+
+// TestConverterErrors tests the strict conversions with unknown characters or codes, and the invalid separators
+func TestConverterErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter func(t *testing.T) *Converter
+		convert   func(converter *Converter) (string, error)
+		expected  string
+	}{
+		{
+			"strict encode",
+			newTestConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Encode("hi~ <AB>", &Options{Strict: true})
+			},
+			"unknown characters: '~' at position 2, '<' at position 4, '>' at position 7",
+		},
+		{
+			"strict decode",
+			newTestConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Decode("... ........ --- / ..--..--", &Options{Strict: true})
+			},
+			"unknown characters: '........' at position 4, '..--..--' at position 19",
+		},
+		{
+			"strict decode with the prosigns disabled",
+			newTestConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Decode("...-.-", &Options{Strict: true})
+			},
+			"unknown characters: '...-.-' at position 0",
+		},
+		{
+			"strict decode with custom separators",
+			newAmericanConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Decode("....|. . .", &Options{Strict: true})
+			},
+			"unknown characters: '. . .' at position 5",
+		},
+		{
+			"equal separators",
+			newTestConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Encode("sos", &Options{Separators: Separators{Letter: "/", Word: "/"}})
+			},
+			"the letter and word separators must be different",
+		},
+		{
+			"separator in a code",
+			newTestConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Decode("...", &Options{Separators: Separators{Letter: "-"}})
+			},
+			"separator \"-\" is part of the code",
+		},
+		{
+			"space separator in a code",
+			newAmericanConverter,
+			func(converter *Converter) (string, error) {
+				return converter.Encode("co", &Options{Separators: Separators{Letter: " ", Word: "|"}})
+			},
+			"separator \" \" is part of the code",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converted, err := test.convert(test.converter(t))
+			if err == nil {
+				t.Fatalf("expected an error, got %q", converted)
+			}
+			if !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, err)
+			}
+		})
+	}
+}
+
+// TestNewConverterErrors tests the alphabets rejected by the converter
+func TestNewConverterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet *Alphabet
+	}{
+		{"nil", nil},
+		{"empty code", &Alphabet{Name: "test", Characters: map[string]string{"A": ""}}},
+		{"duplicated code", &Alphabet{Name: "test", Characters: map[string]string{"A": ".-", "B": ".-"}}},
+		{"empty prosign code", &Alphabet{Name: "test", Prosigns: map[string]string{"END": ""}}},
+		{"duplicated prosign code", &Alphabet{Name: "test", Prosigns: map[string]string{"END": ".-.-", "OVER": ".-.-"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewConverter(test.alphabet); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package morse
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPendingSize is the maximum size in bytes of the morse code of a decoder stream waiting for its separator, the codes are much shorter
+const MaxPendingSize = 1024
+
+// ErrPendingTooLarge is the error for the morse code of a decoder stream that exceeds the maximum size without a separator
+var ErrPendingTooLarge = fmt.Errorf(
+	"morse code without a separator exceeds the maximum of %d bytes",
+	MaxPendingSize,
+)
+
+type (
+	// Stream converts the chunks of a message as they arrive
+	Stream interface {
+		Write(chunk string) (string, error)
+		Flush() (string, error)
+	}
+
+	// EncoderStream encodes text to morse code as it arrives in chunks, keeping the separators between the chunks
+	EncoderStream struct {
+		converter  *Converter
+		separators *Separators
+		prosigns   bool
+		strict     bool
+
+		// pending is the end of the last chunk that could not be encoded yet, an incomplete character or prosign
+		pending string
+
+		// pos is the position of the pending data in the whole text
+		pos int
+
+		// wroteCode is true if a code was written
+		wroteCode bool
+
+		// spaced is true if there was a space after the last written code
+		spaced bool
+
+		// maxProsignLength is the length of the longest prosign name
+		maxProsignLength int
+	}
+
+	// DecoderStream decodes morse code to text as it arrives in chunks, the codes are decoded once their separator arrives
+	DecoderStream struct {
+		converter  *Converter
+		separators *Separators
+		prosigns   bool
+		strict     bool
+
+		// pending is the morse code that could not be decoded yet
+		pending string
+
+		// pos is the position of the pending morse code in the whole morse code
+		pos int
+
+		// wroteCharacter is true if a character was written
+		wroteCharacter bool
+
+		// spaced is true if there was a word separator after the last written character
+		spaced bool
+	}
+)
+
+// NewEncoderStream creates a new encoder stream
+func (c *Converter) NewEncoderStream(options *Options) (*EncoderStream, error) {
+	// Get the separators
+	separators, err := c.GetSeparators(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the length of the longest prosign name
+	var maxProsignLength int
+	for prosign := range c.prosignToCode {
+		maxProsignLength = max(maxProsignLength, len(prosign))
+	}
+
+	return &EncoderStream{
+		converter:        c,
+		separators:       separators,
+		prosigns:         options != nil && options.Prosigns,
+		strict:           options != nil && options.Strict,
+		maxProsignLength: maxProsignLength,
+	}, nil
+}
+
+// writeCode writes a code with the separator from the last written code
+func (e *EncoderStream) writeCode(builder *strings.Builder, code string) {
+	if e.wroteCode {
+		if e.spaced {
+			builder.WriteString(e.separators.Word)
+		} else {
+			builder.WriteString(e.separators.Letter)
+		}
+	}
+	builder.WriteString(code)
+	e.wroteCode = true
+	e.spaced = false
+}
+
+// encode encodes the pending data and the chunk. When it is not the final chunk, the incomplete characters and prosigns at its end are kept pending
+func (e *EncoderStream) encode(chunk string, final bool) (string, error) {
+	data := e.pending + chunk
+	e.pending = ""
+
+	var builder strings.Builder
+	var unknownCharacters []UnknownCharacter
+	pos := 0
+	for pos < len(data) {
+		// Check if the character is incomplete
+		if !final && !utf8.FullRuneInString(data[pos:]) {
+			break
+		}
+		character, size := utf8.DecodeRuneInString(data[pos:])
+
+		// Check if the character is a space
+		if unicode.IsSpace(character) {
+			e.spaced = e.wroteCode
+			pos += size
+			continue
+		}
+
+		// Check if it is a prosign, waiting for its end if it can still arrive
+		if e.prosigns && character == '<' {
+			end := strings.IndexByte(data[pos:], '>')
+			if end == -1 && !final && len(data)-pos <= e.maxProsignLength+1 {
+				break
+			}
+			if end != -1 {
+				if code, ok := e.converter.EncodeProsign(data[pos+1 : pos+end]); ok {
+					e.writeCode(&builder, code)
+					pos += end + 1
+					continue
+				}
+			}
+		}
+
+		// Get the code of the character
+		if code, ok := e.converter.EncodeCharacter(character); ok {
+			e.writeCode(&builder, code)
+		} else {
+			unknownCharacters = append(
+				unknownCharacters,
+				UnknownCharacter{Value: string(character), Pos: e.pos + pos},
+			)
+		}
+		pos += size
+	}
+
+	// Keep the incomplete data pending
+	e.pending = data[pos:]
+	e.pos += pos
+
+	// Check if there are unknown characters on a strict conversion
+	if e.strict && len(unknownCharacters) > 0 {
+		return builder.String(), UnknownCharactersError(unknownCharacters)
+	}
+	return builder.String(), nil
+}
+
+// Write encodes a chunk of text, returning the morse code of its complete characters
+func (e *EncoderStream) Write(chunk string) (string, error) {
+	return e.encode(chunk, false)
+}
+
+// Flush encodes the pending data at the end of the text
+func (e *EncoderStream) Flush() (string, error) {
+	return e.encode("", true)
+}
+
+// NewDecoderStream creates a new decoder stream
+func (c *Converter) NewDecoderStream(options *Options) (*DecoderStream, error) {
+	// Get the separators
+	separators, err := c.GetSeparators(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecoderStream{
+		converter:  c,
+		separators: separators,
+		prosigns:   options != nil && options.Prosigns,
+		strict:     options != nil && options.Strict,
+	}, nil
+}
+
+// writeCode decodes a code and writes its character, with a space if there was a word separator from the last written character
+func (d *DecoderStream) writeCode(
+	builder *strings.Builder,
+	code string,
+	pos int,
+	unknownCharacters *[]UnknownCharacter,
+) {
+	// Get the code without the surrounding spaces
+	trimmedCode := strings.TrimSpace(code)
+	if trimmedCode == "" {
+		return
+	}
+
+	// Decode the code
+	character, ok := d.converter.DecodeCode(trimmedCode, d.prosigns)
+	if !ok {
+		*unknownCharacters = append(
+			*unknownCharacters,
+			UnknownCharacter{
+				Value: trimmedCode,
+				Pos:   pos + strings.Index(code, trimmedCode),
+			},
+		)
+		return
+	}
+	if d.spaced {
+		builder.WriteByte(' ')
+	}
+	builder.WriteString(character)
+	d.wroteCharacter = true
+	d.spaced = false
+}
+
+// decode decodes the codes of the pending morse code and the chunk that are followed by a separator, or all of them if it is the final chunk. The line breaks are word separators, to end the typed lines
+func (d *DecoderStream) decode(chunk string, final bool) (string, error) {
+	chunk = strings.ReplaceAll(chunk, "\r\n", "\n")
+	d.pending += strings.ReplaceAll(chunk, "\n", d.separators.Word)
+
+	var builder strings.Builder
+	var unknownCharacters []UnknownCharacter
+	for {
+		// Get the first separator, the word separator can contain the letter separator
+		letterPos := strings.Index(d.pending, d.separators.Letter)
+		wordPos := strings.Index(d.pending, d.separators.Word)
+		var separatorPos, separatorLength int
+		isWord := false
+		switch {
+		case wordPos != -1 && (letterPos == -1 || wordPos <= letterPos):
+			separatorPos, separatorLength, isWord = wordPos, len(d.separators.Word), true
+		case letterPos != -1:
+			// Wait for more data if the letter separator can be the start of a word separator
+			rest := d.pending[letterPos:]
+			if !final && len(rest) < len(d.separators.Word) && strings.HasPrefix(d.separators.Word, rest) {
+				separatorPos = -1
+				break
+			}
+			separatorPos, separatorLength = letterPos, len(d.separators.Letter)
+		default:
+			separatorPos = -1
+		}
+		if separatorPos == -1 {
+			break
+		}
+
+		// Decode the code before the separator
+		d.writeCode(&builder, d.pending[:separatorPos], d.pos, &unknownCharacters)
+		if isWord {
+			d.spaced = d.wroteCharacter
+		}
+		d.pending = d.pending[separatorPos+separatorLength:]
+		d.pos += separatorPos + separatorLength
+	}
+
+	// Decode the last code, or check if the pending morse code is too large to be a code
+	if final {
+		d.writeCode(&builder, d.pending, d.pos, &unknownCharacters)
+		d.pos += len(d.pending)
+		d.pending = ""
+	} else if len(d.pending) > MaxPendingSize {
+		d.pending = ""
+		return builder.String(), ErrPendingTooLarge
+	}
+
+	// Check if there are unknown codes on a strict conversion
+	if d.strict && len(unknownCharacters) > 0 {
+		return builder.String(), UnknownCharactersError(unknownCharacters)
+	}
+	return builder.String(), nil
+}
+
+// Write decodes a chunk of morse code, returning the characters of its complete codes
+func (d *DecoderStream) Write(chunk string) (string, error) {
+	return d.decode(chunk, false)
+}
+
+// Flush decodes the pending morse code at the end of the morse code
+func (d *DecoderStream) Flush() (string, error) {
+	return d.decode("", true)
+}
@@ -0,0 +1,72 @@
+package morse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestConverter creates a converter with the international alphabet
+func newTestConverter(t *testing.T) *Converter {
+	t.Helper()
+	converter, err := NewConverter(NewInternationalAlphabet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return converter
+}
+
+// TestDecoderStream tests that the codes split across chunks are decoded once their separator arrives
+func TestDecoderStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected string
+	}{
+		{"single chunk", []string{"... --- ..."}, "SOS"},
+		{"split code", []string{"..", ". --", "- ..."}, "SOS"},
+		{"words", []string{"... --- ... / ", "... --- ..."}, "SOS SOS"},
+		{"line breaks", []string{"...\n", "-"}, "S T"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream, err := newTestConverter(t).NewDecoderStream(nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var output strings.Builder
+			for _, chunk := range test.chunks {
+				decoded, err := stream.Write(chunk)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				output.WriteString(decoded)
+			}
+			decoded, err := stream.Flush()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			output.WriteString(decoded)
+			if output.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, output.String())
+			}
+		})
+	}
+}
+
+// TestDecoderStreamPendingTooLarge tests that the morse code without separators is rejected once it exceeds the maximum
+func TestDecoderStreamPendingTooLarge(t *testing.T) {
+	stream, err := newTestConverter(t).NewDecoderStream(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chunk := strings.Repeat(".", MaxPendingSize/4)
+	for i := 0; i < 4; i++ {
+		if _, err = stream.Write(chunk); err != nil {
+			t.Fatalf("unexpected error at the maximum: %v", err)
+		}
+	}
+	if _, err = stream.Write("."); !errors.Is(err, ErrPendingTooLarge) {
+		t.Errorf("expected ErrPendingTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package morse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	// WAVHeaderSize is the size of the header of a canonical WAV file
+	WAVHeaderSize = 44
+
+	// WAVPCMFormat is the audio format of the uncompressed PCM samples
+	WAVPCMFormat = 1
+
+	// WAVExtensibleFormat is the audio format of the files whose format is in an extension of the format chunk
+	WAVExtensibleFormat = 0xFFFE
+
+	// WAVBitsPerSample is the number of bits of each sample
+	WAVBitsPerSample = 16
+)
+
+// EncodeWAV encodes mono 16-bit PCM samples as a WAV file
+func EncodeWAV(samples []int16, sampleRate int) []byte {
+	dataSize := len(samples) * WAVBitsPerSample / 8
+	buffer := bytes.NewBuffer(make([]byte, 0, WAVHeaderSize+dataSize))
+
+	// Write the RIFF header
+	buffer.WriteString("RIFF")
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(WAVHeaderSize-8+dataSize))
+	buffer.WriteString("WAVE")
+
+	// Write the format chunk
+	buffer.WriteString("fmt ")
+	_ = binary.Write(
+		buffer, binary.LittleEndian, struct {
+			Size          uint32
+			AudioFormat   uint16
+			Channels      uint16
+			SampleRate    uint32
+			ByteRate      uint32
+			BlockAlign    uint16
+			BitsPerSample uint16
+		}{
+			Size:          16,
+			AudioFormat:   WAVPCMFormat,
+			Channels:      1,
+			SampleRate:    uint32(sampleRate),
+			ByteRate:      uint32(sampleRate * WAVBitsPerSample / 8),
+			BlockAlign:    WAVBitsPerSample / 8,
+			BitsPerSample: WAVBitsPerSample,
+		},
+	)
+
+	// Write the data chunk
+	buffer.WriteString("data")
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(dataSize))
+	_ = binary.Write(buffer, binary.LittleEndian, samples)
+	return buffer.Bytes()
+}
+
+// DecodeWAV decodes the samples of an uncompressed PCM WAV file, with 8 or 16 bits per sample. The channels are mixed, and the samples are scaled to the range [-1, 1]
+func DecodeWAV(data []byte) (samples []float64, sampleRate int, err error) {
+	// Check the RIFF header
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("invalid WAV file, missing the RIFF header")
+	}
+
+	// Read the chunks, they are padded to an even size
+	var channels, bitsPerSample int
+	var formatFound bool
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		if size > len(data)-pos {
+			size = len(data) - pos
+		}
+		chunk := data[pos : pos+size]
+		pos += size + size%2
+
+		switch id {
+		case "fmt ":
+			// Read the format, the extensible format is accepted for PCM samples
+			if len(chunk) < 16 {
+				return nil, 0, fmt.Errorf("invalid WAV format chunk")
+			}
+			audioFormat := binary.LittleEndian.Uint16(chunk[0:2])
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			if audioFormat != WAVPCMFormat && audioFormat != WAVExtensibleFormat {
+				return nil, 0, fmt.Errorf(
+					"unsupported WAV audio format %d, expected PCM",
+					audioFormat,
+				)
+			}
+			if bitsPerSample != 8 && bitsPerSample != 16 {
+				return nil, 0, fmt.Errorf(
+					"unsupported WAV bits per sample %d, expected 8 or 16",
+					bitsPerSample,
+				)
+			}
+			if channels == 0 || sampleRate == 0 {
+				return nil, 0, fmt.Errorf("invalid WAV format chunk")
+			}
+			formatFound = true
+		case "data":
+			if !formatFound {
+				return nil, 0, fmt.Errorf("invalid WAV file, the data chunk is before the format chunk")
+			}
+
+			// Mix the channels of each frame
+			frameSize := channels * bitsPerSample / 8
+			samples = make([]float64, 0, len(chunk)/frameSize)
+			for frame := 0; frame+frameSize <= len(chunk); frame += frameSize {
+				var sample float64
+				for channel := 0; channel < channels; channel++ {
+					if bitsPerSample == 8 {
+						sample += (float64(chunk[frame+channel]) - 128) / 128
+					} else {
+						offset := frame + channel*2
+						sample += float64(int16(binary.LittleEndian.Uint16(chunk[offset:offset+2]))) / -math.MinInt16
+					}
+				}
+				samples = append(samples, sample/float64(channels))
+			}
+			return samples, sampleRate, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("invalid WAV file, missing the data chunk")
+}
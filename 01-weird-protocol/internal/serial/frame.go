@@ -0,0 +1,167 @@
+package serial
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// FrameEnd is the SLIP byte that delimits the frames, it is sent before and after each frame so the line noise between frames is dropped
+	FrameEnd = 0xC0
+
+	// FrameEscape is the SLIP byte that escapes the delimiter and itself inside a frame
+	FrameEscape = 0xDB
+
+	// FrameEscapedEnd is the byte that follows the escape byte for a delimiter
+	FrameEscapedEnd = 0xDC
+
+	// FrameEscapedEscape is the byte that follows the escape byte for an escape byte
+	FrameEscapedEscape = 0xDD
+
+	// FrameChecksumSize is the size in bytes of the big-endian CRC-32 of the payload, at the end of each frame
+	FrameChecksumSize = 4
+
+	// MaxFrameSize is the maximum size in bytes of the payload of a frame
+	MaxFrameSize = 1 << 20
+
+	// readBufferSize is the size in bytes of the buffer of the reads of the line
+	readBufferSize = 4096
+)
+
+var (
+	// ErrFrameTooLarge is the error for a frame whose payload exceeds the maximum, it is discarded up to its delimiter
+	ErrFrameTooLarge = fmt.Errorf("frame too large, the maximum is %d bytes", MaxFrameSize)
+
+	// ErrInvalidFrame is the error for a frame with an invalid escape sequence or a wrong checksum
+	ErrInvalidFrame = errors.New("invalid frame")
+
+	// ErrFrameTimeout is the error for a frame interrupted by a silent line, its received bytes are discarded
+	ErrFrameTimeout = errors.New("frame timeout, the line was silent in the middle of a frame")
+)
+
+// EncodeFrame encodes a payload as a SLIP frame, followed by its CRC-32
+func EncodeFrame(payload []byte) []byte {
+	// Append the checksum
+	data := binary.BigEndian.AppendUint32(
+		append(make([]byte, 0, len(payload)+FrameChecksumSize), payload...),
+		crc32.ChecksumIEEE(payload),
+	)
+
+	// Escape the data between the delimiters
+	frame := make([]byte, 0, len(data)+2)
+	frame = append(frame, FrameEnd)
+	for _, b := range data {
+		switch b {
+		case FrameEnd:
+			frame = append(frame, FrameEscape, FrameEscapedEnd)
+		case FrameEscape:
+			frame = append(frame, FrameEscape, FrameEscapedEscape)
+		default:
+			frame = append(frame, b)
+		}
+	}
+	return append(frame, FrameEnd)
+}
+
+// DecodeFrame checks the CRC-32 of the unescaped data of a frame and returns its payload
+func DecodeFrame(data []byte) ([]byte, error) {
+	if len(data) < FrameChecksumSize {
+		return nil, ErrInvalidFrame
+	}
+	payload := data[:len(data)-FrameChecksumSize]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[len(payload):]) {
+		return nil, ErrInvalidFrame
+	}
+	return payload, nil
+}
+
+// FrameReader reads the SLIP frames of a serial line. A read that returns no bytes and no error is a read timeout of the port
+type FrameReader struct {
+	reader     io.Reader
+	buffer     []byte
+	start, end int
+}
+
+// NewFrameReader creates a new reader of the frames of a serial line
+func NewFrameReader(reader io.Reader) *FrameReader {
+	return &FrameReader{reader: reader, buffer: make([]byte, readBufferSize)}
+}
+
+// readByte reads the next byte of the line, it reports if the read timed out without bytes
+func (f *FrameReader) readByte() (b byte, timeout bool, err error) {
+	if f.start == f.end {
+		n, err := f.reader.Read(f.buffer)
+		if n == 0 {
+			return 0, err == nil, err
+		}
+		f.start, f.end = 0, n
+	}
+	b = f.buffer[f.start]
+	f.start++
+	return b, false, nil
+}
+
+// ReadFrame reads the payload of the next frame. The frames too large, invalid or interrupted by a silent line return an error and are discarded, so the next frame can be read after them
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	var data []byte
+	var started, escaped, invalid, tooLarge bool
+	for {
+		b, timeout, err := f.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		// Wait for the next frame while the line is idle, a partial frame is discarded
+		if timeout {
+			if started {
+				return nil, ErrFrameTimeout
+			}
+			continue
+		}
+
+		// Check if the frame ended, the empty frames are the delimiters between frames
+		if b == FrameEnd {
+			switch {
+			case !started:
+				continue
+			case tooLarge:
+				return nil, ErrFrameTooLarge
+			case invalid || escaped:
+				return nil, ErrInvalidFrame
+			}
+			return DecodeFrame(data)
+		}
+		started = true
+		if invalid || tooLarge {
+			continue
+		}
+
+		// Unescape the byte
+		if escaped {
+			escaped = false
+			switch b {
+			case FrameEscapedEnd:
+				b = FrameEnd
+			case FrameEscapedEscape:
+				b = FrameEscape
+			default:
+				invalid = true
+				continue
+			}
+		} else if b == FrameEscape {
+			escaped = true
+			continue
+		}
+
+		// Append the byte, the frames over the maximum are discarded up to their delimiter
+		if len(data) == MaxFrameSize+FrameChecksumSize {
+			tooLarge = true
+			data = nil
+			continue
+		}
+		data = append(data, b)
+	}
+}
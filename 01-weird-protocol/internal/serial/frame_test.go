@@ -0,0 +1,110 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// timeoutReader is a line that times out after each of its chunks, like a serial port with a read timeout
+type timeoutReader struct {
+	chunks  [][]byte
+	timeout bool
+}
+
+// Read reads the next chunk, returning no bytes and no error between the chunks
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	if t.timeout {
+		t.timeout = false
+		return 0, nil
+	}
+	if len(t.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, t.chunks[0])
+	if n == len(t.chunks[0]) {
+		t.chunks = t.chunks[1:]
+		t.timeout = true
+	} else {
+		t.chunks[0] = t.chunks[0][n:]
+	}
+	return n, nil
+}
+
+// TestFrameRoundTrip tests that the payloads with the SLIP bytes are read back from their frames
+func TestFrameRoundTrip(t *testing.T) {
+	payloads := [][]byte{
+		[]byte("header: echo"),
+		{FrameEnd, FrameEscape, FrameEscapedEnd, FrameEscapedEscape, FrameEnd},
+		{},
+	}
+	var line bytes.Buffer
+	for _, payload := range payloads {
+		line.Write(EncodeFrame(payload))
+	}
+	reader := NewFrameReader(&line)
+	for _, payload := range payloads {
+		read, err := reader.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(read, payload) {
+			t.Errorf("expected %x, got %x", payload, read)
+		}
+	}
+	if _, err := reader.ReadFrame(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+// TestFrameReaderErrors tests that the corrupted, too large and interrupted frames are discarded, and the next frame is read after them
+func TestFrameReaderErrors(t *testing.T) {
+	corrupted := EncodeFrame([]byte("header: echo"))
+	corrupted[3] ^= 0x01
+	invalidEscape := []byte{FrameEnd, 'a', FrameEscape, 'b', FrameEnd}
+	tooLarge := EncodeFrame(make([]byte, MaxFrameSize+1))
+	garbageSize := []byte{0xff, 0xff, 0xff, 0xff}
+	valid := EncodeFrame([]byte("header: echo"))
+
+	tests := []struct {
+		name     string
+		chunks   [][]byte
+		expected error
+	}{
+		{"corrupted", [][]byte{corrupted, valid}, ErrInvalidFrame},
+		{"invalid escape", [][]byte{invalidEscape, valid}, ErrInvalidFrame},
+		{"too short", [][]byte{{FrameEnd, 'a', FrameEnd}, valid}, ErrInvalidFrame},
+		{"too large", [][]byte{tooLarge, valid}, ErrFrameTooLarge},
+		{"interrupted", [][]byte{valid[:5], valid}, ErrFrameTimeout},
+		{"garbage size", [][]byte{garbageSize, valid}, ErrFrameTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewFrameReader(&timeoutReader{chunks: test.chunks})
+			if _, err := reader.ReadFrame(); !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+			payload, err := reader.ReadFrame()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(payload) != "header: echo" {
+				t.Errorf("expected the next frame, got %q", payload)
+			}
+		})
+	}
+}
+
+// TestFrameReaderIdle tests that an idle line waits for the next frame
+func TestFrameReaderIdle(t *testing.T) {
+	valid := EncodeFrame([]byte("header: echo"))
+	reader := NewFrameReader(&timeoutReader{chunks: [][]byte{{FrameEnd}, valid}, timeout: true})
+	payload, err := reader.ReadFrame()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != "header: echo" {
+		t.Errorf("expected the frame, got %q", payload)
+	}
+}
@@ -0,0 +1,110 @@
+package serial
+
+import (
+	"fmt"
+	goserial "go.bug.st/serial"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaudRate is the default baud rate of the serial port
+	DefaultBaudRate = 9600
+
+	// DataBits is the number of data bits of the serial port
+	DataBits = 8
+
+	// ReopenDelay is the time to wait before opening the serial port again after an error
+	ReopenDelay = 2 * time.Second
+
+	// ReadTimeout is the time the line can be silent in the middle of a frame before its received bytes are discarded
+	ReadTimeout = time.Second
+)
+
+var (
+	// Parities are the parities of the serial port by their name
+	Parities = map[string]goserial.Parity{
+		"none":  goserial.NoParity,
+		"odd":   goserial.OddParity,
+		"even":  goserial.EvenParity,
+		"mark":  goserial.MarkParity,
+		"space": goserial.SpaceParity,
+	}
+
+	// StopBits are the stop bits of the serial port by their name
+	StopBits = map[string]goserial.StopBits{
+		"1":   goserial.OneStopBit,
+		"1.5": goserial.OnePointFiveStopBits,
+		"2":   goserial.TwoStopBits,
+	}
+)
+
+// Config is the configuration of a serial port
+type Config struct {
+	// Device is the path of the TTY device
+	Device string
+
+	// BaudRate is the baud rate
+	BaudRate int
+
+	// Parity is the parity
+	Parity goserial.Parity
+
+	// StopBits are the stop bits
+	StopBits goserial.StopBits
+}
+
+// sortedNames returns the sorted names of a map
+func sortedNames[T any](values map[string]T) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ParseParity parses the name of a parity, an empty one is no parity
+func ParseParity(name string) (goserial.Parity, error) {
+	if name == "" {
+		return goserial.NoParity, nil
+	}
+	parity, ok := Parities[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown parity %s, expected: %s", name, sortedNames(Parities))
+	}
+	return parity, nil
+}
+
+// ParseStopBits parses the name of the stop bits, an empty one is one stop bit
+func ParseStopBits(name string) (goserial.StopBits, error) {
+	if name == "" {
+		return goserial.OneStopBit, nil
+	}
+	stopBits, ok := StopBits[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown stop bits %s, expected: %s", name, sortedNames(StopBits))
+	}
+	return stopBits, nil
+}
+
+// Open opens the serial port of a configuration with the read timeout, a pseudo-terminal can be used instead of a real device
+func Open(config *Config) (goserial.Port, error) {
+	port, err := goserial.Open(
+		config.Device, &goserial.Mode{
+			BaudRate: config.BaudRate,
+			DataBits: DataBits,
+			Parity:   config.Parity,
+			StopBits: config.StopBits,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = port.SetReadTimeout(ReadTimeout); err != nil {
+		_ = port.Close()
+		return nil, err
+	}
+	return port, nil
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalchat "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/chat"
+	internalevents "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/events"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// NewChatEventFields creates the fields of a chat event pushed to a session
+func NewChatEventFields(event *internalevents.Event) internalmessage.Fields {
+	fields := internalmessage.Fields{
+		"event": internalmessage.NewString(event.Type),
+		"actor": internalmessage.NewString(event.Actor),
+		"time":  internalmessage.NewString(event.Time.UTC().Format(time.RFC3339Nano)),
+	}
+	for key, value := range event.Data {
+		if text, ok := value.(string); ok {
+			fields[key] = internalmessage.NewString(text)
+		}
+	}
+	return fields
+}
+
+// NewStringList creates a list value of strings
+func NewStringList(items []string) *internalmessage.Value {
+	values := make([]*internalmessage.Value, len(items))
+	for i, item := range items {
+		values[i] = internalmessage.NewString(item)
+	}
+	return internalmessage.NewList(values...)
+}
+
+// HandleEcho handles the echo, the message is written back to the sender
+func HandleEcho(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	body internalmessage.Fields,
+) {
+	// Get the message
+	message, err := body.GetString("message")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	respondValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"message": internalmessage.NewString(message),
+			},
+		),
+	)
+}
+
+// HandleChatRequest handles a request sent inside a chat session, it returns the response
+func HandleChatRequest(
+	session *internalchat.Session,
+	header string,
+	body internalmessage.Fields,
+) (*internalmessage.Value, error) {
+	response := internalmessage.Fields{
+		"header": internalmessage.NewString(header),
+	}
+
+	switch header {
+	case internal.BroadcastHeader:
+		// Get the message and the optional room
+		message, err := body.GetString("message")
+		if err != nil {
+			return nil, err
+		}
+		var room string
+		if body.Has("room") {
+			if room, err = body.GetString("room"); err != nil {
+				return nil, err
+			}
+		}
+
+		// Send the message to the room or to all the sessions
+		var recipients int
+		if room == "" {
+			recipients, err = internalloader.Chat.Broadcast(session, message)
+		} else {
+			recipients, err = internalloader.Chat.SendRoom(session, room, message)
+			response["room"] = internalmessage.NewString(room)
+		}
+		if err != nil {
+			return nil, err
+		}
+		response["recipients"] = internalmessage.NewInt(int64(recipients))
+	case internal.PrivateHeader:
+		// Get the recipient and the message
+		if err := body.Require("to", "message"); err != nil {
+			return nil, err
+		}
+		to, err := body.GetString("to")
+		if err != nil {
+			return nil, err
+		}
+		message, err := body.GetString("message")
+		if err != nil {
+			return nil, err
+		}
+
+		if err = internalloader.Chat.SendPrivate(session, to, message); err != nil {
+			return nil, err
+		}
+		response["to"] = internalmessage.NewString(to)
+	case internal.EchoHeader:
+		message, err := body.GetString("message")
+		if err != nil {
+			return nil, err
+		}
+		response["message"] = internalmessage.NewString(message)
+	case internal.JoinHeader:
+		room, err := body.GetString("room")
+		if err != nil {
+			return nil, err
+		}
+		members, err := internalloader.Chat.Join(session, room)
+		if err != nil {
+			return nil, err
+		}
+		response["room"] = internalmessage.NewString(room)
+		response["members"] = NewStringList(members)
+	case internal.LeaveHeader:
+		room, err := body.GetString("room")
+		if err != nil {
+			return nil, err
+		}
+		if err = internalloader.Chat.Leave(session, room); err != nil {
+			return nil, err
+		}
+		response["room"] = internalmessage.NewString(room)
+	default:
+		return nil, fmt.Errorf("unknown chat header: %s", header)
+	}
+	return internalmessage.NewObject(response), nil
+}
+
+// HandleChat handles a chat session on a persistent connection, until the client closes it. Every message after the request is a message of the connection, in both directions: the client sends requests with the chat headers, and the server writes their responses and pushes the messages and notifications of the session, which have an event field
+func HandleChat(
+	logger *slog.Logger,
+	respondFn func(message string),
+	conn *PersistentConn,
+	encoding internalmessage.Encoding,
+	user string,
+) {
+	// Check if the connection is persistent
+	if conn == nil {
+		respondFn("chatting requires a persistent connection")
+		return
+	}
+
+	// Get the message write functions, the responses and the pushes are written concurrently
+	var writeMutex sync.Mutex
+	writeMessageFn := func(message string) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.WriteMessage(message)
+	}
+	respondMessageFn := Respond(logger, encoding, writeMessageFn)
+	respondMessageValueFn := RespondValue(logger, encoding, writeMessageFn)
+
+	// Connect the session
+	session, err := internalloader.Chat.Connect(user)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	logger.Info("chat session opened", "session", session.ID)
+	respondMessageValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"session":  internalmessage.NewString(session.ID),
+				"sessions": NewStringList(internalloader.Chat.Sessions()),
+			},
+		),
+	)
+
+	// Push the events of the session until it is disconnected
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		for event := range session.Events() {
+			fields := NewChatEventFields(event)
+			if dropped := session.Dropped(); dropped > 0 {
+				fields["dropped"] = internalmessage.NewInt(dropped)
+			}
+			respondMessageValueFn(internalmessage.NewObject(fields))
+		}
+	}()
+	defer func() {
+		internalloader.Chat.Disconnect(session)
+		<-pushed
+		logger.Info("chat session closed", "session", session.ID)
+	}()
+
+	// Handle the requests until the connection is closed
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warn("error reading", ErrorLogKey, err)
+			}
+			return
+		}
+
+		// Get the header and body
+		fields, err := internalmessage.Parse(encoding, &message)
+		if err != nil {
+			respondMessageFn(err.Error())
+			continue
+		}
+		if err = fields.Allow(RequestFields...); err != nil {
+			respondMessageFn(err.Error())
+			continue
+		}
+		header, err := fields.GetString("header")
+		if err != nil {
+			respondMessageFn(err.Error())
+			continue
+		}
+		body := make(internalmessage.Fields)
+		if fields.Has("body") {
+			if body, err = fields.GetObject("body"); err != nil {
+				respondMessageFn(err.Error())
+				continue
+			}
+		}
+		logger.Info("chat request", "chat_header", header)
+
+		// Handle the request
+		response, err := HandleChatRequest(session, header, body)
+		if err != nil {
+			respondMessageFn(err.Error())
+			continue
+		}
+		respondMessageValueFn(response)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"net"
+	"time"
+)
+
+type (
+	// PersistentConn is a connection kept open after the request, for the handlers that read more data or push messages. The transports without persistent connections have none
+	PersistentConn struct {
+		// Read reads the next data of the connection as it arrives
+		Read func() (string, error)
+
+		// ReadMessage reads the next message of the connection, the stream transports frame them
+		ReadMessage func() (string, error)
+
+		// WriteMessage writes a message to the connection, the stream transports frame them
+		WriteMessage func(message string)
+	}
+
+	// DeadlineReadWriter is the connection of a stream protocol, like a TCP connection or a QUIC stream
+	DeadlineReadWriter interface {
+		io.ReadWriter
+
+		// SetReadDeadline sets the deadline of the next reads, a zero time removes it
+		SetReadDeadline(t time.Time) error
+	}
+
+	// ConnReader reads a persistent connection as a stream, the idle timeouts of the read function are ignored
+	ConnReader struct {
+		readFn func() (string, error)
+		buffer string
+	}
+)
+
+// NewConnReader creates a new reader of a persistent connection
+func NewConnReader(readFn func() (string, error)) *ConnReader {
+	return &ConnReader{readFn: readFn}
+}
+
+// Read reads the next data of the connection
+func (c *ConnReader) Read(p []byte) (int, error) {
+	for c.buffer == "" {
+		data, err := c.readFn()
+		c.buffer += data
+		if err != nil && c.buffer == "" {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return 0, err
+		}
+	}
+	n := copy(p, c.buffer)
+	c.buffer = c.buffer[n:]
+	return n, nil
+}
+
+// NewStreamConn creates a persistent connection of a stream transport, its messages are framed with their length
+func NewStreamConn(
+	readFn func() (string, error),
+	writeFn func(message string),
+) *PersistentConn {
+	reader := NewConnReader(readFn)
+	return &PersistentConn{
+		Read: readFn,
+		ReadMessage: func() (string, error) {
+			return internalmessage.ReadFrame(reader)
+		},
+		WriteMessage: func(message string) {
+			writeFn(internalmessage.EncodeFrame(message))
+		},
+	}
+}
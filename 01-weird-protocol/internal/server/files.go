@@ -0,0 +1,436 @@
+package server
+
+import (
+	"encoding/base64"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"time"
+	"unicode/utf8"
+)
+
+// AddFile adds the file of a body, the filename is a path inside the files root. The optional mode is the policy for an existing file, the optional ifmatch is the checksum its content must have for it to be written, and the optional checksum is the checksum of the sent content, verified before it is written
+func AddFile(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) (*internalmessage.Value, error) {
+	// Get the fields
+	if err := body.Require("filename", "content"); err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	filename, err := body.GetString("filename")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	content, err := body.GetString("content")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	options, err := ReadWriteOptions(body)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	// Verify the checksum of the content
+	if body.Has("checksum") {
+		checksum, err := body.GetString("checksum")
+		if err != nil {
+			return nil, &RequestError{Err: err}
+		}
+		if err = internalstorage.VerifyChecksum([]byte(content), checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	// Write the file
+	err = fileStorage.WriteFile(filename, []byte(content), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return internalmessage.NewString("File added successfully"), nil
+}
+
+// HandleAddFile handles the add file
+func HandleAddFile(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	RespondResult(respondFn, respondValueFn)(AddFile(fileStorage, body))
+}
+
+// ReadWriteOptions reads the optional write options of the body
+func ReadWriteOptions(body internalmessage.Fields) (*internalstorage.WriteOptions, error) {
+	options := &internalstorage.WriteOptions{Mode: internalstorage.WriteOverwrite}
+
+	// Get the mode
+	if body.Has("mode") {
+		mode, err := body.GetString("mode")
+		if err != nil {
+			return nil, err
+		}
+		if options.Mode, err = internalstorage.ParseWriteMode(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get the checksum of the condition
+	if body.Has("ifmatch") {
+		ifMatch, err := body.GetString("ifmatch")
+		if err != nil {
+			return nil, err
+		}
+		options.IfMatch = ifMatch
+	}
+	return options, nil
+}
+
+// RemoveFile removes the file of a body
+func RemoveFile(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) (*internalmessage.Value, error) {
+	// Get the fields
+	filename, err := body.GetString("filename")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	// Remove the file
+	if err = fileStorage.RemoveFile(filename); err != nil {
+		return nil, err
+	}
+	return internalmessage.NewString("File removed successfully"), nil
+}
+
+// HandleRemoveFile handles the remove file
+func HandleRemoveFile(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	RespondResult(respondFn, respondValueFn)(RemoveFile(fileStorage, body))
+}
+
+// HandleGetFile handles the get file, the content of a binary file is encoded in base64. The checksum is the one stored when the file was written, or the one of its content if it was not stored, and can be used as the ifmatch of a conditional add file
+func HandleGetFile(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	filename, err := body.GetString("filename")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Read the file and its stored checksum
+	info, err := fileStorage.Stat(filename)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	content, err := fileStorage.ReadFile(filename)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	checksum := info.Checksum
+	if checksum == "" {
+		checksum = internalstorage.Checksum(content)
+	}
+
+	// Write the file
+	response := internalmessage.Fields{
+		"filename": internalmessage.NewString(filename),
+		"size":     internalmessage.NewInt(int64(len(content))),
+		"checksum": internalmessage.NewString(checksum),
+	}
+	if utf8.Valid(content) {
+		response["content"] = internalmessage.NewString(string(content))
+	} else {
+		response["content"] = internalmessage.NewString(base64.StdEncoding.EncodeToString(content))
+		response["encoding"] = internalmessage.NewString(internal.FileContentBase64)
+	}
+	respondValueFn(internalmessage.NewObject(response))
+}
+
+// HandleListFiles handles the listing of the files of a directory, an empty or missing directory is the files root
+func HandleListFiles(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	var directory string
+	if body.Has("directory") {
+		var err error
+		if directory, err = body.GetString("directory"); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+
+	// List the files
+	files, err := fileStorage.List(directory)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the files
+	values := make([]*internalmessage.Value, 0, len(files))
+	for _, file := range files {
+		values = append(values, internalmessage.NewObject(NewFileInfoFields(&file)))
+	}
+	respondValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"directory": internalmessage.NewString(directory),
+				"files":     internalmessage.NewList(values...),
+			},
+		),
+	)
+}
+
+// NewFileInfoFields creates the fields of the information of a file or directory, the checksum is only included if it was stored
+func NewFileInfoFields(info *internalstorage.FileInfo) internalmessage.Fields {
+	fields := internalmessage.Fields{
+		"name":      internalmessage.NewString(info.Name),
+		"size":      internalmessage.NewInt(info.Size),
+		"directory": internalmessage.NewBool(info.IsDir),
+	}
+	if info.Checksum != "" {
+		fields["checksum"] = internalmessage.NewString(info.Checksum)
+	}
+	return fields
+}
+
+// HandleStat handles the information of a file or directory, with its modification time in RFC 3339 format
+func HandleStat(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	filename, err := body.GetString("filename")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Get the information
+	info, err := fileStorage.Stat(filename)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	fields := NewFileInfoFields(info)
+	fields["modified"] = internalmessage.NewString(info.ModTime.UTC().Format(time.RFC3339))
+	respondValueFn(internalmessage.NewObject(fields))
+}
+
+// HandleVerify handles the verification of the files, reading all of them to compare their content with their stored checksum
+func HandleVerify(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+) {
+	// Verify the files
+	verification, err := internalstorage.Verify(fileStorage)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the corrupted and the unchecked files
+	corrupted := make([]*internalmessage.Value, 0, len(verification.Corrupted))
+	for _, file := range verification.Corrupted {
+		corrupted = append(
+			corrupted, internalmessage.NewObject(
+				internalmessage.Fields{
+					"name":     internalmessage.NewString(file.Name),
+					"expected": internalmessage.NewString(file.Expected),
+					"actual":   internalmessage.NewString(file.Actual),
+				},
+			),
+		)
+	}
+	unchecked := make([]*internalmessage.Value, 0, len(verification.Unchecked))
+	for _, name := range verification.Unchecked {
+		unchecked = append(unchecked, internalmessage.NewString(name))
+	}
+	respondValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"verified":  internalmessage.NewInt(verification.Verified),
+				"corrupted": internalmessage.NewList(corrupted...),
+				"unchecked": internalmessage.NewList(unchecked...),
+			},
+		),
+	)
+}
+
+// HandleMkdir handles the creation of a directory, with its missing parents
+func HandleMkdir(
+	respondFn func(message string),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	directory, err := body.GetString("directory")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Create the directory
+	err = fileStorage.Mkdir(directory)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the success message
+	respondFn("Directory created successfully")
+}
+
+// HandleRmdir handles the removal of an empty directory
+func HandleRmdir(
+	respondFn func(message string),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	directory, err := body.GetString("directory")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Remove the directory
+	err = fileStorage.Rmdir(directory)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the success message
+	respondFn("Directory removed successfully")
+}
+
+// HandleRename handles the renaming of a file or directory
+func HandleRename(
+	respondFn func(message string),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	if err := body.Require("from", "to"); err != nil {
+		respondFn(err.Error())
+		return
+	}
+	from, err := body.GetString("from")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	to, err := body.GetString("to")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Rename the file or directory
+	err = fileStorage.Rename(from, to)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the success message
+	respondFn("Renamed successfully")
+}
+
+// HandleMove handles the move of a file or directory into a directory, an empty directory is the files root
+func HandleMove(
+	respondFn func(message string),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	if err := body.Require("filename", "directory"); err != nil {
+		respondFn(err.Error())
+		return
+	}
+	filename, err := body.GetString("filename")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	directory, err := body.GetString("directory")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Move the file or directory
+	err = internalstorage.Move(fileStorage, filename, directory)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the success message
+	respondFn("Moved successfully")
+}
+
+// HandleQuota handles the quota, writing the usage and the limits of the file storage of the user, the shared files for the anonymous requests. A zero limit is unlimited
+func HandleQuota(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	user string,
+) {
+	// Get the usage, the one tracked by the quota of the storage if it has one
+	usage, err := internalstorage.GetUsage(GetUserStorage(user))
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Get the limits, the anonymous requests are limited by the quota of the shared files
+	quota := internalloader.FileQuota
+	if user != "" {
+		quota = internalloader.UserFileQuota
+	}
+
+	respondValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"user": internalmessage.NewString(user),
+				"usage": internalmessage.NewObject(
+					internalmessage.Fields{
+						"bytes": internalmessage.NewInt(usage.Bytes),
+						"files": internalmessage.NewInt(usage.Files),
+					},
+				),
+				"limits": internalmessage.NewObject(
+					internalmessage.Fields{
+						"maxfilesize": internalmessage.NewInt(quota.MaxFileSize),
+						"maxbytes":    internalmessage.NewInt(quota.MaxBytes),
+						"maxfiles":    internalmessage.NewInt(quota.MaxFiles),
+					},
+				),
+			},
+		),
+	)
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// OpenAPIPath is the path of the OpenAPI document of the HTTP gateway
+	OpenAPIPath = "/openapi.json"
+
+	// OpenAPIVersion is the version of the OpenAPI specification of the document
+	OpenAPIVersion = "3.0.3"
+
+	// GatewayTitle is the title of the HTTP gateway in the OpenAPI document
+	GatewayTitle = "Weird Protocol HTTP gateway"
+)
+
+// GatewayRoute is a REST route of the HTTP gateway, mapped onto the handler of a header
+type GatewayRoute struct {
+	// Method is the HTTP method of the route
+	Method string
+
+	// Path is the pattern of the route, with its parameter in braces. A parameter ending with '...' matches the rest of the path, slashes included
+	Path string
+
+	// Header is the header of the handler
+	Header string
+
+	// PathField is the body field set from the parameter of the path, empty if the path has none
+	PathField string
+
+	// ResultField is the field of the JSON object of the string results of the handler, the object results are written as they are
+	ResultField string
+
+	// SuccessStatus is the status code of the successful responses
+	SuccessStatus int
+
+	// HandleFn is the handler of the route, it returns the result or the error of the request
+	HandleFn func(fileStorage internalstorage.Storage, body internalmessage.Fields) (*internalmessage.Value, error)
+}
+
+// GatewayRoutes are the REST routes of the HTTP gateway
+var GatewayRoutes = []GatewayRoute{
+	{
+		Method:        http.MethodPost,
+		Path:          "/morse",
+		Header:        internal.MorseHeader,
+		ResultField:   "result",
+		SuccessStatus: http.StatusOK,
+		HandleFn:      ConvertMorseBody,
+	},
+	{
+		Method:        http.MethodPut,
+		Path:          "/files/{name...}",
+		Header:        internal.AddFileHeader,
+		PathField:     "filename",
+		ResultField:   "message",
+		SuccessStatus: http.StatusOK,
+		HandleFn:      AddFile,
+	},
+	{
+		Method:        http.MethodDelete,
+		Path:          "/files/{name...}",
+		Header:        internal.RemoveFileHeader,
+		PathField:     "filename",
+		ResultField:   "message",
+		SuccessStatus: http.StatusNoContent,
+		HandleFn:      RemoveFile,
+	},
+	{
+		Method:        http.MethodPost,
+		Path:          "/mail",
+		Header:        internal.MailHeader,
+		ResultField:   "message",
+		SuccessStatus: http.StatusOK,
+		HandleFn: func(fileStorage internalstorage.Storage, body internalmessage.Fields) (*internalmessage.Value, error) {
+			return SendMail(body)
+		},
+	},
+}
+
+// gatewayErrorStatuses are the status codes of the known errors returned by the handlers
+var gatewayErrorStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrInvalidToken, http.StatusUnauthorized},
+	{ErrStreamingConnection, http.StatusBadRequest},
+	{ErrMailProvider, http.StatusBadGateway},
+	{fs.ErrNotExist, http.StatusNotFound},
+	{internalstorage.ErrFileExists, http.StatusConflict},
+	{internalstorage.ErrParentNotExist, http.StatusConflict},
+	{internalstorage.ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{internalstorage.ErrQuotaExceeded, http.StatusInsufficientStorage},
+	{internalstorage.ErrChecksumMismatch, http.StatusUnprocessableEntity},
+	{internalstorage.ErrOutsideRoot, http.StatusForbidden},
+	{internalstorage.ErrReservedPath, http.StatusForbidden},
+}
+
+// GatewayErrorStatus returns the status code of an error returned by a handler. The request errors are bad requests, the conversion errors are unprocessable, and the unknown errors are faults of the server
+func GatewayErrorStatus(err error) int {
+	for _, errorStatus := range gatewayErrorStatuses {
+		if errors.Is(err, errorStatus.err) {
+			return errorStatus.status
+		}
+	}
+	var requestError *RequestError
+	if errors.As(err, &requestError) {
+		return http.StatusBadRequest
+	}
+	var conversionError *ConversionError
+	if errors.As(err, &conversionError) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// GetOpenAPIPath returns the OpenAPI path of the pattern of a route, whose parameters do not have the '...' suffix
+func GetOpenAPIPath(pattern string) string {
+	return strings.ReplaceAll(pattern, "...}", "}")
+}
+
+// WriteGatewayResponse writes a JSON response of the HTTP gateway
+func WriteGatewayResponse(w http.ResponseWriter, status int, response any) {
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// WriteGatewayError writes a JSON error response of the HTTP gateway
+func WriteGatewayError(w http.ResponseWriter, status int, message string) {
+	WriteGatewayResponse(w, status, map[string]string{"error": message})
+}
+
+// ReadGatewayBody reads the JSON object of the body of an HTTP request, an empty body has no fields
+func ReadGatewayBody(r *http.Request) (internalmessage.Fields, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxRequestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxRequestSize {
+		return nil, fmt.Errorf("request too large, the maximum is %d bytes", MaxRequestSize)
+	}
+	body := string(data)
+	if strings.TrimSpace(body) == "" {
+		return make(internalmessage.Fields), nil
+	}
+	return internalmessage.ParseJSON(&body)
+}
+
+// GetBearerToken gets the token of the Authorization header of an HTTP request, it is empty for the anonymous requests
+func GetBearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// HandleGatewayRoute handles an HTTP request of a REST route of the gateway
+func HandleGatewayRoute(
+	logger *slog.Logger,
+	w http.ResponseWriter,
+	r *http.Request,
+	route *GatewayRoute,
+) {
+	logger.Info("gateway request", "method", r.Method, "path", r.URL.Path)
+
+	// Get the body, with the parameter of the path and the conditional header
+	body, err := ReadGatewayBody(r)
+	if err != nil {
+		WriteGatewayError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if route.PathField != "" {
+		body[route.PathField] = internalmessage.NewString(r.PathValue("name"))
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && route.Header == internal.AddFileHeader {
+		body["ifmatch"] = internalmessage.NewString(strings.Trim(ifMatch, `"`))
+	}
+
+	// Get the user and its file storage, the requests without a token are anonymous
+	fields := make(internalmessage.Fields)
+	if token := GetBearerToken(r); token != "" {
+		fields["token"] = internalmessage.NewString(token)
+	}
+	user, err := GetUser(fields, nil)
+	if err != nil {
+		logger.Info("unauthenticated request", ErrorLogKey, err)
+		WriteGatewayError(w, GatewayErrorStatus(err), err.Error())
+		return
+	}
+	fileStorage := GetFileStorage(user)
+	logger.Info("request", HeaderLogKey, route.Header, UserLogKey, user)
+
+	// Validate the body with the schema of the handler, the same one of the OpenAPI document
+	if err = ValidateBody(route.Header, body); err != nil {
+		WriteGatewayError(w, GatewayErrorStatus(err), err.Error())
+		return
+	}
+
+	// Check if the file exists before it is written, so its creation is reported
+	status := route.SuccessStatus
+	if route.Header == internal.AddFileHeader {
+		filename, _ := body.GetString("filename")
+		if _, err = fileStorage.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+			status = http.StatusCreated
+		}
+	}
+
+	// Call the handler
+	result, err := route.HandleFn(fileStorage, body)
+	if err != nil {
+		status = GatewayErrorStatus(err)
+		if status >= http.StatusInternalServerError {
+			logger.Error("handler error", ErrorLogKey, err)
+		}
+		WriteGatewayError(w, status, err.Error())
+		return
+	}
+
+	// Write the result, the string results are set to the result field of an object
+	if result.Type == internalmessage.StringValue {
+		message, _ := result.AsString()
+		WriteGatewayResponse(w, status, map[string]string{route.ResultField: message})
+		return
+	}
+	WriteGatewayResponse(w, status, result.ToAny())
+}
+
+// NewGatewayTransport creates the transport of the HTTP gateway of an address
+func NewGatewayTransport(address string) *HTTPTransport {
+	return NewHTTPTransport("http", address, NewGatewayHandler())
+}
+
+// NewGatewayHandler creates the HTTP handler of the gateway, with its REST routes and its OpenAPI document. Each request is logged with its number in the transport
+func NewGatewayHandler() http.Handler {
+	mux := http.NewServeMux()
+	for i := range GatewayRoutes {
+		route := &GatewayRoutes[i]
+		mux.HandleFunc(
+			route.Method+" "+route.Path, func(w http.ResponseWriter, r *http.Request) {
+				HandleGatewayRoute(
+					NewLogger("http", GetConnNumber(r), &Peer{Address: r.RemoteAddr}),
+					w,
+					r,
+					route,
+				)
+			},
+		)
+	}
+
+	// Serve the OpenAPI document
+	document := NewOpenAPIDocument()
+	mux.HandleFunc(
+		http.MethodGet+" "+OpenAPIPath, func(w http.ResponseWriter, r *http.Request) {
+			WriteGatewayResponse(w, http.StatusOK, document)
+		},
+	)
+	return mux
+}
+
+// NewOpenAPISchema creates the OpenAPI schema of a field
+func NewOpenAPISchema(field *FieldSchema) map[string]any {
+	schema := map[string]any{"type": field.Type}
+	if field.Description != "" {
+		schema["description"] = field.Description
+	}
+	if len(field.Enum) > 0 {
+		schema["enum"] = field.Enum
+	}
+	if field.Items != nil {
+		schema["items"] = NewOpenAPISchema(field.Items)
+
+		// Get the alternative of the single item
+		if field.AllowSingle {
+			delete(schema, "description")
+			return map[string]any{
+				"description": field.Description,
+				"oneOf": []map[string]any{
+					schema,
+					NewOpenAPISchema(field.Items),
+				},
+			}
+		}
+	}
+	if field.Type == ObjectType {
+		properties := make(map[string]any)
+		var required []string
+		for i := range field.Fields {
+			properties[field.Fields[i].Name] = NewOpenAPISchema(&field.Fields[i])
+			if field.Fields[i].Required {
+				required = append(required, field.Fields[i].Name)
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	return schema
+}
+
+// NewOpenAPIOperation creates the OpenAPI operation of a route, from the schema of its handler
+func NewOpenAPIOperation(route *GatewayRoute) map[string]any {
+	handlerSchema := HandlerSchemas[route.Header]
+	errorResponse := map[string]any{
+		"description": "Error of the handler",
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{
+					"type": ObjectType,
+					"properties": map[string]any{
+						"error": map[string]any{"type": StringType},
+					},
+				},
+			},
+		},
+	}
+	successResponse := map[string]any{"description": "Successful response"}
+	if route.SuccessStatus != http.StatusNoContent {
+		successResponse["content"] = map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"type": ObjectType},
+			},
+		}
+	}
+	responses := map[string]any{
+		fmt.Sprint(route.SuccessStatus): successResponse,
+		"default":                       errorResponse,
+	}
+	if route.Header == internal.AddFileHeader {
+		responses[fmt.Sprint(http.StatusCreated)] = successResponse
+	}
+	operation := map[string]any{
+		"operationId": route.Header,
+		"summary":     handlerSchema.Summary,
+		"responses":   responses,
+		"security": []map[string]any{
+			{},
+			{"bearer": []string{}},
+		},
+	}
+
+	// Get the parameter of the path, it is not part of the body
+	fields := handlerSchema.Fields
+	if route.PathField != "" {
+		operation["parameters"] = []map[string]any{
+			{
+				"name":        "name",
+				"in":          "path",
+				"required":    true,
+				"description": "Value of the " + route.PathField + " field, it can have slashes",
+				"schema":      map[string]any{"type": StringType},
+			},
+		}
+		fields = nil
+		for _, field := range handlerSchema.Fields {
+			if field.Name != route.PathField {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	// Get the request body, the requests without body fields have none
+	if len(fields) > 0 {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": NewOpenAPISchema(
+						&FieldSchema{Type: ObjectType, Fields: fields},
+					),
+				},
+			},
+		}
+	}
+	return operation
+}
+
+// NewOpenAPIDocument creates the OpenAPI document of the HTTP gateway, from the schemas of the handlers of its routes
+func NewOpenAPIDocument() map[string]any {
+	paths := make(map[string]any)
+	for i := range GatewayRoutes {
+		route := &GatewayRoutes[i]
+		path := GetOpenAPIPath(route.Path)
+		operations, ok := paths[path].(map[string]any)
+		if !ok {
+			operations = make(map[string]any)
+			paths[path] = operations
+		}
+		operations[strings.ToLower(route.Method)] = NewOpenAPIOperation(route)
+	}
+
+	// Get the sorted headers of the routes
+	headers := make([]string, 0, len(GatewayRoutes))
+	for _, route := range GatewayRoutes {
+		headers = append(headers, route.Header)
+	}
+	sort.Strings(headers)
+
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":       GatewayTitle,
+			"version":     fmt.Sprint(internal.ProtocolVersion),
+			"description": "REST routes of the headers: " + strings.Join(headers, ", "),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+	}
+}
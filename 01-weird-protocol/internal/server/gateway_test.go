@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mailersend/mailersend-go"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGatewayErrorStatus tests the status codes of the errors returned by the handlers
+func TestGatewayErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"invalid token", ErrInvalidToken, http.StatusUnauthorized},
+		{"not found", internalstorage.NotFoundError("open", "file.txt"), http.StatusNotFound},
+		{"wrapped quota exceeded", fmt.Errorf("write: %w", internalstorage.ErrQuotaExceeded), http.StatusInsufficientStorage},
+		{"reserved path", internalstorage.ErrReservedPath, http.StatusForbidden},
+		{"conversion", &ConversionError{Err: errors.New("invalid character")}, http.StatusUnprocessableEntity},
+		{"request", &RequestError{Err: errors.New("missing fields: to")}, http.StatusBadRequest},
+		{"streaming", ErrStreamingConnection, http.StatusBadRequest},
+		{"mail provider", fmt.Errorf("%w: %w", ErrMailProvider, errors.New("service unavailable")), http.StatusBadGateway},
+		{"message mentioning a known error", errors.New("invalid 'to' field value " + fs.ErrNotExist.Error()), http.StatusInternalServerError},
+		{"storage", &fs.PathError{Op: "write", Path: "file.txt", Err: errors.New("input/output error")}, http.StatusInternalServerError},
+		{"unknown", errors.New("invalid request"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := GatewayErrorStatus(test.err); status != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, status)
+			}
+		})
+	}
+}
+
+// TestGatewayRoutes tests the status codes and the responses of the REST routes of the gateway
+func TestGatewayRoutes(t *testing.T) {
+	setMorseConverters(t)
+	fileStorage := internalloader.FileStorage
+	internalloader.FileStorage = internalstorage.NewMemoryStorage()
+	t.Cleanup(func() { internalloader.FileStorage = fileStorage })
+	server := httptest.NewServer(NewGatewayHandler())
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		response string
+	}{
+		{"add a new file", http.MethodPut, "/files/notes.txt", `{"content": "hello"}`, http.StatusCreated, `{"message":"File added successfully"}`},
+		{"overwrite a file", http.MethodPut, "/files/notes.txt", `{"content": "hello"}`, http.StatusOK, `{"message":"File added successfully"}`},
+		{"add an existing file", http.MethodPut, "/files/notes.txt", `{"content": "hello", "mode": "create"}`, http.StatusConflict, ""},
+		{"remove a file", http.MethodDelete, "/files/notes.txt", "", http.StatusNoContent, ""},
+		{"remove a missing file", http.MethodDelete, "/files/notes.txt", "", http.StatusNotFound, ""},
+		{"add a file without content", http.MethodPut, "/files/notes.txt", `{}`, http.StatusBadRequest, `{"error":"missing fields: content"}`},
+		{"add a file with an invalid mode", http.MethodPut, "/files/notes.txt", `{"content": "hello", "mode": "replace"}`, http.StatusBadRequest, `{"error":"invalid 'mode' field: unexpected value replace, expected: overwrite, create, append"}`},
+		{"add a file to a directory", http.MethodPut, "/files/docs/notes.txt", `{"content": "hello"}`, http.StatusConflict, ""},
+		{"convert a message", http.MethodPost, "/morse", `{"to": "morse", "message": "sos"}`, http.StatusOK, `{"result":"... --- ..."}`},
+		{"convert a message with the success message", http.MethodPost, "/morse", `{"to": "text", "message": "..-. .. .-.. . / .- -.. -.. . -.. / ... ..- -.-. -.-. . ... ... ..-. ..- .-.. .-.. -.--"}`, http.StatusOK, `{"result":"FILE ADDED SUCCESSFULLY"}`},
+		{"convert an invalid message", http.MethodPost, "/morse", `{"to": "text", "message": ".........", "strict": true}`, http.StatusUnprocessableEntity, ""},
+		{"start a stream", http.MethodPost, "/morse", `{"to": "morse", "stream": "start"}`, http.StatusBadRequest, ""},
+		{"convert to an invalid target", http.MethodPost, "/morse", `{"to": "binary", "message": "sos"}`, http.StatusBadRequest, ""},
+		{"convert without a message", http.MethodPost, "/morse", `{"to": "morse"}`, http.StatusBadRequest, `{"error":"missing fields: message"}`},
+		{"convert an invalid message type", http.MethodPost, "/morse", `{"to": "morse", "message": ["sos"]}`, http.StatusBadRequest, ""},
+		{"decode invalid audio data", http.MethodPost, "/morse", `{"to": "text", "from": "audio", "audio": {"data": "not base64"}}`, http.StatusBadRequest, ""},
+		{"send a mail without recipients", http.MethodPost, "/mail", `{"subject": "hi", "message": "hello", "to": []}`, http.StatusBadRequest, ""},
+		{"send a mail to an invalid recipient", http.MethodPost, "/mail", `{"subject": "hi", "message": "hello", "to": {"name": "Alice"}}`, http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, response.StatusCode)
+			}
+			if test.response == "" {
+				return
+			}
+			var body json.RawMessage
+			if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(body) != test.response {
+				t.Errorf("expected %s, got %s", test.response, body)
+			}
+		})
+	}
+}
+
+// TestGatewayOpenAPIDocument tests that the OpenAPI document has the routes of the gateway handler, and that their required body fields are checked by the handler
+func TestGatewayOpenAPIDocument(t *testing.T) {
+	fileStorage := internalloader.FileStorage
+	internalloader.FileStorage = internalstorage.NewMemoryStorage()
+	t.Cleanup(func() { internalloader.FileStorage = fileStorage })
+	mux, ok := NewGatewayHandler().(*http.ServeMux)
+	if !ok {
+		t.Fatalf("expected a serve mux")
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	document := NewOpenAPIDocument()
+	paths := document["paths"].(map[string]any)
+
+	operationsCount := 0
+	for path, pathOperations := range paths {
+		for method, operation := range pathOperations.(map[string]any) {
+			operationsCount++
+
+			// Check the path is routed, with a parameter that has slashes
+			request := httptest.NewRequest(strings.ToUpper(method), strings.ReplaceAll(path, "{name}", "docs/notes.txt"), nil)
+			if _, pattern := mux.Handler(request); pattern == "" || GetOpenAPIPath(strings.SplitN(pattern, " ", 2)[1]) != path {
+				t.Errorf("%s %s: expected the route of the path, got %q", method, path, pattern)
+			}
+
+			// Check the required fields of the body are checked
+			requestBody, ok := operation.(map[string]any)["requestBody"].(map[string]any)
+			if !ok {
+				continue
+			}
+			schema := requestBody["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+			required, _ := schema["required"].([]string)
+			if len(required) == 0 {
+				continue
+			}
+			httpRequest, err := http.NewRequest(strings.ToUpper(method), server.URL+request.URL.Path, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response, err := http.DefaultClient.Do(httpRequest)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var body map[string]string
+			err = json.NewDecoder(response.Body).Decode(&body)
+			_ = response.Body.Close()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := "missing fields: " + strings.Join(required, ", ")
+			if response.StatusCode != http.StatusBadRequest || body["error"] != expected {
+				t.Errorf("%s %s: expected %q, got %d %q", method, path, expected, response.StatusCode, body["error"])
+			}
+		}
+	}
+	if operationsCount != len(GatewayRoutes) {
+		t.Errorf("expected %d operations, got %d", len(GatewayRoutes), operationsCount)
+	}
+}
+
+// roundTripFunc is an HTTP transport of a function
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+// RoundTrip calls the function
+func (r roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return r(request)
+}
+
+// TestGatewayMailProviderError tests that a mail the mail provider fails to send is a bad gateway
+func TestGatewayMailProviderError(t *testing.T) {
+	mailerSendClient := internalloader.MailerSendClient
+	t.Cleanup(func() { internalloader.MailerSendClient = mailerSendClient })
+	internalloader.MailerSendClient = mailersend.NewMailersend("key")
+	internalloader.MailerSendClient.SetClient(
+		&http.Client{
+			Transport: roundTripFunc(
+				func(r *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusServiceUnavailable,
+						Header:     make(http.Header),
+						Body:       io.NopCloser(strings.NewReader(`{"message": "service unavailable"}`)),
+						Request:    r,
+					}, nil
+				},
+			),
+		},
+	)
+	server := httptest.NewServer(NewGatewayHandler())
+	t.Cleanup(server.Close)
+
+	response, err := http.Post(
+		server.URL+"/mail",
+		"application/json",
+		strings.NewReader(`{"subject": "hi", "message": "hello", "to": {"name": "Alice", "email": "alice@example.com"}}`),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, response.StatusCode)
+	}
+}
@@ -1,683 +1,414 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/mailersend/mailersend-go"
 	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
 	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
-	"log"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"log/slog"
 	"net"
-	"os"
-	"strings"
+	"time"
 )
 
 const (
-	// FilesFolder is the folder for the files
-	FilesFolder = "files"
+	// MaxRequestSize is the maximum size in bytes of a TCP request
+	MaxRequestSize = 1 << 20
+
+	// RequestTimeout is the time to wait for a TCP request
+	RequestTimeout = 30 * time.Second
+
+	// UnframedRequestIdleTimeout is the time without data after which an unframed TCP request is considered complete
+	UnframedRequestIdleTimeout = 200 * time.Millisecond
+
+	// StreamIdleTimeout is the time to wait for the next chunk of a stream
+	StreamIdleTimeout = 5 * time.Minute
 )
 
-// CheckFilesFolder checks the files folder
-func CheckFilesFolder(logFn func(string)) bool {
-	// Check if the files folder exists
-	if _, err := os.Stat(FilesFolder); err != nil {
-		// Create the files folder
-		err := os.Mkdir(FilesFolder, 0755)
-		if err != nil {
-			logFn("error creating files folder: " + err.Error())
+// RequestFields are the fields of a request, any other field is rejected. The fields of its body are read by the handlers
+var RequestFields = []string{"version", "header", "body", "token"}
+
+// ErrMailProvider is the error for a mail that the mail provider failed to send
+var ErrMailProvider = errors.New("mail provider error")
+
+// RespondValue writes a value to the client with the given encoding. The responses are not operator logs, they are only logged at the debug level
+func RespondValue(
+	logger *slog.Logger,
+	encoding internalmessage.Encoding,
+	writeFn func(message string),
+) func(*internalmessage.Value) {
+	return func(value *internalmessage.Value) {
+		// Log the value in the text format
+		if logger.Enabled(context.Background(), slog.LevelDebug) {
+			text, err := internalmessage.EncodeText(value)
+			if err != nil {
+				logger.Error("error encoding the response as text", ErrorLogKey, err)
+			} else {
+				logger.Debug("response", ResponseLogKey, text)
+			}
 		}
-		return false
-	}
-	return true
-}
 
-// NoNestedObjects is validation function that checks if none of the fields is a nested objects
-func NoNestedObjects(initialPos int) func(
-	isNestedObject bool,
-	key string,
-	value *string,
-	valuePos int,
-) error {
-	return func(
-		isNestedObject bool,
-		key string,
-		value *string,
-		valuePos int,
-	) error {
-		// Check if it is a nested object
-		if isNestedObject {
-			return fmt.Errorf(
-				"expected a string at position %d", initialPos+valuePos,
+		// Encode the value, the client gets an error message if it can not be encoded
+		encoded, err := internalmessage.Encode(encoding, value)
+		if err != nil {
+			logger.Error("error encoding the response", ErrorLogKey, err)
+			encoded, err = internalmessage.Encode(
+				encoding,
+				internalmessage.NewString("error encoding the response"),
 			)
+			if err != nil {
+				encoded = "error encoding the response"
+			}
 		}
-		return nil
-	}
-}
-
-// MissingAtPositionError is the error for a missing string at a position
-func MissingAtPositionError(str string, pos int) error {
-	return fmt.Errorf("'%s' is missing in the data at position %d", str, pos)
-}
 
-// Log logs a message
-func Log(protocol string, connNumber int) func(string) {
-	return func(msg string) {
-		log.Printf("%s [%d] %s", protocol, connNumber, msg)
+		// Write the value
+		writeFn(encoded)
 	}
 }
 
-// LogAndWrite logs and writes a message
-func LogAndWrite(
-	protocol string,
-	connNumber int,
+// Respond writes a message to the client with the given encoding
+func Respond(
+	logger *slog.Logger,
+	encoding internalmessage.Encoding,
 	writeFn func(message string),
 ) func(string) {
-	// Get the log function
-	logFn := Log(protocol, connNumber)
+	// Get the respond value function
+	respondValueFn := RespondValue(logger, encoding, writeFn)
 
 	return func(msg string) {
-		// Log the message
-		logFn(msg)
-
-		// Write the message
-		writeFn(msg)
+		respondValueFn(internalmessage.NewString(msg))
 	}
 }
 
-// SkipUntilANonSpacingCharacter skips until a non-spacing character is found
-func SkipUntilANonSpacingCharacter(data *string, pos int) int {
-	// Check if the data is nil
-	if data == nil {
-		return pos
-	}
-
-	// Skip until a non-spacing character is found
-	for _, r := range (*data)[pos:] {
-		if r != ' ' && r != '\n' && r != '\t' && r != '\r' {
-			break
+// RespondResult writes the result of a handler to the client, or its error message
+func RespondResult(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+) func(value *internalmessage.Value, err error) {
+	return func(value *internalmessage.Value, err error) {
+		if err != nil {
+			respondFn(err.Error())
+			return
 		}
-		pos++
+		respondValueFn(value)
 	}
-	return pos
 }
 
-// ReadKeyValue reads a key value pair
-func ReadKeyValue(
+// Peer is the remote side of a request
+type Peer struct {
+	// Address is the address of the peer
+	Address string
+
+	// User is the user authenticated by the transport, the requests without a token are made by it. It is empty if the transport does not authenticate its peers
+	User string
+}
+
+// HandleIncomingData handles the incoming data, the persistent connection is nil if the protocol has none, and the peer is nil if the protocol has no information about it. The logs of the request have its ID and header
+func HandleIncomingData(
+	logger *slog.Logger,
+	writeFn func(message string),
+	conn *PersistentConn,
+	peer *Peer,
 	data *string,
-	pos int,
-) (
-	isNestedObject bool,
-	key string,
-	value *string,
-	valuePos, finalPos int,
 	err error,
 ) {
-	// Check if the data is nil
-	if data == nil {
-		return false, "", nil, -1, -1, fmt.Errorf("data is nil")
-	}
+	logger = logger.With(RequestIDLogKey, NewRequestID())
 
-	// Check if the data starts with the given key
-	pos = SkipUntilANonSpacingCharacter(data, pos)
-	tempPos := pos
-	for _, r := range (*data)[pos:] {
-		if r == ' ' || r == '\n' || r == '\t' || r == '\r' || r == ':' {
-			break
-		}
-		pos++
+	// Check if there is an error
+	if err != nil {
+		logger.Warn("error reading the request", ErrorLogKey, err)
+		Respond(logger, internalmessage.TextEncoding, writeFn)(
+			"error reading: " + err.Error(),
+		)
+		return
 	}
-	key = (*data)[tempPos:pos]
 
-	// Start iterating over the value content
-	pos = SkipUntilANonSpacingCharacter(data, pos)
-	if (*data)[pos] != ':' {
-		return false, "", nil, pos, -1, MissingAtPositionError(":", pos)
-	}
-	pos++
-
-	// Check of the value starts with a double quote if it is a string
-	var depth int
-	var isString bool
-	pos = SkipUntilANonSpacingCharacter(data, pos)
-	valuePos = pos
-	if (*data)[pos] == '"' {
-		isString = true
-		pos++
-	} else if (*data)[pos] == '{' {
-		isNestedObject = true
-		depth++
-		pos++
-		pos = SkipUntilANonSpacingCharacter(data, pos)
+	//	Check if the data is nil
+	if data == nil {
+		logger.Warn("the request has no data")
+		Respond(logger, internalmessage.TextEncoding, writeFn)("data is nil")
+		return
 	}
 
-	// Get the value
-	tempPos = pos
-	for _, r := range (*data)[pos:] {
-		// Check if it is a nested object, a string or other type
-		if isNestedObject {
-			if r == '{' {
-				depth++
-			} else if r == '}' {
-				depth--
-
-				if depth == 0 {
-					break
-				}
-			}
-		} else if isString {
-			if r == '"' {
-				break
-			}
-		} else if r == ',' {
-			break
-		}
-		pos++
-	}
+	// Detect the encoding, the response is written with the same encoding
+	encoding := internalmessage.DetectEncoding(data)
+	respondValueFn := RespondValue(logger, encoding, writeFn)
+	respondFn := Respond(logger, encoding, writeFn)
 
-	// Check if the value ends with a double quote if it is a string, or a closing curly brace if it is a nested object
-	if isString {
-		if (*data)[pos] != '"' {
-			return false, key, nil, pos, valuePos, MissingAtPositionError(
-				"\"",
-				pos,
-			)
-		}
-	} else if isNestedObject {
-		if (*data)[pos] != '}' {
-			return false, key, nil, pos, valuePos, MissingAtPositionError(
-				"}",
-				pos,
-			)
-		}
+	// Process the data, binary encodings are logged as hexadecimal
+	if encoding.IsBinary() {
+		logger.Debug("received data", DataLogKey, fmt.Sprintf("%x", *data))
+	} else {
+		logger.Debug("received data", DataLogKey, *data)
 	}
 
-	// Get the value
-	valueStr := (*data)[tempPos:pos]
-	if isString || isNestedObject {
-		pos++
+	// Get the header and body
+	fields, err := ParseRequest(encoding, data)
+	if err != nil {
+		logger.Info("invalid request", ErrorLogKey, err)
+		respondFn(err.Error())
+		return
 	}
-	value = &valueStr
-
-	return isNestedObject, key, value, pos, valuePos, nil
-}
-
-// ReadKeyValues reads key value pairs
-func ReadKeyValues(
-	data *string,
-	pos int,
-	validationFn func(
-		isNestedObject bool,
-		key string,
-		value *string,
-		valuePos int,
-	) error,
-	fieldsToRead ...string,
-) (fields *map[string]*string, fieldsValuePos *map[string]int, err error) {
-	// Check if the data is nil
-	if data == nil {
-		return nil, nil, fmt.Errorf("data is nil")
+	if err = fields.Allow(RequestFields...); err != nil {
+		logger.Info("invalid request", ErrorLogKey, err)
+		respondFn(err.Error())
+		return
 	}
-	dataLen := len(*data)
-
-	// Create the fields to read map
-	fieldsToReadMap := make(map[string]bool)
-	for _, field := range fieldsToRead {
-		fieldsToReadMap[field] = true
+	header, err := fields.GetString("header")
+	if err != nil {
+		logger.Info("invalid request", ErrorLogKey, err)
+		respondFn(err.Error())
+		return
 	}
+	logger = logger.With(HeaderLogKey, header)
+	respondValueFn = RespondValue(logger, encoding, writeFn)
+	respondFn = Respond(logger, encoding, writeFn)
 
-	// Get the fields
-	var lastKey string
-	fields = new(map[string]*string)
-	*fields = make(map[string]*string)
-	fieldsValuePos = new(map[string]int)
-	*fieldsValuePos = make(map[string]int)
-	for i := 0; i < len(fieldsToRead); i++ {
-		// Get the key and value
-		isNestedObject, key, value, finalPos, valuePos, err := ReadKeyValue(
-			data,
-			pos,
-		)
+	// Get the body, it can be omitted by the requests that do not need it
+	body := make(internalmessage.Fields)
+	if fields.Has("body") {
+		body, err = fields.GetObject("body")
 		if err != nil {
-			return nil, nil, err
-		}
-
-		// Call the validation function
-		if validationFn != nil {
-			err = validationFn(isNestedObject, key, value, valuePos)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
-
-		// Add the key and value to the fields
-		(*fields)[key] = value
-		(*fieldsValuePos)[key] = valuePos
-		lastKey = key
-
-		// Set field as read
-		fieldsToReadMap[key] = false
-
-		// Update the position
-		pos = finalPos
-
-		// Check if the next character is a comma
-		pos = SkipUntilANonSpacingCharacter(data, pos)
-		if pos < dataLen-1 {
-			if (*data)[pos] != ',' {
-				return nil, nil, MissingAtPositionError(",", pos)
-			} else {
-				pos++
-			}
-		} else {
-			// Check if there are any missing fields
-			var missingFields []string
-			for field, isMissing := range fieldsToReadMap {
-				if isMissing {
-					missingFields = append(missingFields, field)
-				}
-			}
-			if len(missingFields) > 0 {
-				return nil, nil, fmt.Errorf(
-					"missing fields: %s",
-					strings.Join(missingFields, ", "),
-				)
-			}
-		}
-	}
-
-	// Check if there is any data after the last value
-	if pos < dataLen-1 {
-		pos = SkipUntilANonSpacingCharacter(data, pos)
-		if pos < dataLen {
-			return nil, nil, fmt.Errorf("unexpected data after the %s", lastKey)
+			logger.Info("invalid request", ErrorLogKey, err)
+			respondFn(err.Error())
+			return
 		}
 	}
-	return fields, fieldsValuePos, nil
-}
 
-// HandleIncomingData handles the incoming data
-func HandleIncomingData(
-	logFn, logAndWriteFn func(message string),
-	data *string,
-	err error,
-) {
-	// Check if there is an error
+	// Check the protocol version
+	version, err := GetProtocolVersion(fields)
 	if err != nil {
-		logAndWriteFn("error reading: " + err.Error())
-		return
-	}
-
-	//	Check if the data is nil
-	if data == nil {
-		logAndWriteFn("data is nil")
+		logger.Info("invalid request", ErrorLogKey, err)
+		respondFn(err.Error())
 		return
 	}
 
-	// Process the data
-	logFn("received data: " + *data)
-
-	// Get the header and body
-	fields, fieldsValuePos, err := ReadKeyValues(
-		data,
-		0,
-		func(
-			isNestedObject bool,
-			key string,
-			value *string,
-			valuePos int,
-		) error {
-			// Check if it is a nested object
-			if key == "header" && isNestedObject {
-				return fmt.Errorf(
-					"expected a string at position %d", valuePos,
-				)
-			} else if key == "body" && !isNestedObject {
-				return fmt.Errorf(
-					"expected a nested object at position %d",
-					valuePos,
-				)
-			}
-			return nil
-		},
-		"header",
-		"body",
-	)
+	// Get the user and its file storage, the requests without a token are made by the user of the peer or are anonymous
+	user, err := GetUser(fields, peer)
 	if err != nil {
-		logAndWriteFn(err.Error())
+		logger.Info("unauthenticated request", ErrorLogKey, err)
+		respondFn(err.Error())
 		return
 	}
+	fileStorage := GetFileStorage(user)
 
-	// Log the header and body
-	header := (*fields)["header"]
-	body := (*fields)["body"]
-	bodyValuePos := (*fieldsValuePos)["body"]
-	logFn("header: " + *header)
-	logFn("body: " + *body)
+	// Log the request, with its encoding, protocol version and user
+	logger.Info(
+		"request",
+		EncodingLogKey, string(encoding),
+		VersionLogKey, version,
+		UserLogKey, user,
+	)
 
 	// Call the appropriate handler
-	switch *header {
+	switch header {
 	case internal.MorseHeader:
-		HandleMorseCode(logAndWriteFn, body, bodyValuePos)
+		HandleMorseCode(
+			logger,
+			respondFn,
+			respondValueFn,
+			conn,
+			encoding,
+			fileStorage,
+			body,
+		)
 	case internal.AddFileHeader:
-		HandleAddFile(logFn, logAndWriteFn, body, bodyValuePos)
+		HandleAddFile(respondFn, respondValueFn, fileStorage, body)
 	case internal.RemoveFileHeader:
-		HandleRemoveFile(logFn, logAndWriteFn, body, bodyValuePos)
+		HandleRemoveFile(respondFn, respondValueFn, fileStorage, body)
+	case internal.GetFileHeader:
+		HandleGetFile(respondFn, respondValueFn, fileStorage, body)
+	case internal.ListFilesHeader:
+		HandleListFiles(respondFn, respondValueFn, fileStorage, body)
+	case internal.StatHeader:
+		HandleStat(respondFn, respondValueFn, fileStorage, body)
+	case internal.VerifyHeader:
+		HandleVerify(respondFn, respondValueFn, fileStorage)
+	case internal.UploadHeader:
+		HandleUpload(respondFn, respondValueFn, user, fileStorage, body)
+	case internal.DownloadHeader:
+		HandleDownload(respondFn, respondValueFn, fileStorage, body)
+	case internal.WatchHeader:
+		HandleWatch(logger, respondFn, conn, encoding, user, body)
+	case internal.ChatHeader:
+		HandleChat(logger, respondFn, conn, encoding, user)
+	case internal.EchoHeader:
+		HandleEcho(respondFn, respondValueFn, body)
+	case internal.BroadcastHeader, internal.PrivateHeader, internal.JoinHeader, internal.LeaveHeader:
+		respondFn(fmt.Sprintf("%s requires a chat session", header))
+	case internal.MkdirHeader:
+		HandleMkdir(respondFn, fileStorage, body)
+	case internal.RmdirHeader:
+		HandleRmdir(respondFn, fileStorage, body)
+	case internal.RenameHeader:
+		HandleRename(respondFn, fileStorage, body)
+	case internal.MoveHeader:
+		HandleMove(respondFn, fileStorage, body)
+	case internal.QuotaHeader:
+		HandleQuota(respondFn, respondValueFn, user)
 	case internal.MailHeader:
-		HandleMail(logAndWriteFn, body, bodyValuePos)
+		HandleMail(respondFn, respondValueFn, body)
+	case internal.HelloHeader:
+		HandleHello(respondFn, respondValueFn, body)
+	case internal.CapabilitiesHeader:
+		HandleCapabilities(respondValueFn)
 	default:
-		logAndWriteFn(
-			fmt.Sprintf("unknown header: %s", *header),
+		respondFn(
+			fmt.Sprintf("unknown header: %s", header),
 		)
 	}
 }
 
-// HandleTCPConnection handles the TCP connection
-func HandleTCPConnection(conn net.Conn, connNumber int) (
-	func(message string),
-	func(message string),
-	*string,
-	error,
-) {
-	// Set the protocol
-	protocol := "tcp"
-
-	// Get the logs functions
-	logFn := Log(protocol, connNumber)
-	logAndWriteFn := LogAndWrite(
-		protocol, connNumber, func(message string) {
-			_, err := conn.Write([]byte(message))
-			if err != nil {
-				logFn("error writing: " + err.Error())
-			}
-		},
-	)
-
-	// Create a buffer
-	buffer := make([]byte, 1024)
-
-	// Read the data from the connection
-	_, err := conn.Read(buffer)
-	if err != nil {
-		return nil, nil, nil, err
+// ReadTCPRequest reads a request from the connection of a stream protocol. The requests are framed with their length, the frames start with a zero byte that no encoding starts with. The unframed ones, written by the legacy clients that wait for the response without closing their writing side, end when the client closes it or stops writing for a moment. Binary encodings may contain null characters, so the data is not trimmed
+func ReadTCPRequest(conn DeadlineReadWriter) (string, error) {
+	// Set the deadline of the request, it is removed after reading it
+	deadline := time.Now().Add(RequestTimeout)
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return "", err
 	}
+	defer func() {
+		_ = conn.SetReadDeadline(time.Time{})
+	}()
 
-	// Get the data from the buffer and trim the null characters
-	trimmedBuffer := strings.Trim(string(buffer), "\x00")
-
-	return logFn, logAndWriteFn, &trimmedBuffer, nil
-}
-
-// HandleUDPIncomingData handles the UDP incoming data
-func HandleUDPIncomingData(
-	conn *net.UDPConn,
-	connNumber int,
-	clientAddr *net.UDPAddr,
-	data *string,
-) (
-	func(message string),
-	func(message string),
-	*string,
-	error,
-) {
-	// Set the protocol
-	protocol := "udp"
-
-	// Get the logs functions
-	logFn := Log(protocol, connNumber)
-	logAndWriteFn := LogAndWrite(
-		protocol, connNumber, func(message string) {
-			_, err := conn.WriteToUDP([]byte(message), clientAddr)
-			if err != nil {
-				logFn("error writing: " + err.Error())
-			}
-		},
-	)
-	return logFn, logAndWriteFn, data, nil
-}
-
-// HandleMorseCode handles the morse code
-func HandleMorseCode(
-	logAndWriteFn func(message string),
-	body *string,
-	bodyValuePos int,
-) {
-	// Get the fields
-	fields, _, err := ReadKeyValues(
-		body,
-		0,
-		NoNestedObjects(bodyValuePos),
-		"message",
-		"to",
-	)
-	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+	// Read the first byte, to check if the request is framed
+	firstByte := make([]byte, 1)
+	if _, err := io.ReadFull(conn, firstByte); err != nil {
+		return "", err
+	}
+	if firstByte[0] == 0 {
+		return internalmessage.ReadFrame(
+			io.MultiReader(bytes.NewReader(firstByte), conn),
+		)
 	}
-	message := (*fields)["message"]
-	to := *(*fields)["to"]
 
-	// Check the 'to' value
-	toValues := []string{internal.MorseToMorse, internal.MorseToText}
-	found := false
-	for _, toValue := range toValues {
-		if to == toValue {
-			found = true
-			break
+	// Read the unframed request until the client closes its writing side or is idle
+	data := bytes.NewBuffer(firstByte)
+	buffer := make([]byte, 4096)
+	for {
+		// Set the idle deadline, without extending the deadline of the request
+		idleDeadline := time.Now().Add(UnframedRequestIdleTimeout)
+		if idleDeadline.After(deadline) {
+			idleDeadline = deadline
+		}
+		if err := conn.SetReadDeadline(idleDeadline); err != nil {
+			return "", err
 		}
-	}
 
-	// Check if it was found
-	if !found {
-		logAndWriteFn(
-			fmt.Sprintf(
-				"invalid 'to' field value %s, expected: %s",
-				to,
-				strings.Join(toValues, ", "),
-			),
-		)
-	}
+		n, err := conn.Read(buffer)
+		data.Write(buffer[:n])
+		if data.Len() > MaxRequestSize {
+			return "", fmt.Errorf(
+				"request too large, the maximum is %d bytes",
+				MaxRequestSize,
+			)
+		}
 
-	// Convert the message
-	var convertedMessage string
-	if to == internal.MorseToMorse {
-		convertedMessage = internalloader.MorseCodeHandler.Encode(*message)
-	} else {
-		convertedMessage = internalloader.MorseCodeHandler.Decode(*message)
+		// Check if the client closed its writing side or is idle
+		var netErr net.Error
+		if errors.Is(err, io.EOF) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return data.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
 	}
-
-	// Write the converted message
-	logAndWriteFn(convertedMessage)
 }
 
-// HandleAddFile handles the add file
-func HandleAddFile(
-	logFn,
-	logAndWriteFn func(message string),
-	body *string,
-	bodyValuePos int,
+// ReadMailRecipient reads a mail recipient from a nested object
+func ReadMailRecipient(value *internalmessage.Value) (
+	*mailersend.Recipient,
+	error,
 ) {
-	// Get the fields
-	fields, _, err := ReadKeyValues(
-		body,
-		0,
-		NoNestedObjects(bodyValuePos),
-		"filename",
-		"content",
-	)
+	// Get the recipient fields
+	fields, err := value.AsObject()
 	if err != nil {
-		logAndWriteFn(err.Error())
-		return
-	}
-	filename := *(*fields)["filename"]
-	content := (*fields)["content"]
-
-	// Check if the filename contains a path separator
-	if strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
-		logAndWriteFn("invalid filename")
-		return
+		return nil, err
 	}
-
-	// Check if the files folder exists
-	CheckFilesFolder(logFn)
-
-	// Create the file
-	file, err := os.Create(fmt.Sprintf("%s/%s", FilesFolder, filename))
-	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+	if err = fields.Require("name", "email"); err != nil {
+		return nil, err
 	}
-
-	// Write the content to the file
-	_, err = file.WriteString(*content)
+	name, err := fields.GetString("name")
 	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+		return nil, err
 	}
-
-	// Close the file
-	err = file.Close()
+	email, err := fields.GetString("email")
 	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+		return nil, err
 	}
-
-	// Write the success message
-	logAndWriteFn("File added successfully")
+	return &mailersend.Recipient{
+		Name:  name,
+		Email: email,
+	}, nil
 }
 
-// HandleRemoveFile handles the remove file
-func HandleRemoveFile(
-	logFn, logAndWriteFn func(message string),
-	body *string,
-	bodyValuePos int,
-) {
+// SendMail sends the email of a body, 'to' can be a single recipient or a list of them
+func SendMail(body internalmessage.Fields) (*internalmessage.Value, error) {
 	// Get the fields
-	fields, _, err := ReadKeyValues(
-		body,
-		0,
-		NoNestedObjects(bodyValuePos),
-		"filename",
-	)
+	if err := body.Require("subject", "message", "to"); err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	subject, err := body.GetString("subject")
 	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+		return nil, &RequestError{Err: err}
 	}
-	filename := *(*fields)["filename"]
-
-	// Check if the filename contains a path separator
-	if strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
-		logAndWriteFn("invalid filename")
-		return
+	message, err := body.GetString("message")
+	if err != nil {
+		return nil, &RequestError{Err: err}
 	}
+	to, _ := body.Get("to")
 
-	// Check if the files folder exists
-	if !CheckFilesFolder(logFn) {
-		logAndWriteFn("files folder does not exist")
-		return
+	// Get the recipients, 'to' can be a single recipient or a list of them
+	toValues := []*internalmessage.Value{to}
+	if to.Type == internalmessage.ListValue {
+		toValues = to.Items
+		if len(toValues) == 0 {
+			return nil, &RequestError{
+				Err: errors.New("at least one recipient is required"),
+			}
+		}
+	}
+	recipients := make([]mailersend.Recipient, 0, len(toValues))
+	for _, toValue := range toValues {
+		recipient, err := ReadMailRecipient(toValue)
+		if err != nil {
+			return nil, &RequestError{Err: err}
+		}
+		recipients = append(recipients, *recipient)
 	}
 
-	// Remove the file
-	err = os.Remove(fmt.Sprintf("%s/%s", FilesFolder, filename))
-	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+	// Set the origin
+	from := mailersend.From{
+		Name:  internalloader.MailerSendName,
+		Email: internalloader.MailerSendEmail,
 	}
 
-	// Write the success message
-	logAndWriteFn("File removed successfully")
-}
+	// Send the email
+	mailMessage := internalloader.MailerSendClient.Email.NewMessage()
+	mailMessage.SetFrom(from)
+	mailMessage.SetRecipients(recipients)
+	mailMessage.SetSubject(subject)
+	mailMessage.SetText(message)
 
-// HandleMail handles the mail
-func HandleMail(
-	logAndWriteFn func(message string),
-	body *string,
-	bodyValuePos int,
-) {
-	// Get the fields
-	fields, fieldsValuePos, err := ReadKeyValues(
-		body,
-		0,
-		func(
-			isNestedObject bool,
-			key string,
-			value *string,
-			valuePos int,
-		) error {
-			// Check if it is a nested object
-			if key != "to" {
-				if isNestedObject {
-					return fmt.Errorf(
-						"expected a string at position %d",
-						bodyValuePos+valuePos,
-					)
-				}
-			} else if !isNestedObject {
-				return fmt.Errorf(
-					"expected a nested object at position %d",
-					bodyValuePos+valuePos,
-				)
-			}
-			return nil
-		},
-		"subject",
-		"message",
-		"to",
-	)
-	if err != nil {
-		logAndWriteFn(err.Error())
-		return
-	}
-	subject := *(*fields)["subject"]
-	message := (*fields)["message"]
-	to := (*fields)["to"]
-
-	// Get the 'to' fields
-	toFields, _, err := ReadKeyValues(
-		to,
-		0,
-		NoNestedObjects((*fieldsValuePos)["to"]+bodyValuePos),
-		"name",
-		"email",
+	_, err = internalloader.MailerSendClient.Email.Send(
+		context.Background(),
+		mailMessage,
 	)
 	if err != nil {
-		logAndWriteFn(err.Error())
-		return
+		return nil, fmt.Errorf("%w: %w", ErrMailProvider, err)
 	}
-	toName := *(*toFields)["name"]
-	toEmail := *(*toFields)["email"]
-
-	// Send the email on a separate goroutine
-	go func() {
-		// Set the origin and recipients
-		from := mailersend.From{
-			Name:  internalloader.MailerSendName,
-			Email: internalloader.MailerSendEmail,
-		}
-		recipients := []mailersend.Recipient{
-			{
-				Name:  toName,
-				Email: toEmail,
-			},
-		}
-
-		// Send the email
-		mailMessage := internalloader.MailerSendClient.Email.NewMessage()
-		mailMessage.SetFrom(from)
-		mailMessage.SetRecipients(recipients)
-		mailMessage.SetSubject(subject)
-		mailMessage.SetText(*message)
-
-		_, err := internalloader.MailerSendClient.Email.Send(
-			context.Background(),
-			mailMessage,
-		)
-		if err != nil {
-			logAndWriteFn(err.Error())
-			return
-		}
 
-		// Write the success message
-		logAndWriteFn("Email sent successfully")
-	}()
+	return internalmessage.NewString("Email sent successfully"), nil
+}
 
+// HandleMail handles the mail, the response is written after sending the email
+func HandleMail(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	body internalmessage.Fields,
+) {
+	RespondResult(respondFn, respondValueFn)(SendMail(body))
 }
@@ -0,0 +1,201 @@
+package server
+
+import (
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalmorse "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/morse"
+	"log/slog"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setMorseConverters sets the converter of the international alphabet, restoring the loaded converters after the test
+func setMorseConverters(t *testing.T) {
+	morseConverters := internalloader.MorseConverters
+	converter, err := internalmorse.NewConverter(internalmorse.NewInternationalAlphabet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	internalloader.MorseConverters = map[string]*internalmorse.Converter{internalmorse.InternationalAlphabet: converter}
+	t.Cleanup(func() { internalloader.MorseConverters = morseConverters })
+}
+
+// TestRespondValue tests that a response is written with the encoding of the request
+func TestRespondValue(t *testing.T) {
+	var responses []string
+	writeFn := func(message string) {
+		responses = append(responses, message)
+	}
+	RespondValue(slog.Default(), internalmessage.TextEncoding, writeFn)(
+		internalmessage.NewList(internalmessage.NewString(`"`)),
+	)
+	if len(responses) != 1 || responses[0] != `["\""]` {
+		t.Errorf("expected an escaped double quote, got %q", responses)
+	}
+}
+
+// TestRespondValueEncodingError tests that the client gets an error message if the response can not be encoded
+func TestRespondValueEncodingError(t *testing.T) {
+	var responses []string
+	writeFn := func(message string) {
+		responses = append(responses, message)
+	}
+	RespondValue(slog.Default(), internalmessage.JSONEncoding, writeFn)(
+		internalmessage.NewFloat(math.NaN()),
+	)
+	if len(responses) != 1 {
+		t.Fatalf("expected a response, got %d", len(responses))
+	}
+	if responses[0] != `"error encoding the response"` {
+		t.Errorf("expected an error message, got %q", responses[0])
+	}
+}
+
+// TestReadTCPRequest tests that the framed requests are read one by one, and the unframed ones until the client closes its writing side
+func TestReadTCPRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []string
+		expected []string
+	}{
+		{
+			"framed",
+			[]string{internalmessage.EncodeFrame("header: echo")},
+			[]string{"header: echo"},
+		},
+		{
+			"framed in pieces",
+			[]string{"\x00\x00", "\x00\x0c", "header: ", "echo"},
+			[]string{"header: echo"},
+		},
+		{
+			"back to back frames",
+			[]string{internalmessage.EncodeFrame("header: echo") + internalmessage.EncodeFrame("header: quota")},
+			[]string{"header: echo", "header: quota"},
+		},
+		{
+			"unframed",
+			[]string{"header: ", "echo"},
+			[]string{"header: echo"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+
+			// Write the data in pieces, the client closes the connection at the end
+			go func() {
+				defer client.Close()
+				for _, data := range test.data {
+					if _, err := client.Write([]byte(data)); err != nil {
+						return
+					}
+				}
+			}()
+
+			// Read the requests
+			for _, expected := range test.expected {
+				request, err := ReadTCPRequest(server)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if request != expected {
+					t.Errorf("expected %q, got %q", expected, request)
+				}
+			}
+		})
+	}
+}
+
+// TestReadTCPRequestUnframedIdle tests that an unframed request ends when the client stops writing, without closing its writing side
+func TestReadTCPRequestUnframedIdle(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	// Write the request in pieces, without closing the connection
+	go func() {
+		for _, data := range []string{"header: ", "echo"} {
+			if _, err := client.Write([]byte(data)); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Read the request, before the deadline of the request
+	start := time.Now()
+	request, err := ReadTCPRequest(server)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request != "header: echo" {
+		t.Errorf("expected %q, got %q", "header: echo", request)
+	}
+	if elapsed := time.Since(start); elapsed >= RequestTimeout/2 {
+		t.Errorf("expected the request before the deadline, got it after %v", elapsed)
+	}
+}
+
+// TestReadTCPRequestTooLarge tests that the requests larger than the maximum are rejected
+func TestReadTCPRequestTooLarge(t *testing.T) {
+	for _, data := range []string{
+		"\x00\xff\xff\xff",
+		strings.Repeat("a", MaxRequestSize+1),
+	} {
+		server, client := net.Pipe()
+		go func() {
+			defer client.Close()
+			_, _ = client.Write([]byte(data))
+		}()
+		if _, err := ReadTCPRequest(server); err == nil {
+			t.Errorf("expected an error for a request of %d bytes", len(data))
+		}
+		_ = server.Close()
+	}
+}
+
+// TestHandleMorseCodeInvalidTo tests that a morse request with an invalid 'to' value gets a single response
+func TestHandleMorseCodeInvalidTo(t *testing.T) {
+	var responses []string
+	writeFn := func(message string) {
+		responses = append(responses, message)
+	}
+	data := `header: morse, body: {message: "... --- ...", to: audio-text}`
+	HandleIncomingData(slog.Default(), writeFn, nil, nil, &data, nil)
+	if len(responses) != 1 {
+		t.Fatalf("expected a single response, got %q", responses)
+	}
+	if !strings.HasPrefix(responses[0], "invalid 'to' field value") {
+		t.Errorf("expected an invalid 'to' error, got %q", responses[0])
+	}
+}
+
+// TestHandleLegacyRequest tests that a request written by the baseline client, without a version field, is read with the legacy grammar
+func TestHandleLegacyRequest(t *testing.T) {
+	setMorseConverters(t)
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"unquoted null", "header: \"morse\",\nbody: {\n\tmessage: null,\n\tto: \"morse\"\n}", "-. ..- .-.. .-.."},
+		{"unquoted boolean", "header: \"morse\",\nbody: {\n\tmessage: true,\n\tto: \"morse\"\n}", "- .-. ..- ."},
+		{"trailing backslash", "header: \"morse\",\nbody: {\n\tmessage: \"-- \\\",\n\tto: \"text\"\n}", "M"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var responses []string
+			writeFn := func(message string) {
+				responses = append(responses, message)
+			}
+			HandleIncomingData(slog.Default(), writeFn, nil, nil, &test.data, nil)
+			if len(responses) != 1 || responses[0] != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, responses)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	"net"
+	"net/http"
+)
+
+type (
+	// HTTPTransport is the transport of an HTTP server, its requests are handled on the goroutines of the server so they keep its panic recovery
+	HTTPTransport struct {
+		protocol   string
+		address    string
+		handler    http.Handler
+		listener   net.Listener
+		server     *http.Server
+		connNumber *goconcurrency.SafeNumber
+	}
+
+	// connNumberKey is the context key of the number of an HTTP request in its transport
+	connNumberKey struct{}
+)
+
+// NewHTTPTransport creates the transport of the HTTP server of an address, its requests are handled by the handler
+func NewHTTPTransport(protocol, address string, handler http.Handler) *HTTPTransport {
+	return &HTTPTransport{
+		protocol:   protocol,
+		address:    address,
+		handler:    handler,
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// GetConnNumber returns the number of an HTTP request in its transport
+func GetConnNumber(r *http.Request) int {
+	connNumber, _ := r.Context().Value(connNumberKey{}).(int)
+	return connNumber
+}
+
+// Protocol returns the name of the protocol
+func (h *HTTPTransport) Protocol() string {
+	return h.protocol
+}
+
+// Address returns the address the transport listens on
+func (h *HTTPTransport) Address() string {
+	return h.address
+}
+
+// Listen starts listening for requests
+func (h *HTTPTransport) Listen() error {
+	listener, err := net.Listen("tcp", h.address)
+	if err != nil {
+		return err
+	}
+	h.listener = listener
+	h.server = &http.Server{Handler: h}
+	return nil
+}
+
+// Serve handles the requests with the HTTP server until the transport is closed
+func (h *HTTPTransport) Serve() error {
+	if err := h.server.Serve(h.listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP handles a request of the HTTP server with the handler of the transport, with the number of the request in its context
+func (h *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	connNumber := h.connNumber.IncrementAndGetValue()
+	h.handler.ServeHTTP(
+		w,
+		r.WithContext(context.WithValue(r.Context(), connNumberKey{}, connNumber)),
+	)
+}
+
+// Close stops the HTTP server, the hijacked connections are closed by their handlers
+func (h *HTTPTransport) Close() error {
+	if h.server == nil {
+		return nil
+	}
+	return h.server.Close()
+}
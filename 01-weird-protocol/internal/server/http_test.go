@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// serveTransport listens and serves a transport on a free local port, it is closed when the test ends
+func serveTransport(t *testing.T, newTransport func(address string) Listener) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	address := listener.Addr().String()
+	if err = listener.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	transport := newTransport(address)
+	if err = transport.Listen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	served := make(chan struct{})
+	go func() {
+		Serve(transport)
+		close(served)
+	}()
+	t.Cleanup(
+		func() {
+			if err := transport.Close(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			select {
+			case <-served:
+			case <-time.After(5 * time.Second):
+				t.Errorf("expected the transport to stop serving")
+			}
+		},
+	)
+	return address
+}
+
+// TestWebSocketTransport tests the requests of a connection to a served WebSocket transport, which are handled in order
+func TestWebSocketTransport(t *testing.T) {
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewWebSocketTransport(address, nil)
+		},
+	)
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+address+internal.WebSocketPath, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	for _, message := range []string{"first", "second"} {
+		request := `{"header": "echo", "body": {"message": "` + message + `"}}`
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, response, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(response) != `{"message":"`+message+`"}` {
+			t.Errorf("expected the echo of %s, got %s", message, response)
+		}
+	}
+}
+
+// TestGatewayTransport tests the requests of a served HTTP gateway transport
+func TestGatewayTransport(t *testing.T) {
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewGatewayTransport(address)
+		},
+	)
+	response, err := http.Get("http://" + address + OpenAPIPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, response.StatusCode)
+	}
+	var document map[string]any
+	if err = json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if document["openapi"] != OpenAPIVersion {
+		t.Errorf("expected the OpenAPI document, got %v", document)
+	}
+}
+
+// TestHTTPTransportPanic tests that a handler panic is recovered by the HTTP server, which keeps handling the next requests with their numbers
+func TestHTTPTransportPanic(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("handler panic")
+		},
+	)
+	mux.HandleFunc(
+		"/number", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, GetConnNumber(r))
+		},
+	)
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewHTTPTransport("http", address, mux)
+		},
+	)
+
+	if response, err := http.Get("http://" + address + "/panic"); err == nil {
+		_ = response.Body.Close()
+		t.Errorf("expected the connection of the panic to be closed, got status %d", response.StatusCode)
+	}
+	response, err := http.Get("http://" + address + "/number")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "2" {
+		t.Errorf("expected the second request, got %s", body)
+	}
+}
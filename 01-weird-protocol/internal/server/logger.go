@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+const (
+	// TransportLogKey is the log key of the protocol of the transport of a request
+	TransportLogKey = "transport"
+
+	// ConnLogKey is the log key of the connection number of a request in its transport
+	ConnLogKey = "conn"
+
+	// PeerLogKey is the log key of the address of the peer of a request
+	PeerLogKey = "peer"
+
+	// RequestIDLogKey is the log key of the ID of a request, it correlates all its logs
+	RequestIDLogKey = "request_id"
+
+	// HeaderLogKey is the log key of the header of a request
+	HeaderLogKey = "header"
+
+	// EncodingLogKey is the log key of the encoding of a request
+	EncodingLogKey = "encoding"
+
+	// VersionLogKey is the log key of the protocol version of a request
+	VersionLogKey = "version"
+
+	// UserLogKey is the log key of the user of a request, it is empty for the anonymous requests
+	UserLogKey = "user"
+
+	// DataLogKey is the log key of the data of a request
+	DataLogKey = "data"
+
+	// ResponseLogKey is the log key of a response, in the text format
+	ResponseLogKey = "response"
+
+	// ErrorLogKey is the log key of an error
+	ErrorLogKey = "error"
+
+	// RequestIDSize is the size in bytes of the request IDs
+	RequestIDSize = 8
+)
+
+// NewLogger creates the logger of a connection of a transport, its logs have the protocol, the connection number and the address of the peer. The peer is nil if the transport has no information about it
+func NewLogger(protocol string, connNumber int, peer *Peer) *slog.Logger {
+	logger := slog.Default().With(TransportLogKey, protocol, ConnLogKey, connNumber)
+	if peer != nil && peer.Address != "" {
+		logger = logger.With(PeerLogKey, peer.Address)
+	}
+	return logger
+}
+
+// NewRequestID creates a new random request ID, it is empty if the random bytes can not be read
+func NewRequestID() string {
+	id := make([]byte, RequestIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,625 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalmorse "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/morse"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxMorseBatchSize is the maximum number of messages of a batch conversion
+const MaxMorseBatchSize = 1000
+
+// ErrStreamingConnection is the error for a streaming conversion without a persistent connection
+var ErrStreamingConnection = errors.New("streaming requires a persistent connection")
+
+type (
+	// ConversionError is the error of a message that can not be converted, its message is the one of the conversion error
+	ConversionError struct {
+		Err error
+	}
+
+	// MorseRequest is a morse request read from its body
+	MorseRequest struct {
+		// To is the conversion of the message
+		To string
+
+		// FromAudio is true if the morse code is decoded from a WAV file
+		FromAudio bool
+
+		// Stream is true if it is a streaming conversion
+		Stream bool
+
+		// Batch is true if it is a batch of messages
+		Batch bool
+
+		// Message is the message of a single conversion, it is empty for the audio decodings, the batches and the streams
+		Message string
+
+		// Converter is the converter of the alphabet of the request
+		Converter *internalmorse.Converter
+
+		// Options are the options of the conversion
+		Options *internalmorse.Options
+	}
+)
+
+// Error returns the error message
+func (c *ConversionError) Error() string {
+	return c.Err.Error()
+}
+
+// Unwrap returns the conversion error
+func (c *ConversionError) Unwrap() error {
+	return c.Err
+}
+
+// ReadMorseOptions reads the optional alphabet, prosigns, strict and separators fields of a morse body
+func ReadMorseOptions(body internalmessage.Fields) (
+	*internalmorse.Converter,
+	*internalmorse.Options,
+	error,
+) {
+	// Get the converter of the alphabet, the international one by default
+	alphabet := internalmorse.InternationalAlphabet
+	if body.Has("alphabet") {
+		var err error
+		alphabet, err = body.GetString("alphabet")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	converter, ok := internalloader.MorseConverters[alphabet]
+	if !ok {
+		alphabets := make([]string, 0, len(internalloader.MorseConverters))
+		for name := range internalloader.MorseConverters {
+			alphabets = append(alphabets, name)
+		}
+		sort.Strings(alphabets)
+		return nil, nil, fmt.Errorf(
+			"unknown alphabet %s, expected: %s",
+			alphabet,
+			strings.Join(alphabets, ", "),
+		)
+	}
+
+	// Get the flags
+	options := &internalmorse.Options{}
+	for key, dest := range map[string]*bool{
+		"prosigns": &options.Prosigns,
+		"strict":   &options.Strict,
+	} {
+		if body.Has(key) {
+			value, err := body.GetBool(key)
+			if err != nil {
+				return nil, nil, err
+			}
+			*dest = value
+		}
+	}
+
+	// Get the separators
+	if body.Has("separators") {
+		separators, err := body.GetObject("separators")
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, dest := range map[string]*string{
+			"letter": &options.Separators.Letter,
+			"word":   &options.Separators.Word,
+		} {
+			if separators.Has(key) {
+				value, err := separators.GetString(key)
+				if err != nil {
+					return nil, nil, err
+				}
+				*dest = value
+			}
+		}
+	}
+	return converter, options, nil
+}
+
+// ReadAudioOptions reads the optional audio nested object of a morse body, with the wpm, farnsworth, frequency and samplerate fields
+func ReadAudioOptions(body internalmessage.Fields) (
+	*internalmorse.AudioOptions,
+	error,
+) {
+	// Get the audio fields
+	options := internalmorse.NewDefaultAudioOptions()
+	if !body.Has("audio") {
+		return options, nil
+	}
+	audio, err := body.GetObject("audio")
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the floating point fields
+	for key, dest := range map[string]*float64{
+		"wpm":        &options.WPM,
+		"farnsworth": &options.FarnsworthWPM,
+		"frequency":  &options.Frequency,
+	} {
+		if audio.Has(key) {
+			value, err := audio.GetFloat(key)
+			if err != nil {
+				return nil, err
+			}
+			*dest = value
+		}
+	}
+
+	// Get the sample rate
+	if audio.Has("samplerate") {
+		sampleRate, err := audio.GetInt("samplerate")
+		if err != nil {
+			return nil, err
+		}
+		options.SampleRate = int(sampleRate)
+	}
+	return options, options.Validate()
+}
+
+// RenderMorseAudio renders a message as a morse code WAV file. It is returned as base64, or saved to the file storage if the audio nested object has a filename
+func RenderMorseAudio(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+	message string,
+	converter *internalmorse.Converter,
+	options *internalmorse.Options,
+) (*internalmessage.Value, error) {
+	// Get the audio options
+	audioOptions, err := ReadAudioOptions(body)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	// Get the codes of the message and render them
+	words, err := converter.EncodeWords(message, options)
+	if err != nil {
+		return nil, &ConversionError{Err: err}
+	}
+	samples, err := internalmorse.RenderAudio(words, audioOptions)
+	if err != nil {
+		return nil, err
+	}
+	wav := internalmorse.EncodeWAV(samples, audioOptions.SampleRate)
+	response := internalmessage.Fields{
+		"duration": internalmessage.NewFloat(
+			float64(len(samples)) / float64(audioOptions.SampleRate),
+		),
+		"samplerate": internalmessage.NewInt(int64(audioOptions.SampleRate)),
+		"size":       internalmessage.NewInt(int64(len(wav))),
+	}
+
+	// Check if the audio must be saved to a file
+	audio, _ := body.GetObject("audio")
+	if !audio.Has("filename") {
+		response["audio"] = internalmessage.NewString(
+			base64.StdEncoding.EncodeToString(wav),
+		)
+		return internalmessage.NewObject(response), nil
+	}
+	filename, err := audio.GetString("filename")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	writeOptions, err := ReadWriteOptions(audio)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	// Save the audio to the file storage
+	if err = fileStorage.WriteFile(filename, wav, writeOptions); err != nil {
+		return nil, err
+	}
+	response["filename"] = internalmessage.NewString(filename)
+	return internalmessage.NewObject(response), nil
+}
+
+// ReadAudioFile reads the WAV file of the audio nested object of a morse body, from its filename in the file storage or from its base64 data
+func ReadAudioFile(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) ([]byte, error) {
+	// Get the audio fields
+	audio, err := body.GetObject("audio")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	if audio.Has("filename") == audio.Has("data") {
+		return nil, &RequestError{
+			Err: errors.New("expected either the filename or the data of the audio"),
+		}
+	}
+
+	// Decode the base64 data
+	if audio.Has("data") {
+		data, err := audio.GetString("data")
+		if err != nil {
+			return nil, &RequestError{Err: err}
+		}
+		decodedData, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, &RequestError{Err: err}
+		}
+		return decodedData, nil
+	}
+
+	// Get the filename
+	filename, err := audio.GetString("filename")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	return fileStorage.ReadFile(filename)
+}
+
+// DecodeMorseAudio decodes the morse code of a WAV file to text, with the detected speed and the confidence of the decoding
+func DecodeMorseAudio(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+	converter *internalmorse.Converter,
+	options *internalmorse.Options,
+) (*internalmessage.Value, error) {
+	// Read the WAV file
+	data, err := ReadAudioFile(fileStorage, body)
+	if err != nil {
+		return nil, err
+	}
+	samples, sampleRate, err := internalmorse.DecodeWAV(data)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	// Decode the audio
+	decoding, err := converter.DecodeAudio(samples, sampleRate, options)
+	if err != nil {
+		return nil, &ConversionError{Err: err}
+	}
+	return internalmessage.NewObject(
+		internalmessage.Fields{
+			"text":       internalmessage.NewString(decoding.Text),
+			"wpm":        internalmessage.NewFloat(decoding.WPM),
+			"frequency":  internalmessage.NewFloat(decoding.Frequency),
+			"confidence": internalmessage.NewFloat(decoding.Confidence),
+		},
+	), nil
+}
+
+// ConvertMorseMessage converts a message to morse code or to text
+func ConvertMorseMessage(
+	converter *internalmorse.Converter,
+	options *internalmorse.Options,
+	to, message string,
+) (string, error) {
+	if to == internal.MorseToMorse {
+		return converter.Encode(message, options)
+	}
+	return converter.Decode(message, options)
+}
+
+// ConvertMorseBatch converts many messages, each one with its own result or error
+func ConvertMorseBatch(
+	body internalmessage.Fields,
+	to string,
+	converter *internalmorse.Converter,
+	options *internalmorse.Options,
+) (*internalmessage.Value, error) {
+	// Get the messages
+	if to == internal.MorseToAudio {
+		return nil, &RequestError{
+			Err: fmt.Errorf(
+				"batch conversion only supports: %s, %s",
+				internal.MorseToMorse,
+				internal.MorseToText,
+			),
+		}
+	}
+	messages, err := body.GetList("messages")
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	if len(messages) > MaxMorseBatchSize {
+		return nil, &RequestError{
+			Err: fmt.Errorf(
+				"too many messages, the maximum is %d",
+				MaxMorseBatchSize,
+			),
+		}
+	}
+
+	// Convert each message
+	results := make([]*internalmessage.Value, 0, len(messages))
+	var errorsCount int64
+	for _, messageValue := range messages {
+		result := internalmessage.Fields{}
+		message, err := messageValue.AsString()
+		if err == nil {
+			result["message"] = internalmessage.NewString(message)
+			message, err = ConvertMorseMessage(converter, options, to, message)
+		}
+		if err != nil {
+			result["error"] = internalmessage.NewString(err.Error())
+			errorsCount++
+		} else {
+			result["result"] = internalmessage.NewString(message)
+		}
+		results = append(results, internalmessage.NewObject(result))
+	}
+	return internalmessage.NewObject(
+		internalmessage.Fields{
+			"results": internalmessage.NewList(results...),
+			"errors":  internalmessage.NewInt(errorsCount),
+		},
+	), nil
+}
+
+// HandleMorseStream handles a streaming conversion, the chunks read from the connection are converted and written as they arrive, until the stop message or the end of the connection. Every message after the request is framed, like the ones of the other persistent connections, starting with the confirmation. The converted chunks are written as output messages, and the error that ends the conversion as an error message
+func HandleMorseStream(
+	logger *slog.Logger,
+	respondFn func(message string),
+	conn *PersistentConn,
+	encoding internalmessage.Encoding,
+	to string,
+	converter *internalmorse.Converter,
+	options *internalmorse.Options,
+) {
+	// Check if the connection is persistent
+	if conn == nil {
+		respondFn(ErrStreamingConnection.Error())
+		return
+	}
+
+	// Create the stream
+	var stream internalmorse.Stream
+	var err error
+	switch to {
+	case internal.MorseToMorse:
+		stream, err = converter.NewEncoderStream(options)
+	case internal.MorseToText:
+		stream, err = converter.NewDecoderStream(options)
+	default:
+		err = fmt.Errorf(
+			"streaming only supports: %s, %s",
+			internal.MorseToMorse,
+			internal.MorseToText,
+		)
+	}
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Get the message write function, and confirm the stream
+	respondMessageValueFn := RespondValue(logger, encoding, conn.WriteMessage)
+	respondMessageValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"stream": internalmessage.NewString("started"),
+			},
+		),
+	)
+
+	for {
+		// Read the next chunk, checking if it has the stop message
+		chunk, readErr := conn.Read()
+		stopPos := strings.Index(chunk, internal.MorseStreamStop)
+		if stopPos != -1 {
+			chunk = chunk[:stopPos]
+		}
+
+		// Convert the chunk, and flush the stream when it ends
+		output, err := stream.Write(chunk)
+		if err == nil && (stopPos != -1 || readErr != nil) {
+			var flushedOutput string
+			flushedOutput, err = stream.Flush()
+			output += flushedOutput
+		}
+		if output != "" {
+			respondMessageValueFn(
+				internalmessage.NewObject(
+					internalmessage.Fields{
+						"output": internalmessage.NewString(output),
+					},
+				),
+			)
+		}
+		if err != nil {
+			respondMessageValueFn(
+				internalmessage.NewObject(
+					internalmessage.Fields{
+						"error": internalmessage.NewString(err.Error()),
+					},
+				),
+			)
+			return
+		}
+
+		// Check if the stream ended
+		if stopPos != -1 || errors.Is(readErr, io.EOF) {
+			logger.Info("stream stopped")
+			return
+		}
+		if readErr != nil {
+			logger.Warn("error reading", ErrorLogKey, readErr)
+			return
+		}
+	}
+}
+
+// ReadMorseRequest reads the morse request of a body, the 'messages' field converts a batch of messages instead of a single one, and the 'stream' field starts a streaming conversion
+func ReadMorseRequest(body internalmessage.Fields) (*MorseRequest, error) {
+	// Get the fields
+	if err := body.Require("to"); err != nil {
+		return nil, err
+	}
+	to, err := body.GetString("to")
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the 'to' value
+	toValues := []string{
+		internal.MorseToMorse,
+		internal.MorseToText,
+		internal.MorseToAudio,
+	}
+	if !slices.Contains(toValues, to) {
+		return nil, fmt.Errorf(
+			"invalid 'to' field value %s, expected: %s",
+			to,
+			strings.Join(toValues, ", "),
+		)
+	}
+	request := &MorseRequest{To: to}
+
+	// Check if the morse code is from a WAV file, it can only be decoded to text
+	request.FromAudio = body.Has("from")
+	if request.FromAudio {
+		from, err := body.GetString("from")
+		if err != nil {
+			return nil, err
+		}
+		if from != internal.MorseFromAudio {
+			return nil, fmt.Errorf(
+				"invalid 'from' field value %s, expected: %s",
+				from,
+				internal.MorseFromAudio,
+			)
+		}
+		if to != internal.MorseToText {
+			return nil, fmt.Errorf(
+				"the audio can only be converted to %s",
+				internal.MorseToText,
+			)
+		}
+	}
+
+	// Check if it is a streaming conversion
+	request.Stream = !request.FromAudio && body.Has("stream")
+	if request.Stream {
+		streamValue, err := body.GetString("stream")
+		if err != nil {
+			return nil, err
+		}
+		if streamValue != internal.MorseStreamStart {
+			return nil, fmt.Errorf(
+				"invalid 'stream' field value %s, expected: %s",
+				streamValue,
+				internal.MorseStreamStart,
+			)
+		}
+	}
+
+	// Check if it is a batch of messages
+	request.Batch = !request.FromAudio && !request.Stream && body.Has("messages")
+	if request.Batch && body.Has("message") {
+		return nil, errors.New("expected either the message or the messages")
+	}
+
+	// Get the message, which is not needed for the audio, the batches and the streams
+	if !request.FromAudio && !request.Batch && !request.Stream {
+		if err = body.Require("message"); err != nil {
+			return nil, err
+		}
+		if request.Message, err = body.GetString("message"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get the converter and the options
+	if request.Converter, request.Options, err = ReadMorseOptions(body); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ConvertMorseRequest converts a morse request that is not a stream, returning its result. The conversion errors of a single message are returned as a ConversionError
+func ConvertMorseRequest(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+	request *MorseRequest,
+) (*internalmessage.Value, error) {
+	switch {
+	case request.Stream:
+		return nil, ErrStreamingConnection
+	case request.FromAudio:
+		return DecodeMorseAudio(fileStorage, body, request.Converter, request.Options)
+	case request.Batch:
+		return ConvertMorseBatch(body, request.To, request.Converter, request.Options)
+	case request.To == internal.MorseToAudio:
+		return RenderMorseAudio(fileStorage, body, request.Message, request.Converter, request.Options)
+	}
+
+	// Convert the message
+	convertedMessage, err := ConvertMorseMessage(
+		request.Converter,
+		request.Options,
+		request.To,
+		request.Message,
+	)
+	if err != nil {
+		return nil, &ConversionError{Err: err}
+	}
+	return internalmessage.NewString(convertedMessage), nil
+}
+
+// ConvertMorseBody converts the morse request of a body without a persistent connection, so the streaming conversions are rejected
+func ConvertMorseBody(
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) (*internalmessage.Value, error) {
+	request, err := ReadMorseRequest(body)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	return ConvertMorseRequest(fileStorage, body, request)
+}
+
+// HandleMorseCode handles the morse code, writing the result of its conversion or starting its streaming conversion
+func HandleMorseCode(
+	logger *slog.Logger,
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	conn *PersistentConn,
+	encoding internalmessage.Encoding,
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the request
+	request, err := ReadMorseRequest(body)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Start the streaming conversion
+	if request.Stream {
+		HandleMorseStream(
+			logger,
+			respondFn,
+			conn,
+			encoding,
+			request.To,
+			request.Converter,
+			request.Options,
+		)
+		return
+	}
+
+	// Convert the request
+	RespondResult(respondFn, respondValueFn)(
+		ConvertMorseRequest(fileStorage, body, request),
+	)
+}
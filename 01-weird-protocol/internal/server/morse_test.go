@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMorseStream tests the streaming conversions of a served TCP transport, whose converted chunks and errors are written as framed messages
+func TestMorseStream(t *testing.T) {
+	setMorseConverters(t)
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewTCPTransport(address, nil)
+		},
+	)
+
+	tests := []struct {
+		name   string
+		body   string
+		chunks []string
+		output string
+		err    string
+	}{
+		{
+			"to morse",
+			`{"to": "morse", "stream": "start"}`,
+			[]string{"so", "s sos\n", "\x04"},
+			"... --- ... / ... --- ...",
+			"",
+		},
+		{
+			"to text",
+			`{"to": "text", "stream": "start"}`,
+			[]string{"... --", "- ...\x04"},
+			"SOS",
+			"",
+		},
+		{
+			"strict error",
+			`{"to": "morse", "stream": "start", "strict": true}`,
+			[]string{"s~o"},
+			"... ---",
+			"unknown characters: '~' at position 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", address)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer conn.Close()
+			if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			reader := bufio.NewReader(conn)
+
+			// Start the stream and read its confirmation
+			request := `{"header": "morse", "body": ` + test.body + `}`
+			if _, err = conn.Write([]byte(internalmessage.EncodeFrame(request))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			confirmation, err := internalmessage.ReadFrame(reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if confirmation != `{"stream":"started"}` {
+				t.Fatalf("expected the confirmation, got %q", confirmation)
+			}
+
+			// Send the chunks
+			for _, chunk := range test.chunks {
+				if _, err = conn.Write([]byte(chunk)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			// Read the output and error messages until the server closes the connection, the error is the last one
+			var output strings.Builder
+			var errorMessage string
+			for {
+				message, err := internalmessage.ReadFrame(reader)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if errorMessage != "" {
+					t.Fatalf("expected no messages after the error, got %q", message)
+				}
+				fields, err := internalmessage.ParseJSON(&message)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if fields.Has("error") {
+					errorMessage, err = fields.GetString("error")
+				} else {
+					var chunk string
+					chunk, err = fields.GetString("output")
+					output.WriteString(chunk)
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if output.String() != test.output {
+				t.Errorf("expected the output %q, got %q", test.output, output.String())
+			}
+			if errorMessage != test.err {
+				t.Errorf("expected the error %q, got %q", test.err, errorMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package server
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+)
+
+// UnixgramOOBSize is the size in bytes of the ancillary data of a datagram with the credentials of its sender
+var UnixgramOOBSize = unix.CmsgSpace(unix.SizeofUcred)
+
+// GetUnixConnCredentials gets the credentials of the peer of a Unix domain stream socket, from its SO_PEERCRED option
+func GetUnixConnCredentials(conn *net.UnixConn) (*PeerCredentials, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *unix.Ucred
+	var ucredErr error
+	if err = rawConn.Control(
+		func(fd uintptr) {
+			ucred, ucredErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+		},
+	); err != nil {
+		return nil, err
+	}
+	if ucredErr != nil {
+		return nil, ucredErr
+	}
+	return &PeerCredentials{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+}
+
+// EnableUnixgramCredentials enables the SO_PASSCRED option of a Unix domain datagram socket, so the kernel attaches the credentials of the sender to each datagram
+func EnableUnixgramCredentials(conn *net.UnixConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var setErr error
+	if err = rawConn.Control(
+		func(fd uintptr) {
+			setErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PASSCRED, 1)
+		},
+	); err != nil {
+		return err
+	}
+	return setErr
+}
+
+// ReadUnixgramCredentials reads the credentials of the sender of a datagram from its ancillary data
+func ReadUnixgramCredentials(oob []byte) (*PeerCredentials, error) {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		ucred, err := unix.ParseUnixCredentials(&messages[i])
+		if err == nil {
+			return &PeerCredentials{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+		}
+	}
+	return nil, ErrPeerCredentialsUnavailable
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package server
+
+import (
+	"net"
+)
+
+// UnixgramOOBSize is the size in bytes of the ancillary data of a datagram with the credentials of its sender, the credentials are only read on Linux
+var UnixgramOOBSize = 0
+
+// GetUnixConnCredentials gets the credentials of the peer of a Unix domain stream socket, they are only read on Linux
+func GetUnixConnCredentials(*net.UnixConn) (*PeerCredentials, error) {
+	return nil, ErrPeerCredentialsUnavailable
+}
+
+// EnableUnixgramCredentials enables the credentials of the senders of the datagrams, they are only read on Linux
+func EnableUnixgramCredentials(*net.UnixConn) error {
+	return nil
+}
+
+// ReadUnixgramCredentials reads the credentials of the sender of a datagram, they are only read on Linux
+func ReadUnixgramCredentials([]byte) (*PeerCredentials, error) {
+	return nil, ErrPeerCredentialsUnavailable
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/quic-go/quic-go"
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// QUICKeepAlivePeriod is the period of the packets sent to keep a QUIC connection alive, so the persistent streams outlive its idle timeout
+const QUICKeepAlivePeriod = 15 * time.Second
+
+// QUICTransport is the transport of the QUIC connections, each of their streams carries a request and gets its own connection number
+type QUICTransport struct {
+	address    string
+	tlsConfig  *tls.Config
+	listener   *quic.Listener
+	requests   chan Request
+	closed     chan struct{}
+	closeOnce  sync.Once
+	connNumber *goconcurrency.SafeNumber
+}
+
+// NewQUICTransport creates the transport of the QUIC connections of an address, with a copy of the TLS configuration that negotiates the application protocol
+func NewQUICTransport(address string, tlsConfig *tls.Config) *QUICTransport {
+	quicTLSConfig := tlsConfig.Clone()
+	quicTLSConfig.NextProtos = []string{internal.QUICProtocol}
+	return &QUICTransport{
+		address:    address,
+		tlsConfig:  quicTLSConfig,
+		requests:   make(chan Request),
+		closed:     make(chan struct{}),
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (q *QUICTransport) Protocol() string {
+	return "quic"
+}
+
+// Address returns the address the transport listens on
+func (q *QUICTransport) Address() string {
+	return q.address
+}
+
+// Listen starts listening for connections, their streams are accepted on separate goroutines
+func (q *QUICTransport) Listen() error {
+	listener, err := quic.ListenAddr(
+		q.address,
+		q.tlsConfig,
+		&quic.Config{KeepAlivePeriod: QUICKeepAlivePeriod},
+	)
+	if err != nil {
+		return err
+	}
+	q.listener = listener
+
+	// Accept the connections until the listener is closed
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				select {
+				case <-q.closed:
+					return
+				default:
+				}
+				slog.Error(
+					"error accepting connection",
+					TransportLogKey, q.Protocol(),
+					ErrorLogKey, err,
+				)
+				continue
+			}
+			go q.acceptStreams(conn)
+		}
+	}()
+	return nil
+}
+
+// acceptStreams accepts the streams of a connection until it is closed
+func (q *QUICTransport) acceptStreams(conn *quic.Conn) {
+	peer := &Peer{Address: conn.RemoteAddr().String()}
+	for {
+		// Accept a stream, the error is returned when the connection is closed
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+
+		// Pass the request of the stream, its writing side is closed after writing the response
+		request := NewStreamRequest(
+			q.Protocol(),
+			stream,
+			func() error {
+				stream.CancelRead(0)
+				return stream.Close()
+			},
+			q.connNumber.IncrementAndGetValue(),
+			peer,
+		)
+		select {
+		case q.requests <- request:
+		case <-q.closed:
+			stream.CancelRead(0)
+			stream.CancelWrite(0)
+			return
+		}
+	}
+}
+
+// Accept waits for the next stream
+func (q *QUICTransport) Accept() (Request, error) {
+	select {
+	case request := <-q.requests:
+		return request, nil
+	case <-q.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops listening
+func (q *QUICTransport) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+	})
+	return q.listener.Close()
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"slices"
+	"strings"
+)
+
+const (
+	// StringType is the schema type of the string fields
+	StringType = "string"
+
+	// IntegerType is the schema type of the integer fields
+	IntegerType = "integer"
+
+	// NumberType is the schema type of the floating point fields
+	NumberType = "number"
+
+	// BooleanType is the schema type of the boolean fields
+	BooleanType = "boolean"
+
+	// ArrayType is the schema type of the list fields
+	ArrayType = "array"
+
+	// ObjectType is the schema type of the nested object fields
+	ObjectType = "object"
+)
+
+type (
+	// RequestError is the error of a body whose fields are missing or not valid, its message is the one of the validation error
+	RequestError struct {
+		Err error
+	}
+
+	// FieldSchema is the schema of a field of the body of a request
+	FieldSchema struct {
+		// Name is the name of the field
+		Name string
+
+		// Type is the type of the field
+		Type string
+
+		// Description is the description of the field
+		Description string
+
+		// Required is true if the field must be present
+		Required bool
+
+		// Enum are the allowed values of a string field, any value is allowed if it is empty
+		Enum []string
+
+		// Items is the schema of the items of a list field
+		Items *FieldSchema
+
+		// AllowSingle is true if a list field also accepts a single item instead of a list
+		AllowSingle bool
+
+		// Fields are the fields of a nested object field, or of the objects of a list field
+		Fields []FieldSchema
+	}
+
+	// HandlerSchema is the schema of the body of the requests of a handler
+	HandlerSchema struct {
+		// Header is the header of the requests
+		Header string
+
+		// Summary is the summary of the handler
+		Summary string
+
+		// Fields are the fields of the body
+		Fields []FieldSchema
+	}
+)
+
+// writeOptionsFields are the fields of the optional write options of the bodies that write a file
+var writeOptionsFields = []FieldSchema{
+	{
+		Name:        "mode",
+		Type:        StringType,
+		Description: "Policy for an existing file",
+		Enum:        []string{"overwrite", "create", "append"},
+	},
+	{
+		Name:        "ifmatch",
+		Type:        StringType,
+		Description: "SHA-256 checksum the current content must have for the file to be written",
+	},
+}
+
+// HandlerSchemas are the schemas of the handlers, by their header
+var HandlerSchemas = map[string]*HandlerSchema{
+	internal.MorseHeader: {
+		Header:  internal.MorseHeader,
+		Summary: "Convert a message to morse code, text or audio",
+		Fields: []FieldSchema{
+			{
+				Name:        "to",
+				Type:        StringType,
+				Description: "Target of the conversion",
+				Required:    true,
+				Enum:        []string{internal.MorseToMorse, internal.MorseToText, internal.MorseToAudio},
+			},
+			{Name: "message", Type: StringType, Description: "Message to convert"},
+			{
+				Name:        "messages",
+				Type:        ArrayType,
+				Description: "Batch of messages to convert, instead of the message",
+				Items:       &FieldSchema{Type: StringType},
+			},
+			{Name: "alphabet", Type: StringType, Description: "Alphabet of the conversion, the international one by default"},
+			{Name: "prosigns", Type: BooleanType, Description: "Convert the prosigns"},
+			{Name: "strict", Type: BooleanType, Description: "Fail on the characters without a code"},
+			{
+				Name:        "separators",
+				Type:        ObjectType,
+				Description: "Separators of the morse code",
+				Fields: []FieldSchema{
+					{Name: "letter", Type: StringType},
+					{Name: "word", Type: StringType},
+				},
+			},
+			{
+				Name:        "from",
+				Type:        StringType,
+				Description: "Source of the morse code, to decode a WAV file",
+				Enum:        []string{internal.MorseFromAudio},
+			},
+			{
+				Name:        "audio",
+				Type:        ObjectType,
+				Description: "Options of the audio rendering, or the WAV file to decode",
+				Fields: []FieldSchema{
+					{Name: "wpm", Type: NumberType},
+					{Name: "farnsworth", Type: NumberType},
+					{Name: "frequency", Type: NumberType},
+					{Name: "samplerate", Type: IntegerType},
+					{Name: "filename", Type: StringType, Description: "File of the storage to save or to decode"},
+					{Name: "data", Type: StringType, Description: "Base64 WAV file to decode"},
+				},
+			},
+		},
+	},
+	internal.AddFileHeader: {
+		Header:  internal.AddFileHeader,
+		Summary: "Write a file",
+		Fields: append(
+			[]FieldSchema{
+				{Name: "filename", Type: StringType, Description: "Path of the file", Required: true},
+				{Name: "content", Type: StringType, Description: "Content of the file", Required: true},
+				{Name: "checksum", Type: StringType, Description: "SHA-256 checksum of the content, verified before it is written"},
+			},
+			writeOptionsFields...,
+		),
+	},
+	internal.RemoveFileHeader: {
+		Header:  internal.RemoveFileHeader,
+		Summary: "Remove a file",
+		Fields: []FieldSchema{
+			{Name: "filename", Type: StringType, Description: "Path of the file", Required: true},
+		},
+	},
+	internal.MailHeader: {
+		Header:  internal.MailHeader,
+		Summary: "Send a mail",
+		Fields: []FieldSchema{
+			{Name: "subject", Type: StringType, Required: true},
+			{Name: "message", Type: StringType, Required: true},
+			{
+				Name:        "to",
+				Type:        ArrayType,
+				Description: "Recipients of the mail, a single recipient object is also accepted",
+				Required:    true,
+				AllowSingle: true,
+				Items: &FieldSchema{
+					Type: ObjectType,
+					Fields: []FieldSchema{
+						{Name: "name", Type: StringType, Required: true},
+						{Name: "email", Type: StringType, Required: true},
+					},
+				},
+			},
+		},
+	},
+}
+
+// Error returns the error message
+func (r *RequestError) Error() string {
+	return r.Err.Error()
+}
+
+// Unwrap returns the validation error
+func (r *RequestError) Unwrap() error {
+	return r.Err
+}
+
+// ValidateValue checks that a value has the type of its schema, with the same conversions the handlers use to read it
+func ValidateValue(schema *FieldSchema, value *internalmessage.Value) error {
+	switch schema.Type {
+	case StringType:
+		str, err := value.AsString()
+		if err != nil {
+			return err
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, str) {
+			return fmt.Errorf(
+				"unexpected value %s, expected: %s",
+				str,
+				strings.Join(schema.Enum, ", "),
+			)
+		}
+	case IntegerType:
+		if _, err := value.AsInt(); err != nil {
+			return err
+		}
+	case NumberType:
+		if _, err := value.AsFloat(); err != nil {
+			return err
+		}
+	case BooleanType:
+		if _, err := value.AsBool(); err != nil {
+			return err
+		}
+	case ArrayType:
+		// Check if it is a single item instead of a list
+		items, err := value.AsList()
+		if err != nil && schema.AllowSingle && schema.Items != nil {
+			return ValidateValue(schema.Items, value)
+		}
+		if err != nil || schema.Items == nil {
+			return err
+		}
+		for i, item := range items {
+			if err = ValidateValue(schema.Items, item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+	case ObjectType:
+		fields, err := value.AsObject()
+		if err != nil {
+			return err
+		}
+		return ValidateFields(schema.Fields, fields)
+	}
+	return nil
+}
+
+// ValidateFields checks the fields of a body against their schemas. The null fields are missing, and the fields without a schema are ignored as the handlers do
+func ValidateFields(schemas []FieldSchema, fields internalmessage.Fields) error {
+	// Check the required fields
+	var missingFields []string
+	for i := range schemas {
+		if schemas[i].Required && !fields.Has(schemas[i].Name) {
+			missingFields = append(missingFields, schemas[i].Name)
+		}
+	}
+	if len(missingFields) > 0 {
+		return fmt.Errorf("missing fields: %s", strings.Join(missingFields, ", "))
+	}
+
+	// Check the present fields
+	for i := range schemas {
+		if !fields.Has(schemas[i].Name) {
+			continue
+		}
+		value, _ := fields.Get(schemas[i].Name)
+		if err := ValidateValue(&schemas[i], value); err != nil {
+			return fmt.Errorf("invalid '%s' field: %w", schemas[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateBody checks the body of a request against the schema of its handler, the errors are returned as a RequestError
+func ValidateBody(header string, body internalmessage.Fields) error {
+	handlerSchema, ok := HandlerSchemas[header]
+	if !ok {
+		return nil
+	}
+	if err := ValidateFields(handlerSchema.Fields, body); err != nil {
+		return &RequestError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"testing"
+)
+
+// TestValidateBody tests the bodies checked against the schemas of their handlers
+func TestValidateBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		body     string
+		expected string
+	}{
+		{"valid", internal.MorseHeader, `{"to": "morse", "message": "sos", "strict": true}`, ""},
+		{"unknown fields", internal.MorseHeader, `{"to": "morse", "message": "sos", "newer": 1}`, ""},
+		{"null optional field", internal.MorseHeader, `{"to": "morse", "alphabet": null}`, ""},
+		{"number as a string", internal.MorseHeader, `{"to": "morse", "message": 73}`, ""},
+		{"integer as a number", internal.MorseHeader, `{"to": "audio", "audio": {"wpm": 20}}`, ""},
+		{"missing fields", internal.MailHeader, `{"subject": "hi"}`, "missing fields: message, to"},
+		{"null required field", internal.AddFileHeader, `{"filename": "a.txt", "content": null}`, "missing fields: content"},
+		{"enum", internal.MorseHeader, `{"to": "binary"}`, "invalid 'to' field: unexpected value binary, expected: morse, text, audio"},
+		{"type", internal.MorseHeader, `{"to": "morse", "strict": "yes"}`, "invalid 'strict' field: expected a boolean at position 26"},
+		{"nested field", internal.MorseHeader, `{"to": "audio", "audio": {"samplerate": 8000.5}}`, "invalid 'audio' field: invalid 'samplerate' field: expected a integer at position 40"},
+		{"list item", internal.MorseHeader, `{"to": "morse", "messages": ["sos", {}]}`, "invalid 'messages' field: item 1: expected a string at position 36"},
+		{"single item", internal.MailHeader, `{"subject": "hi", "message": "hello", "to": {"name": "Alice", "email": "alice@example.com"}}`, ""},
+		{"invalid single item", internal.MailHeader, `{"subject": "hi", "message": "hello", "to": {"name": "Alice"}}`, "invalid 'to' field: missing fields: email"},
+		{"no schema", internal.EchoHeader, `{"message": 1}`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := internalmessage.ParseJSON(&test.body)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = ValidateBody(test.header, body)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var requestError *RequestError
+			if !errors.As(err, &requestError) || err.Error() != test.expected {
+				t.Errorf("expected the request error %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
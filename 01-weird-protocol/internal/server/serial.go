@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	internalserial "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/serial"
+	goserial "go.bug.st/serial"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+type (
+	// SerialTransport is the transport of a serial port, the line is a single persistent connection. The port is opened again after the line fails, and its requests are numbered across the openings
+	SerialTransport struct {
+		config     *internalserial.Config
+		conn       *SerialConn
+		mutex      sync.Mutex
+		opened     bool
+		released   chan struct{}
+		closed     chan struct{}
+		closeOnce  sync.Once
+		connNumber *goconcurrency.SafeNumber
+	}
+
+	// SerialConn is the line of an opened serial port
+	SerialConn struct {
+		transport *SerialTransport
+		port      goserial.Port
+		closeOnce sync.Once
+	}
+)
+
+// NewSerialTransport creates the transport of the serial port of a configuration
+func NewSerialTransport(config *internalserial.Config) *SerialTransport {
+	return &SerialTransport{
+		config:     config,
+		released:   make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (s *SerialTransport) Protocol() string {
+	return "serial"
+}
+
+// Address returns the TTY device of the serial port
+func (s *SerialTransport) Address() string {
+	return s.config.Device
+}
+
+// Listen does nothing, the port is opened when the line is accepted so it can be opened again after an error
+func (s *SerialTransport) Listen() error {
+	return nil
+}
+
+// AcceptConn opens the serial port, after the previous line is released and the reopen delay
+func (s *SerialTransport) AcceptConn() (TransportConn, error) {
+	// Wait for the previous line, and before opening the port again
+	if s.opened {
+		select {
+		case <-s.released:
+		case <-s.closed:
+			return nil, net.ErrClosed
+		}
+		select {
+		case <-time.After(internalserial.ReopenDelay):
+		case <-s.closed:
+			return nil, net.ErrClosed
+		}
+	}
+	s.opened = true
+
+	// Open the port, its failure is released as a line so it is opened again
+	port, err := internalserial.Open(s.config)
+	if err != nil {
+		s.released <- struct{}{}
+		return nil, err
+	}
+	conn := &SerialConn{transport: s, port: port}
+
+	// Check if the transport was closed while opening the port
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.closed:
+		_ = port.Close()
+		return nil, net.ErrClosed
+	default:
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// Close stops the transport, closing the opened port
+func (s *SerialTransport) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// ConnNumber returns zero, the line has no connections and its requests are numbered by the transport
+func (s *SerialConn) ConnNumber() int {
+	return 0
+}
+
+// Peer returns nil, the line has no information about its peer
+func (s *SerialConn) Peer() *Peer {
+	return nil
+}
+
+// Handle handles the requests of the line until the port fails or the transport is closed
+func (s *SerialConn) Handle() {
+	err := HandleSerialPort(s.port, s.transport.connNumber.IncrementAndGetValue)
+	select {
+	case <-s.transport.closed:
+	default:
+		slog.Error(
+			"error reading from serial port",
+			TransportLogKey, s.transport.Protocol(),
+			"device", s.transport.config.Device,
+			ErrorLogKey, err,
+		)
+	}
+}
+
+// Close closes the port and releases the line, so the port can be opened again
+func (s *SerialConn) Close() error {
+	var err error
+	s.closeOnce.Do(
+		func() {
+			err = s.port.Close()
+			s.transport.released <- struct{}{}
+		},
+	)
+	return err
+}
+
+// HandleSerialPort handles the requests of a serial port in order, until it fails. The line has no connections, so every request and response is a SLIP frame with a CRC-32, and the port is the persistent connection of the handlers that read more data or push messages. Each request is logged with the next connection number
+func HandleSerialPort(port io.ReadWriter, nextConnNumber func() int) error {
+	// Set the protocol
+	protocol := "serial"
+
+	// Get the write function, the responses and the pushes can be written concurrently
+	reader := internalserial.NewFrameReader(port)
+	var writeMutex sync.Mutex
+	writeFn := func(logger *slog.Logger) func(message string) {
+		return func(message string) {
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			if _, err := port.Write(internalserial.EncodeFrame([]byte(message))); err != nil {
+				logger.Warn("error writing", ErrorLogKey, err)
+			}
+		}
+	}
+
+	// Get the read function, each frame is a message
+	readFn := func() (string, error) {
+		payload, err := reader.ReadFrame()
+		return string(payload), err
+	}
+
+	// Handle the requests until the port fails
+	for {
+		data, err := readFn()
+		logger := NewLogger(protocol, nextConnNumber(), nil)
+		if errors.Is(err, internalserial.ErrFrameTooLarge) || errors.Is(err, internalserial.ErrInvalidFrame) || errors.Is(err, internalserial.ErrFrameTimeout) {
+			// The frame was discarded, the next one is read from its delimiter
+			logger.Warn("error reading", ErrorLogKey, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		connWriteFn := writeFn(logger)
+		HandleIncomingData(
+			logger,
+			connWriteFn,
+			&PersistentConn{
+				Read:         readFn,
+				ReadMessage:  readFn,
+				WriteMessage: connWriteFn,
+			},
+			nil,
+			&data,
+			nil,
+		)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalserial "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/serial"
+	"golang.org/x/sys/unix"
+	"os"
+	"testing"
+	"time"
+)
+
+// openPTY opens a pseudo-terminal pair, returning its master and the path of its slave
+func openPTY(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	t.Cleanup(func() { _ = master.Close() })
+
+	// Unlock the slave and get its number
+	fd := int(master.Fd())
+	if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	number, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", number)
+}
+
+// TestSerialTransport tests the requests sent through a pseudo-terminal pair to a served serial transport, with line noise and a corrupted frame before them
+func TestSerialTransport(t *testing.T) {
+	master, device := openPTY(t)
+	transport := NewSerialTransport(
+		&internalserial.Config{Device: device, BaudRate: internalserial.DefaultBaudRate},
+	)
+	if err := transport.Listen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = transport.Close() })
+	served := make(chan struct{})
+	go func() {
+		Serve(transport)
+		close(served)
+	}()
+
+	// Wait for the port to be opened in raw mode, the bytes sent before are processed by the line discipline
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		transport.mutex.Lock()
+		opened := transport.conn != nil
+		transport.mutex.Unlock()
+		if opened {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the port to be opened")
+		}
+	}
+
+	// Send a garbage size, a corrupted frame and a request
+	corrupted := internalserial.EncodeFrame([]byte(`{"header": "echo", "body": {"message": "lost"}}`))
+	corrupted[5] ^= 0x01
+	var line bytes.Buffer
+	line.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	line.Write(corrupted)
+	line.Write(internalserial.EncodeFrame([]byte(`{"header": "echo", "body": {"message": "hello"}}`)))
+	if _, err := master.Write(line.Bytes()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Read the response of the request
+	if err := master.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload, err := internalserial.NewFrameReader(master).ReadFrame()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response := string(payload)
+	fields, err := internalmessage.ParseJSON(&response)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message, err := fields.GetString("message"); err != nil || message != "hello" {
+		t.Errorf("expected the echo of the request, got %s", response)
+	}
+
+	// Check the transport stops serving once it is closed
+	if err = transport.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the transport to stop serving")
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/tls"
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	"net"
+)
+
+// TCPTransport is the transport of the TCP connections, each one carries a request
+type TCPTransport struct {
+	address    string
+	tlsConfig  *tls.Config
+	listener   net.Listener
+	connNumber *goconcurrency.SafeNumber
+}
+
+// NewTCPTransport creates the transport of the TCP connections of an address, they use TLS if its configuration is not nil
+func NewTCPTransport(address string, tlsConfig *tls.Config) *TCPTransport {
+	return &TCPTransport{
+		address:    address,
+		tlsConfig:  tlsConfig,
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (t *TCPTransport) Protocol() string {
+	return "tcp"
+}
+
+// Address returns the address the transport listens on
+func (t *TCPTransport) Address() string {
+	return t.address
+}
+
+// Listen starts listening for connections, over TLS if it is configured
+func (t *TCPTransport) Listen() error {
+	listener, err := net.Listen("tcp", t.address)
+	if err != nil {
+		return err
+	}
+	if t.tlsConfig != nil {
+		listener = tls.NewListener(listener, t.tlsConfig)
+	}
+	t.listener = listener
+	return nil
+}
+
+// Accept waits for the next connection
+func (t *TCPTransport) Accept() (Request, error) {
+	conn, err := t.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamRequest(
+		t.Protocol(),
+		conn,
+		conn.Close,
+		t.connNumber.IncrementAndGetValue(),
+		&Peer{Address: conn.RemoteAddr().String()},
+	), nil
+}
+
+// Close stops listening
+func (t *TCPTransport) Close() error {
+	return t.listener.Close()
+}
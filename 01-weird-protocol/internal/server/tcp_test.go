@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestTCPTransportUnframedRequest tests that a legacy client, which writes an unframed request and waits for the response without closing its writing side, gets the response
+func TestTCPTransportUnframedRequest(t *testing.T) {
+	setMorseConverters(t)
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewTCPTransport(address, nil)
+		},
+	)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	// Write the request like the baseline client, and read the response with a single read
+	request := "header: \"morse\",\nbody: {\n\tmessage: \"sos\",\n\tto: \"morse\"\n}"
+	if _, err = conn.Write([]byte(request)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response := string(buffer[:n]); response != "... --- ..." {
+		t.Errorf("expected the morse code, got %q", response)
+	}
+}
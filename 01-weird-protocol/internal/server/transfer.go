@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"strings"
+)
+
+// UploadActions are the actions of the upload sessions
+var UploadActions = []string{
+	internal.UploadBegin,
+	internal.UploadChunk,
+	internal.UploadStatus,
+	internal.UploadCommit,
+	internal.UploadAbort,
+}
+
+// NewUploadSessionFields creates the fields of the state of an upload session
+func NewUploadSessionFields(session string, offset int64) internalmessage.Fields {
+	return internalmessage.Fields{
+		"session": internalmessage.NewString(session),
+		"offset":  internalmessage.NewInt(offset),
+	}
+}
+
+// HandleUpload handles the actions of the resumable upload sessions. A session begins with the filename and the optional size, checksum, mode and ifmatch, receives the base64 chunks at their offsets, and is committed to write the file or aborted. Its committed offset can be queried to resume it after a dropped connection
+func HandleUpload(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	user string,
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the action
+	action, err := body.GetString("action")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Begin the session
+	if action == internal.UploadBegin {
+		HandleUploadBegin(respondFn, respondValueFn, user, body)
+		return
+	}
+
+	// Get the session
+	id, err := body.GetString("session")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	session, err := internalloader.Uploads.Get(user, id)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	switch action {
+	case internal.UploadChunk:
+		HandleUploadChunk(respondFn, respondValueFn, body, id, session.WriteChunk)
+	case internal.UploadStatus:
+		fields := NewUploadSessionFields(id, session.Offset())
+		fields["filename"] = internalmessage.NewString(session.Filename)
+		fields["size"] = internalmessage.NewInt(session.Size)
+		respondValueFn(internalmessage.NewObject(fields))
+	case internal.UploadCommit:
+		if _, err = internalloader.Uploads.Commit(user, id, fileStorage); err != nil {
+			respondFn(err.Error())
+			return
+		}
+		respondFn("File added successfully")
+	case internal.UploadAbort:
+		if err = internalloader.Uploads.Abort(user, id); err != nil {
+			respondFn(err.Error())
+			return
+		}
+		respondFn("Upload aborted successfully")
+	default:
+		respondFn(
+			fmt.Sprintf(
+				"unknown upload action %s, expected: %s",
+				action,
+				strings.Join(UploadActions, ", "),
+			),
+		)
+	}
+}
+
+// HandleUploadBegin handles the beginning of an upload session, the size of -1 is unknown. The declared size and the received chunks are checked against the quota of the storage of the user
+func HandleUploadBegin(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	user string,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	filename, err := body.GetString("filename")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	size := int64(-1)
+	if body.Has("size") {
+		if size, err = body.GetInt("size"); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+	var checksum string
+	if body.Has("checksum") {
+		if checksum, err = body.GetString("checksum"); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+	options, err := ReadWriteOptions(body)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Begin the session
+	userStorage := GetUserStorage(user)
+	session, err := internalloader.Uploads.Begin(
+		user, filename, size, checksum, options, func(size int64) error {
+			return internalstorage.CheckQuota(userStorage, filename, size, options)
+		},
+	)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	respondValueFn(internalmessage.NewObject(NewUploadSessionFields(session.ID, 0)))
+}
+
+// HandleUploadChunk handles a chunk of an upload session, with its base64 data and the optional checksum of the decoded data
+func HandleUploadChunk(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	body internalmessage.Fields,
+	id string,
+	writeChunkFn func(offset int64, data []byte) (int64, error),
+) {
+	// Get the fields
+	if err := body.Require("offset", "data"); err != nil {
+		respondFn(err.Error())
+		return
+	}
+	offset, err := body.GetInt("offset")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	encodedData, err := body.GetString("data")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(encodedData)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	if len(data) > internal.MaxChunkSize {
+		respondFn(fmt.Sprintf("chunk too large, the maximum size is %d bytes", internal.MaxChunkSize))
+		return
+	}
+
+	// Verify the checksum of the chunk
+	if body.Has("checksum") {
+		checksum, err := body.GetString("checksum")
+		if err != nil {
+			respondFn(err.Error())
+			return
+		}
+		if err = internalstorage.VerifyChecksum(data, checksum); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+
+	// Write the chunk
+	newOffset, err := writeChunkFn(offset, data)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	respondValueFn(internalmessage.NewObject(NewUploadSessionFields(id, newOffset)))
+}
+
+// HandleDownload handles the download of a chunk of a file, from the offset and up to the optional length, reading only the chunk. The response has the base64 data, the size and the stored checksum of the whole file, so the client can resume the download and verify it. The checksum is empty for the files written without one
+func HandleDownload(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	fileStorage internalstorage.Storage,
+	body internalmessage.Fields,
+) {
+	// Get the fields
+	filename, err := body.GetString("filename")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	var offset int64
+	if body.Has("offset") {
+		if offset, err = body.GetInt("offset"); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+	length := int64(internal.MaxChunkSize)
+	if body.Has("length") {
+		if length, err = body.GetInt("length"); err != nil {
+			respondFn(err.Error())
+			return
+		}
+	}
+	if length <= 0 || length > internal.MaxChunkSize {
+		respondFn(fmt.Sprintf("invalid length, expected between 1 and %d bytes", internal.MaxChunkSize))
+		return
+	}
+
+	// Get the size and the checksum of the file, the checksum is empty if it is unknown
+	info, err := fileStorage.Stat(filename)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+	if info.IsDir {
+		respondFn(fmt.Sprintf("%s is a directory", filename))
+		return
+	}
+	if offset < 0 || offset > info.Size {
+		respondFn(fmt.Sprintf("invalid offset, the file size is %d bytes", info.Size))
+		return
+	}
+
+	// Read the chunk
+	chunk, err := fileStorage.ReadFileRange(filename, offset, length)
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Write the chunk
+	end := offset + int64(len(chunk))
+	respondValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"filename": internalmessage.NewString(filename),
+				"offset":   internalmessage.NewInt(offset),
+				"size":     internalmessage.NewInt(info.Size),
+				"checksum": internalmessage.NewString(info.Checksum),
+				"data":     internalmessage.NewString(base64.StdEncoding.EncodeToString(chunk)),
+				"eof":      internalmessage.NewBool(end >= info.Size),
+			},
+		),
+	)
+}
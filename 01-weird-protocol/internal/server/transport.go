@@ -0,0 +1,297 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+)
+
+type (
+	// Listener is the listening part of the transports, shared by the ones of single requests and the ones of persistent connections
+	Listener interface {
+		// Protocol returns the name of the protocol, it prefixes the logs of the requests
+		Protocol() string
+
+		// Address returns the address the transport listens on
+		Address() string
+
+		// Listen starts listening for requests
+		Listen() error
+
+		// Close stops listening
+		Close() error
+	}
+
+	// Transport is a listener of the requests of a protocol. A new transport is added by implementing it, the requests it accepts are served like the ones of the other transports
+	Transport interface {
+		Listener
+
+		// Accept waits for the next request, it returns net.ErrClosed once the transport is closed
+		Accept() (Request, error)
+	}
+
+	// ConnTransport is a listener of the persistent connections of a protocol, each one carries requests that it handles in order
+	ConnTransport interface {
+		Listener
+
+		// AcceptConn waits for the next connection, it returns net.ErrClosed once the transport is closed
+		AcceptConn() (TransportConn, error)
+	}
+
+	// ServerTransport is a listener whose requests are handled by its own server, like the HTTP ones. The handlers run on the goroutines of the server
+	ServerTransport interface {
+		Listener
+
+		// Serve handles the requests until the transport is closed, it returns nil once it is closed
+		Serve() error
+	}
+
+	// TransportConn is a connection accepted by a ConnTransport
+	TransportConn interface {
+		// ConnNumber returns the number of the connection in its transport
+		ConnNumber() int
+
+		// Peer returns the peer of the connection, it is nil if the transport has no information about it
+		Peer() *Peer
+
+		// Handle handles the requests of the connection until it is closed or fails
+		Handle()
+
+		// Close releases the connection
+		Close() error
+	}
+
+	// Request is a request accepted by a transport, with the writer of its responses
+	Request interface {
+		// ConnNumber returns the number of the request in its transport
+		ConnNumber() int
+
+		// Peer returns the peer of the request, it is nil if the transport has no information about it
+		Peer() *Peer
+
+		// ReadRequest reads the data of the request
+		ReadRequest() (string, error)
+
+		// Reply writes a response to the peer
+		Reply(message string) error
+
+		// Conn returns the connection kept open after the request, it is nil if the transport has none
+		Conn() *PersistentConn
+
+		// Close releases the request, the stream transports close its connection or stream
+		Close() error
+	}
+
+	// StreamRequest is the request of a stream transport, its data is framed with its length. The connection is kept open for the handlers that read more data or push messages
+	StreamRequest struct {
+		protocol   string
+		conn       DeadlineReadWriter
+		closeFn    func() error
+		connNumber int
+		peer       *Peer
+	}
+
+	// DatagramRequest is the request of a datagram transport, a single datagram whose response is sent back to its sender
+	DatagramRequest struct {
+		data       string
+		replyFn    func(message string) error
+		connNumber int
+		peer       *Peer
+	}
+)
+
+// Serve handles the requests or the connections accepted by a listening transport, each one on a separate goroutine, or lets the server of the transport handle them. It returns once the transport is closed
+func Serve(listener Listener) {
+	switch transport := listener.(type) {
+	case Transport:
+		ServeAccepted(
+			transport.Protocol(), transport.Accept, func(request Request) {
+				HandleRequest(transport.Protocol(), request)
+			},
+		)
+	case ConnTransport:
+		ServeAccepted(
+			transport.Protocol(), transport.AcceptConn, func(conn TransportConn) {
+				conn.Handle()
+			},
+		)
+	case ServerTransport:
+		if err := transport.Serve(); err != nil {
+			slog.Error(
+				"error serving requests",
+				TransportLogKey, transport.Protocol(),
+				ErrorLogKey, err,
+			)
+		}
+	default:
+		slog.Error("unknown transport", TransportLogKey, listener.Protocol())
+	}
+}
+
+// ServeAccepted handles the requests or the connections accepted by the accept function, each one on a separate goroutine. They are closed after being handled, and it returns once the accept function returns net.ErrClosed
+func ServeAccepted[T interface {
+	ConnNumber() int
+	Peer() *Peer
+	Close() error
+}](
+	protocol string,
+	acceptFn func() (T, error),
+	handleFn func(accepted T),
+) {
+	for {
+		// Accept a request or connection
+		accepted, err := acceptFn()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Error(
+				"error accepting request",
+				TransportLogKey, protocol,
+				ErrorLogKey, err,
+			)
+			continue
+		}
+
+		// Handle it, it is closed after writing the responses
+		go func(accepted T) {
+			defer func(accepted T) {
+				if err := accepted.Close(); err != nil {
+					NewLogger(protocol, accepted.ConnNumber(), accepted.Peer()).Warn(
+						"error closing connection",
+						ErrorLogKey, err,
+					)
+				}
+			}(accepted)
+			handleFn(accepted)
+		}(accepted)
+	}
+}
+
+// HandleRequest reads a request of a transport and handles it
+func HandleRequest(protocol string, request Request) {
+	// Get the logger and the write function
+	logger := NewLogger(protocol, request.ConnNumber(), request.Peer())
+	writeFn := func(message string) {
+		if err := request.Reply(message); err != nil {
+			logger.Warn("error writing", ErrorLogKey, err)
+		}
+	}
+
+	// Read the data of the request
+	data, err := request.ReadRequest()
+	if err != nil {
+		HandleIncomingData(logger, writeFn, nil, request.Peer(), nil, err)
+		return
+	}
+
+	// Handle the data, the persistent connection is kept open until the request is closed
+	HandleIncomingData(logger, writeFn, request.Conn(), request.Peer(), &data, nil)
+}
+
+// NewStreamRequest creates the request of a stream transport, the close function closes its connection or stream
+func NewStreamRequest(
+	protocol string,
+	conn DeadlineReadWriter,
+	closeFn func() error,
+	connNumber int,
+	peer *Peer,
+) *StreamRequest {
+	return &StreamRequest{
+		protocol:   protocol,
+		conn:       conn,
+		closeFn:    closeFn,
+		connNumber: connNumber,
+		peer:       peer,
+	}
+}
+
+// ConnNumber returns the number of the request in its transport
+func (s *StreamRequest) ConnNumber() int {
+	return s.connNumber
+}
+
+// Peer returns the peer of the request
+func (s *StreamRequest) Peer() *Peer {
+	return s.peer
+}
+
+// ReadRequest reads the frame of the request, or the data until the client closes its writing side if it is not framed
+func (s *StreamRequest) ReadRequest() (string, error) {
+	return ReadTCPRequest(s.conn)
+}
+
+// Reply writes a response to the peer
+func (s *StreamRequest) Reply(message string) error {
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+// Conn returns the connection, its messages are framed and their write errors are logged
+func (s *StreamRequest) Conn() *PersistentConn {
+	readFn := func() (string, error) {
+		if err := s.conn.SetReadDeadline(time.Now().Add(StreamIdleTimeout)); err != nil {
+			return "", err
+		}
+		buffer := make([]byte, 4096)
+		n, err := s.conn.Read(buffer)
+		return string(buffer[:n]), err
+	}
+	writeFn := func(message string) {
+		if err := s.Reply(message); err != nil {
+			NewLogger(s.protocol, s.connNumber, s.peer).Warn("error writing", ErrorLogKey, err)
+		}
+	}
+	return NewStreamConn(readFn, writeFn)
+}
+
+// Close closes the connection or stream of the request
+func (s *StreamRequest) Close() error {
+	return s.closeFn()
+}
+
+// NewDatagramRequest creates the request of a datagram transport, the reply function sends a datagram back to its sender
+func NewDatagramRequest(
+	data string,
+	replyFn func(message string) error,
+	connNumber int,
+	peer *Peer,
+) *DatagramRequest {
+	return &DatagramRequest{
+		data:       data,
+		replyFn:    replyFn,
+		connNumber: connNumber,
+		peer:       peer,
+	}
+}
+
+// ConnNumber returns the number of the request in its transport
+func (d *DatagramRequest) ConnNumber() int {
+	return d.connNumber
+}
+
+// Peer returns the peer of the request
+func (d *DatagramRequest) Peer() *Peer {
+	return d.peer
+}
+
+// ReadRequest returns the data of the datagram
+func (d *DatagramRequest) ReadRequest() (string, error) {
+	return d.data, nil
+}
+
+// Reply sends a response to the sender of the datagram
+func (d *DatagramRequest) Reply(message string) error {
+	return d.replyFn(message)
+}
+
+// Conn returns nil, the datagram transports have no persistent connections
+func (d *DatagramRequest) Conn() *PersistentConn {
+	return nil
+}
+
+// Close does nothing, the datagram transports have no connections
+func (d *DatagramRequest) Close() error {
+	return nil
+}
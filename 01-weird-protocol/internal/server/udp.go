@@ -0,0 +1,66 @@
+package server
+
+import (
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	"net"
+)
+
+// UDPTransport is the transport of the UDP datagrams, each one carries a request
+type UDPTransport struct {
+	address    string
+	conn       *net.UDPConn
+	buffer     []byte
+	connNumber *goconcurrency.SafeNumber
+}
+
+// NewUDPTransport creates the transport of the UDP datagrams of an address
+func NewUDPTransport(address string) *UDPTransport {
+	return &UDPTransport{
+		address:    address,
+		buffer:     make([]byte, internal.MaxDatagramSize),
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (u *UDPTransport) Protocol() string {
+	return "udp"
+}
+
+// Address returns the address the transport listens on
+func (u *UDPTransport) Address() string {
+	return u.address
+}
+
+// Listen starts listening for datagrams
+func (u *UDPTransport) Listen() error {
+	address, err := net.ResolveUDPAddr("udp", u.address)
+	if err != nil {
+		return err
+	}
+	u.conn, err = net.ListenUDP("udp", address)
+	return err
+}
+
+// Accept waits for the next datagram, the response is sent back to its sender
+func (u *UDPTransport) Accept() (Request, error) {
+	n, clientAddr, err := u.conn.ReadFromUDP(u.buffer)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatagramRequest(
+		string(u.buffer[:n]),
+		func(message string) error {
+			_, err := u.conn.WriteToUDP([]byte(message), clientAddr)
+			return err
+		},
+		u.connNumber.IncrementAndGetValue(),
+		&Peer{Address: clientAddr.String()},
+	), nil
+}
+
+// Close stops listening
+func (u *UDPTransport) Close() error {
+	return u.conn.Close()
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	goconcurrency "github.com/ralvarezdev/go-concurrency"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/user"
+)
+
+// ErrPeerCredentialsUnavailable is the error for a peer of a Unix domain socket whose credentials can not be read
+var ErrPeerCredentialsUnavailable = errors.New("peer credentials unavailable")
+
+// PeerCredentials are the credentials of the process of a peer of a Unix domain socket
+type PeerCredentials struct {
+	// PID is the process ID
+	PID int32
+
+	// UID is the user ID
+	UID uint32
+
+	// GID is the group ID
+	GID uint32
+}
+
+// String returns the credentials as they are logged
+func (p *PeerCredentials) String() string {
+	return fmt.Sprintf("pid=%d uid=%d gid=%d", p.PID, p.UID, p.GID)
+}
+
+// GetCredentialsUser gets the user authenticated by the credentials of a peer, it is the user with the name of the system user of the peer. It is empty if the authentication is disabled or there is no such user
+func GetCredentialsUser(credentials *PeerCredentials) string {
+	if !internalloader.UnixSocketAuth || credentials == nil {
+		return ""
+	}
+
+	// Get the name of the system user
+	systemUser, err := user.LookupId(fmt.Sprint(credentials.UID))
+	if err != nil {
+		return ""
+	}
+
+	// Check if it is a user of the protocol
+	for _, name := range internalloader.Users {
+		if name == systemUser.Username {
+			return name
+		}
+	}
+	return ""
+}
+
+// NewUnixPeer creates the peer of a Unix domain socket, authenticated by its credentials. The credentials are logged
+func NewUnixPeer(
+	logger *slog.Logger,
+	address string,
+	credentials *PeerCredentials,
+	err error,
+) *Peer {
+	if err != nil {
+		logger.Warn("error reading peer credentials", ErrorLogKey, err)
+		return &Peer{Address: address}
+	}
+	peer := &Peer{Address: address, User: GetCredentialsUser(credentials)}
+	logger.Info(
+		"peer credentials",
+		"pid", credentials.PID,
+		"uid", credentials.UID,
+		"gid", credentials.GID,
+		UserLogKey, peer.User,
+	)
+	return peer
+}
+
+// PrepareUnixSocketPath removes the socket file left by a previous server at a path, so it can be listened again. Other files are not removed
+func PrepareUnixSocketPath(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// ListenUnixSocket listens on a Unix domain socket, stream or datagram, and sets the permissions of its file
+func ListenUnixSocket(network, path string, mode fs.FileMode) (net.Listener, *net.UnixConn, error) {
+	if err := PrepareUnixSocketPath(path); err != nil {
+		return nil, nil, err
+	}
+	address := &net.UnixAddr{Name: path, Net: network}
+
+	// Listen on the socket
+	var listener net.Listener
+	var conn *net.UnixConn
+	var err error
+	if network == "unixgram" {
+		conn, err = net.ListenUnixgram(network, address)
+	} else {
+		listener, err = net.ListenUnix(network, address)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Set the permissions of the socket file
+	if err = os.Chmod(path, mode); err != nil {
+		if listener != nil {
+			_ = listener.Close()
+		} else {
+			_ = conn.Close()
+		}
+		return nil, nil, err
+	}
+	return listener, conn, nil
+}
+
+// UnixTransport is the transport of the connections of a Unix domain stream socket, each one carries a request and its peer is authenticated by its credentials
+type UnixTransport struct {
+	path       string
+	mode       fs.FileMode
+	listener   net.Listener
+	connNumber *goconcurrency.SafeNumber
+}
+
+// NewUnixTransport creates the transport of a Unix domain stream socket, with the permissions of its file
+func NewUnixTransport(path string, mode fs.FileMode) *UnixTransport {
+	return &UnixTransport{
+		path:       path,
+		mode:       mode,
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (u *UnixTransport) Protocol() string {
+	return "unix"
+}
+
+// Address returns the path of the socket
+func (u *UnixTransport) Address() string {
+	return u.path
+}
+
+// Listen starts listening for connections
+func (u *UnixTransport) Listen() error {
+	listener, _, err := ListenUnixSocket("unix", u.path, u.mode)
+	if err != nil {
+		return err
+	}
+	u.listener = listener
+	return nil
+}
+
+// Accept waits for the next connection, and reads the credentials of its peer
+func (u *UnixTransport) Accept() (Request, error) {
+	conn, err := u.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	unixConn := conn.(*net.UnixConn)
+	connNumber := u.connNumber.IncrementAndGetValue()
+
+	// Get the peer
+	credentials, err := GetUnixConnCredentials(unixConn)
+	peer := NewUnixPeer(
+		NewLogger(u.Protocol(), connNumber, nil),
+		unixConn.RemoteAddr().String(),
+		credentials,
+		err,
+	)
+
+	return NewStreamRequest(u.Protocol(), unixConn, unixConn.Close, connNumber, peer), nil
+}
+
+// Close stops listening
+func (u *UnixTransport) Close() error {
+	return u.listener.Close()
+}
+
+// UnixgramTransport is the transport of the datagrams of a Unix domain datagram socket, each one carries a request and its sender is authenticated by the credentials of the datagram. The sender must be bound to an address to receive the response
+type UnixgramTransport struct {
+	path       string
+	mode       fs.FileMode
+	conn       *net.UnixConn
+	buffer     []byte
+	oobBuffer  []byte
+	connNumber *goconcurrency.SafeNumber
+}
+
+// NewUnixgramTransport creates the transport of a Unix domain datagram socket, with the permissions of its file
+func NewUnixgramTransport(path string, mode fs.FileMode) *UnixgramTransport {
+	return &UnixgramTransport{
+		path:       path,
+		mode:       mode,
+		buffer:     make([]byte, internal.MaxDatagramSize),
+		oobBuffer:  make([]byte, UnixgramOOBSize),
+		connNumber: goconcurrency.NewSafeNumber(0),
+	}
+}
+
+// Protocol returns the name of the protocol
+func (u *UnixgramTransport) Protocol() string {
+	return "unixgram"
+}
+
+// Address returns the path of the socket
+func (u *UnixgramTransport) Address() string {
+	return u.path
+}
+
+// Listen starts listening for datagrams, the credentials of the senders are attached to them
+func (u *UnixgramTransport) Listen() error {
+	_, conn, err := ListenUnixSocket("unixgram", u.path, u.mode)
+	if err != nil {
+		return err
+	}
+	if err = EnableUnixgramCredentials(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	u.conn = conn
+	return nil
+}
+
+// Accept waits for the next datagram, and reads the credentials of its sender
+func (u *UnixgramTransport) Accept() (Request, error) {
+	n, oobn, _, clientAddr, err := u.conn.ReadMsgUnix(u.buffer, u.oobBuffer)
+	if err != nil {
+		return nil, err
+	}
+	connNumber := u.connNumber.IncrementAndGetValue()
+
+	// Get the peer
+	var address string
+	if clientAddr != nil {
+		address = clientAddr.Name
+	}
+	credentials, err := ReadUnixgramCredentials(u.oobBuffer[:oobn])
+	peer := NewUnixPeer(NewLogger(u.Protocol(), connNumber, nil), address, credentials, err)
+
+	return NewDatagramRequest(
+		string(u.buffer[:n]),
+		func(message string) error {
+			if address == "" {
+				return errors.New("the sender is not bound to an address")
+			}
+			_, err := u.conn.WriteToUnix([]byte(message), clientAddr)
+			return err
+		},
+		connNumber,
+		peer,
+	), nil
+}
+
+// Close stops listening
+func (u *UnixgramTransport) Close() error {
+	return u.conn.Close()
+}
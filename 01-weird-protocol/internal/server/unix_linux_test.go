@@ -0,0 +1,253 @@
+package server
+
+import (
+	"fmt"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	// addFileRequest is the request that adds a file, to check the storage of the user of a peer
+	addFileRequest = `{"header": "addfile", "body": {"filename": "notes.txt", "content": "hello"}}`
+
+	// addFileResponse is the response of the add file request
+	addFileResponse = `"File added successfully"`
+)
+
+// setUnixSocketUsers sets the Unix socket authentication and the users, each one with its memory storage, and the memory storage of the anonymous user
+func setUnixSocketUsers(t *testing.T, auth bool, names ...string) {
+	t.Helper()
+	unixSocketAuth, users := internalloader.UnixSocketAuth, internalloader.Users
+	fileStorage, userFileStorages := internalloader.FileStorage, internalloader.UserFileStorages
+	t.Cleanup(
+		func() {
+			internalloader.UnixSocketAuth, internalloader.Users = unixSocketAuth, users
+			internalloader.FileStorage, internalloader.UserFileStorages = fileStorage, userFileStorages
+		},
+	)
+	internalloader.UnixSocketAuth = auth
+	internalloader.Users = make(map[string]string)
+	internalloader.FileStorage = internalstorage.NewMemoryStorage()
+	internalloader.UserFileStorages = make(map[string]internalstorage.Storage)
+	for i, name := range names {
+		internalloader.Users[fmt.Sprintf("token%d", i)] = name
+		internalloader.UserFileStorages[name] = internalstorage.NewMemoryStorage()
+	}
+}
+
+// getCurrentUsername gets the name of the system user of the test process
+func getCurrentUsername(t *testing.T) string {
+	t.Helper()
+	currentUser, err := user.Current()
+	if err != nil {
+		t.Skipf("the current user is not available: %v", err)
+	}
+	return currentUser.Username
+}
+
+// serveUnixSocket serves a transport of a Unix domain socket in a temporary directory, returning the path of the socket
+func serveUnixSocket(t *testing.T, newTransport func(path string) Listener) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server.sock")
+	transport := newTransport(path)
+	if err := transport.Listen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	served := make(chan struct{})
+	go func() {
+		Serve(transport)
+		close(served)
+	}()
+	t.Cleanup(
+		func() {
+			if err := transport.Close(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			select {
+			case <-served:
+			case <-time.After(5 * time.Second):
+				t.Errorf("expected the transport to stop serving")
+			}
+		},
+	)
+	return path
+}
+
+// assertFileOwner checks that the file of the add file request is only in the storage of a user, the anonymous one if it is empty
+func assertFileOwner(t *testing.T, owner string) {
+	t.Helper()
+	storages := map[string]internalstorage.Storage{"": internalloader.FileStorage}
+	for name, storage := range internalloader.UserFileStorages {
+		storages[name] = storage
+	}
+	for name, storage := range storages {
+		_, err := storage.Stat("notes.txt")
+		if name == owner && err != nil {
+			t.Errorf("expected the file in the storage of %q, got %v", name, err)
+		}
+		if name != owner && err == nil {
+			t.Errorf("expected no file in the storage of %q", name)
+		}
+	}
+}
+
+// TestGetCredentialsUser tests the users authenticated by the credentials of the peers
+func TestGetCredentialsUser(t *testing.T) {
+	username := getCurrentUsername(t)
+	credentials := &PeerCredentials{PID: int32(os.Getpid()), UID: uint32(os.Getuid()), GID: uint32(os.Getgid())}
+	tests := []struct {
+		name        string
+		auth        bool
+		users       []string
+		credentials *PeerCredentials
+		expected    string
+	}{
+		{"protocol user", true, []string{"other", username}, credentials, username},
+		{"authentication disabled", false, []string{username}, credentials, ""},
+		{"not a protocol user", true, []string{"other"}, credentials, ""},
+		{"no credentials", true, []string{username}, nil, ""},
+		{"unknown system user", true, []string{username}, &PeerCredentials{UID: 1 << 30}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setUnixSocketUsers(t, test.auth, test.users...)
+			if user := GetCredentialsUser(test.credentials); user != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, user)
+			}
+		})
+	}
+}
+
+// TestUnixTransportCredentials tests that the requests of a Unix domain stream socket are made by the user of the credentials of their peer, and by the anonymous user if the authentication is disabled
+func TestUnixTransportCredentials(t *testing.T) {
+	username := getCurrentUsername(t)
+	tests := []struct {
+		name     string
+		auth     bool
+		expected string
+	}{
+		{"authentication enabled", true, username},
+		{"authentication disabled", false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setUnixSocketUsers(t, test.auth, username)
+			path := serveUnixSocket(
+				t, func(path string) Listener {
+					return NewUnixTransport(path, 0o600)
+				},
+			)
+
+			// Check the permissions of the socket file
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != 0o600 {
+				t.Errorf("expected the permissions 0600, got %o", info.Mode().Perm())
+			}
+
+			// Send the request and read its response
+			conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer conn.Close()
+			if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err = conn.Write([]byte(addFileRequest)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = conn.CloseWrite(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(response) != addFileResponse {
+				t.Errorf("expected the file to be added, got %q", response)
+			}
+			assertFileOwner(t, test.expected)
+		})
+	}
+}
+
+// TestUnixgramTransportCredentials tests that the requests of a Unix domain datagram socket are made by the user of the credentials of their sender
+func TestUnixgramTransportCredentials(t *testing.T) {
+	username := getCurrentUsername(t)
+	setUnixSocketUsers(t, true, username)
+	path := serveUnixSocket(
+		t, func(path string) Listener {
+			return NewUnixgramTransport(path, 0o600)
+		},
+	)
+
+	// Send the request from a bound address, so the response can be received
+	clientPath := filepath.Join(t.TempDir(), "client.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: clientPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = conn.WriteToUnix([]byte(addFileRequest), &net.UnixAddr{Name: path, Net: "unixgram"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(buffer[:n]) != addFileResponse {
+		t.Errorf("expected the file to be added, got %q", buffer[:n])
+	}
+	assertFileOwner(t, username)
+}
+
+// TestPrepareUnixSocketPath tests that only the socket files left at a path are removed
+func TestPrepareUnixSocketPath(t *testing.T) {
+	directory := t.TempDir()
+
+	// Check a missing path and a socket file
+	if err := PrepareUnixSocketPath(filepath.Join(directory, "missing.sock")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	socketPath := filepath.Join(directory, "server.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err = listener.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = PrepareUnixSocketPath(socketPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = os.Lstat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed, got %v", err)
+	}
+
+	// Check a regular file is kept
+	filePath := filepath.Join(directory, "file.txt")
+	if err = os.WriteFile(filePath, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = PrepareUnixSocketPath(filePath); err == nil {
+		t.Errorf("expected an error for a regular file")
+	}
+	if _, err = os.Stat(filePath); err != nil {
+		t.Errorf("expected the regular file to be kept, got %v", err)
+	}
+}
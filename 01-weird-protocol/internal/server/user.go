@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"path"
+)
+
+// ErrInvalidToken is the error for a request with a token of an unknown user
+var ErrInvalidToken = errors.New("invalid token")
+
+// GetUser gets the name of the user of a request from its token, the requests without a token field are made by the user authenticated by the transport, or are anonymous
+func GetUser(fields internalmessage.Fields, peer *Peer) (string, error) {
+	// Check if the request has a token field
+	if !fields.Has("token") {
+		if peer != nil {
+			return peer.User, nil
+		}
+		return "", nil
+	}
+
+	// Get the token and its user
+	token, err := fields.GetString("token")
+	if err != nil {
+		return "", err
+	}
+	user, ok := internalloader.Users[token]
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	return user, nil
+}
+
+// AnonymousActor is the actor of the events of the anonymous requests
+const AnonymousActor = "anonymous"
+
+// GetActor gets the actor of the events of a user
+func GetActor(user string) string {
+	if user == "" {
+		return AnonymousActor
+	}
+	return user
+}
+
+// GetHomeDirectory gets the directory of the file storage of a user, empty for the anonymous requests
+func GetHomeDirectory(user string) string {
+	if user == "" {
+		return ""
+	}
+	return path.Join(internalloader.UsersDirectory, user)
+}
+
+// GetUserStorage gets the storage of a user, the home directory of the users and the shared files for the anonymous requests, which can not access the home directories
+func GetUserStorage(user string) internalstorage.Storage {
+	if user == "" {
+		return internalloader.FileStorage
+	}
+	return internalloader.UserFileStorages[user]
+}
+
+// GetFileStorage gets the storage of a user, publishing its changes to the event bus
+func GetFileStorage(user string) internalstorage.Storage {
+	return internalstorage.NewNotifyingStorage(
+		GetUserStorage(user), func(change *internalstorage.Change) {
+			PublishFileChange(user, change)
+		},
+	)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"slices"
+)
+
+// UnsupportedVersionError is the error for a protocol version that is not supported
+func UnsupportedVersionError(version int64) error {
+	return fmt.Errorf(
+		"unsupported protocol version %d, supported versions: %v",
+		version,
+		internal.ProtocolVersions,
+	)
+}
+
+// GetProtocolVersion gets the protocol version of a request, the requests without a version field are from the legacy version
+func GetProtocolVersion(fields internalmessage.Fields) (int64, error) {
+	// Check if the request has a version field
+	if !fields.Has("version") {
+		return internal.LegacyProtocolVersion, nil
+	}
+
+	// Get the version and check if it is supported
+	version, err := fields.GetInt("version")
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(internal.ProtocolVersions, version) {
+		return 0, UnsupportedVersionError(version)
+	}
+	return version, nil
+}
+
+// ParseRequest parses a request with its encoding. The text requests without a version field are from the legacy version, so they are parsed with its grammar, where the scalars are untyped strings and the quoted strings have no escapes. The ones that are only valid in the current grammar are kept as they are
+func ParseRequest(
+	encoding internalmessage.Encoding,
+	data *string,
+) (internalmessage.Fields, error) {
+	// Parse the request with the current grammar
+	fields, err := internalmessage.Parse(encoding, data)
+	if encoding != internalmessage.TextEncoding || (err == nil && fields.Has("version")) {
+		return fields, err
+	}
+
+	// Parse the request with the legacy grammar
+	legacyFields, legacyErr := internalmessage.ParseLegacyText(data)
+	if legacyErr != nil || legacyFields.Has("version") {
+		return fields, err
+	}
+	return legacyFields, nil
+}
+
+// Capabilities returns the capabilities of the server
+func Capabilities() internalmessage.Fields {
+	// Get the supported versions
+	versions := make([]*internalmessage.Value, 0, len(internal.ProtocolVersions))
+	for _, version := range internal.ProtocolVersions {
+		versions = append(versions, internalmessage.NewInt(version))
+	}
+
+	// Get the supported encodings
+	encodings := make([]*internalmessage.Value, 0, len(internalmessage.Encodings))
+	for _, encoding := range internalmessage.Encodings {
+		encodings = append(encodings, internalmessage.NewString(string(encoding)))
+	}
+
+	// Get the supported headers
+	headers := make([]*internalmessage.Value, 0, len(internal.Headers))
+	for _, header := range internal.Headers {
+		headers = append(headers, internalmessage.NewString(header))
+	}
+
+	return internalmessage.Fields{
+		"versions":  internalmessage.NewList(versions...),
+		"encodings": internalmessage.NewList(encodings...),
+		"headers":   internalmessage.NewList(headers...),
+	}
+}
+
+// HandleHello handles the version negotiation, the server chooses the highest version supported by both sides
+func HandleHello(
+	respondFn func(message string),
+	respondValueFn func(value *internalmessage.Value),
+	body internalmessage.Fields,
+) {
+	// Get the versions supported by the client
+	versions, err := body.GetList("versions")
+	if err != nil {
+		respondFn(err.Error())
+		return
+	}
+
+	// Choose the highest common version
+	var chosenVersion int64
+	for _, versionValue := range versions {
+		version, err := versionValue.AsInt()
+		if err != nil {
+			respondFn(err.Error())
+			return
+		}
+		if version > chosenVersion && slices.Contains(
+			internal.ProtocolVersions,
+			version,
+		) {
+			chosenVersion = version
+		}
+	}
+	if chosenVersion == 0 {
+		respondFn(
+			fmt.Sprintf(
+				"no common protocol version, supported versions: %v",
+				internal.ProtocolVersions,
+			),
+		)
+		return
+	}
+
+	// Write the chosen version with the server capabilities
+	capabilities := Capabilities()
+	capabilities["version"] = internalmessage.NewInt(chosenVersion)
+	respondValueFn(internalmessage.NewObject(capabilities))
+}
+
+// HandleCapabilities handles the server capabilities
+func HandleCapabilities(
+	respondValueFn func(value *internalmessage.Value),
+) {
+	respondValueFn(internalmessage.NewObject(Capabilities()))
+}
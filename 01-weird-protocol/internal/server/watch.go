@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalevents "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/events"
+	internalloader "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/loader"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// FileEventsTopic is the topic of the event bus for the changes of the files
+const FileEventsTopic = "files"
+
+// PublishFileChange publishes a change of the file storage of a user to the event bus, with its path in the whole file storage
+func PublishFileChange(user string, change *internalstorage.Change) {
+	change = internalstorage.JoinChangeName(GetHomeDirectory(user), change)
+	internalloader.Events.Publish(
+		&internalevents.Event{
+			Topic: FileEventsTopic,
+			Type:  string(change.Type),
+			Actor: GetActor(user),
+			Data: map[string]any{
+				"filename":  change.Name,
+				"size":      change.Size,
+				"directory": change.IsDir,
+			},
+		},
+	)
+}
+
+// GetWatchedName gets the path of a file event inside the watched directory of the file storage of a user, it is false if the event is outside it. The anonymous requests watch the shared files, so the events of the home directories are hidden from them
+func GetWatchedName(user, directory, name string) (string, bool) {
+	// Get the path inside the home directory of the user, or check it is a shared file
+	if homeDirectory := GetHomeDirectory(user); homeDirectory != "" {
+		if !strings.HasPrefix(name, homeDirectory+"/") {
+			return "", false
+		}
+		name = strings.TrimPrefix(name, homeDirectory+"/")
+	} else if name == internalloader.UsersDirectory || strings.HasPrefix(name, internalloader.UsersDirectory+"/") {
+		return "", false
+	}
+
+	// Check if the path is inside the watched directory
+	if directory != "" && name != directory && !strings.HasPrefix(name, directory+"/") {
+		return "", false
+	}
+	return name, true
+}
+
+// NewFileEventFields creates the fields of a file event
+func NewFileEventFields(event *internalevents.Event, name string) internalmessage.Fields {
+	size, _ := event.Data["size"].(int64)
+	isDir, _ := event.Data["directory"].(bool)
+	return internalmessage.Fields{
+		"event":     internalmessage.NewString(event.Type),
+		"filename":  internalmessage.NewString(name),
+		"size":      internalmessage.NewInt(size),
+		"directory": internalmessage.NewBool(isDir),
+		"actor":     internalmessage.NewString(event.Actor),
+		"time":      internalmessage.NewString(event.Time.UTC().Format(time.RFC3339Nano)),
+	}
+}
+
+// ReadUntilClosed reads a persistent connection until the client closes it or sends the stop message, ignoring the idle timeouts, and closes the returned channel
+func ReadUntilClosed(readFn func() (string, error)) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			data, err := readFn()
+			if strings.Contains(data, internal.MorseStreamStop) {
+				return
+			}
+			var netErr net.Error
+			if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// HandleWatch handles the watch, pushing the changes of the files of the user to a persistent connection until the client closes it or sends the stop message. The optional directory limits the changes to the ones inside it. Every message after the request is a message of the connection, starting with the confirmation
+func HandleWatch(
+	logger *slog.Logger,
+	respondFn func(message string),
+	conn *PersistentConn,
+	encoding internalmessage.Encoding,
+	user string,
+	body internalmessage.Fields,
+) {
+	// Check if the connection is persistent
+	if conn == nil {
+		respondFn("watching requires a persistent connection")
+		return
+	}
+
+	// Get the message write functions
+	respondMessageFn := Respond(logger, encoding, conn.WriteMessage)
+	respondMessageValueFn := RespondValue(logger, encoding, conn.WriteMessage)
+
+	// Get the fields
+	var directory string
+	if body.Has("directory") {
+		var err error
+		if directory, err = body.GetString("directory"); err != nil {
+			respondMessageFn(err.Error())
+			return
+		}
+	}
+	if !internalstorage.IsRootPath(directory) {
+		var err error
+		if directory, err = internalstorage.CleanPath(directory); err != nil {
+			respondMessageFn(err.Error())
+			return
+		}
+	} else {
+		directory = ""
+	}
+
+	// Subscribe to the changes of the files
+	subscription := internalloader.Events.Subscribe(FileEventsTopic)
+	defer subscription.Cancel()
+	respondMessageValueFn(
+		internalmessage.NewObject(
+			internalmessage.Fields{
+				"watch":     internalmessage.NewString("started"),
+				"directory": internalmessage.NewString(directory),
+			},
+		),
+	)
+
+	// Push the changes until the connection is closed
+	done := ReadUntilClosed(conn.Read)
+	for {
+		select {
+		case <-done:
+			logger.Info("watch stopped")
+			return
+		case event := <-subscription.Events():
+			name, ok := GetWatchedName(user, directory, event.Data["filename"].(string))
+			if !ok {
+				continue
+			}
+			fields := NewFileEventFields(event, name)
+			if dropped := subscription.Dropped(); dropped > 0 {
+				fields["dropped"] = internalmessage.NewInt(dropped)
+			}
+			respondMessageValueFn(internalmessage.NewObject(fields))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+)
+
+// TestGetWatchedName tests the events received by the watchers of each user and directory
+func TestGetWatchedName(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		directory string
+		filename  string
+		expected  string
+		watched   bool
+	}{
+		{"anonymous shared file", "", "", "notes.txt", "notes.txt", true},
+		{"anonymous users directory", "", "", "users", "", false},
+		{"anonymous home file", "", "", "users/alice/secret.txt", "", false},
+		{"anonymous watched users directory", "", "users", "users/alice/secret.txt", "", false},
+		{"anonymous file starting like the users directory", "", "", "users.txt", "users.txt", true},
+		{"anonymous watched directory", "", "docs", "docs/a.txt", "docs/a.txt", true},
+		{"anonymous outside the watched directory", "", "docs", "other/a.txt", "", false},
+		{"user home file", "alice", "", "users/alice/secret.txt", "secret.txt", true},
+		{"user shared file", "alice", "", "notes.txt", "", false},
+		{"user other home file", "alice", "", "users/bob/secret.txt", "", false},
+		{"user watched directory", "alice", "docs", "users/alice/docs/a.txt", "docs/a.txt", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, watched := GetWatchedName(test.user, test.directory, test.filename)
+			if watched != test.watched || name != test.expected {
+				t.Errorf("expected %q, %v, got %q, %v", test.expected, test.watched, name, watched)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// WebSocketPingPeriod is the period of the pings sent to keep a WebSocket connection alive
+	WebSocketPingPeriod = 30 * time.Second
+
+	// WebSocketPongWait is the time to wait for the next pong or message of a WebSocket connection before closing it
+	WebSocketPongWait = 2 * WebSocketPingPeriod
+
+	// WebSocketWriteWait is the time to wait for a write to a WebSocket connection
+	WebSocketWriteWait = 10 * time.Second
+
+	// AnyWebSocketOrigin is the origin that allows any origin to open a WebSocket connection
+	AnyWebSocketOrigin = "*"
+)
+
+// NewWebSocketUpgrader creates the upgrader of the WebSocket connections, allowing the given origins. Only the same origin is allowed if there are none
+func NewWebSocketUpgrader(origins []string) *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{}
+	if len(origins) == 0 {
+		return upgrader
+	}
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		if slices.Contains(origins, AnyWebSocketOrigin) {
+			return true
+		}
+		return slices.Contains(origins, r.Header.Get("Origin"))
+	}
+	return upgrader
+}
+
+// NewWebSocketTransport creates the transport of the WebSocket connections of an address, the requests of the WebSocket path are upgraded if their origin is allowed
+func NewWebSocketTransport(address string, origins []string) *HTTPTransport {
+	upgrader := NewWebSocketUpgrader(origins)
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		internal.WebSocketPath, func(w http.ResponseWriter, r *http.Request) {
+			// Upgrade the request, the connection is closed after the client closes it
+			logger := NewLogger("websocket", GetConnNumber(r), &Peer{Address: r.RemoteAddr})
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				logger.Warn("error upgrading connection", ErrorLogKey, err)
+				return
+			}
+			defer func(conn *websocket.Conn) {
+				if err := conn.Close(); err != nil {
+					logger.Warn("error closing connection", ErrorLogKey, err)
+				}
+			}(conn)
+			HandleWebSocketConnection(conn, GetConnNumber(r))
+		},
+	)
+	return NewHTTPTransport("websocket", address, mux)
+}
+
+// HandleWebSocketConnection handles the requests of a WebSocket connection in order, until the client closes it. Each message is a request, and the messages of the persistent connection are WebSocket messages too, so they are not framed
+func HandleWebSocketConnection(conn *websocket.Conn, connNumber int) {
+	// Set the protocol
+	protocol := "websocket"
+
+	// Get the logger and the write function, the text messages are sent as text and the binary encodings as binary
+	peer := &Peer{Address: conn.RemoteAddr().String()}
+	logger := NewLogger(protocol, connNumber, peer)
+	var writeMutex sync.Mutex
+	writeFn := func(message string) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+
+		messageType := websocket.TextMessage
+		if !utf8.ValidString(message) {
+			messageType = websocket.BinaryMessage
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(WebSocketWriteWait)); err != nil {
+			logger.Warn("error writing", ErrorLogKey, err)
+			return
+		}
+		if err := conn.WriteMessage(messageType, []byte(message)); err != nil {
+			logger.Warn("error writing", ErrorLogKey, err)
+		}
+	}
+
+	// Get the read function, the errors of a WebSocket connection are permanent so they are not reported as timeouts
+	readFn := func() (string, error) {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return "", io.EOF
+			}
+			return "", errors.New(err.Error())
+		}
+		return string(data), nil
+	}
+	persistentConn := &PersistentConn{
+		Read:         readFn,
+		ReadMessage:  readFn,
+		WriteMessage: writeFn,
+	}
+
+	// Keep the connection alive, the client must answer the pings
+	conn.SetReadLimit(MaxRequestSize)
+	_ = conn.SetReadDeadline(time.Now().Add(WebSocketPongWait))
+	conn.SetPongHandler(
+		func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(WebSocketPongWait))
+		},
+	)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(WebSocketPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(
+					websocket.PingMessage,
+					nil,
+					time.Now().Add(WebSocketWriteWait),
+				); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Handle the requests until the connection is closed
+	for {
+		data, err := readFn()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warn("error reading", ErrorLogKey, err)
+			}
+			return
+		}
+		HandleIncomingData(logger, writeFn, persistentConn, peer, &data, nil)
+
+		// Wait again for the client, the handler may not have read its pongs
+		_ = conn.SetReadDeadline(time.Now().Add(WebSocketPongWait))
+	}
+}
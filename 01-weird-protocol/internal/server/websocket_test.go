@@ -0,0 +1,159 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal"
+	internalmessage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/message"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// dialWebSocket opens a WebSocket connection to a served transport, with the given origin if it is not empty
+func dialWebSocket(t *testing.T, address, origin string) (*websocket.Conn, error) {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+address+internal.WebSocketPath, header)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return conn, nil
+}
+
+// TestWebSocketOrigins tests that only the allowed origins can open a WebSocket connection
+func TestWebSocketOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		allowed bool
+	}{
+		{"no origin", nil, "", true},
+		{"same origin", nil, "same", true},
+		{"other origin", nil, "http://example.com", false},
+		{"allowed origin", []string{"http://example.com"}, "http://example.com", true},
+		{"not allowed origin", []string{"http://example.com"}, "http://attacker.com", false},
+		{"any origin", []string{AnyWebSocketOrigin}, "http://attacker.com", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address := serveTransport(
+				t, func(address string) Listener {
+					return NewWebSocketTransport(address, test.origins)
+				},
+			)
+			origin := test.origin
+			if origin == "same" {
+				origin = "http://" + address
+			}
+			_, err := dialWebSocket(t, address, origin)
+			if test.allowed && err != nil {
+				t.Errorf("expected the origin to be allowed, got %v", err)
+			}
+			if !test.allowed && err == nil {
+				t.Errorf("expected the origin to be rejected")
+			}
+		})
+	}
+}
+
+// TestWebSocketBinaryEncoding tests that the responses of the binary encodings are written as binary messages, and the text ones as text messages
+func TestWebSocketBinaryEncoding(t *testing.T) {
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewWebSocketTransport(address, nil)
+		},
+	)
+	conn, err := dialWebSocket(t, address, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request := internalmessage.NewObject(
+		internalmessage.Fields{
+			"header": internalmessage.NewString(internal.EchoHeader),
+			"body": internalmessage.NewObject(
+				internalmessage.Fields{"message": internalmessage.NewString("hello")},
+			),
+		},
+	)
+
+	for _, encoding := range []internalmessage.Encoding{internalmessage.CBOREncoding, internalmessage.TextEncoding} {
+		message, err := internalmessage.Encode(encoding, request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		messageType := websocket.TextMessage
+		if encoding.IsBinary() {
+			messageType = websocket.BinaryMessage
+		}
+		if err = conn.WriteMessage(messageType, []byte(message)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Check the type and the content of the response
+		responseType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if responseType != messageType {
+			t.Errorf("%s: expected the message type %d, got %d", encoding, messageType, responseType)
+		}
+		response := string(data)
+		fields, err := internalmessage.Parse(encoding, &response)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if message, err := fields.GetString("message"); err != nil || message != "hello" {
+			t.Errorf("%s: expected the echo, got %q", encoding, response)
+		}
+	}
+}
+
+// TestWebSocketMorseStream tests a streaming conversion on a WebSocket connection, whose messages are WebSocket messages instead of frames
+func TestWebSocketMorseStream(t *testing.T) {
+	setMorseConverters(t)
+	address := serveTransport(
+		t, func(address string) Listener {
+			return NewWebSocketTransport(address, nil)
+		},
+	)
+	conn, err := dialWebSocket(t, address, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Start the stream, send a chunk with the stop message and read the messages until the connection is closed
+	for _, message := range []string{
+		`{"header": "morse", "body": {"to": "morse", "stream": "start"}}`,
+		"sos" + internal.MorseStreamStop,
+	} {
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	expected := []string{`{"stream":"started"}`, `{"output":"... --- ..."}`}
+	for _, expectedMessage := range expected {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != expectedMessage {
+			t.Errorf("expected %s, got %s", expectedMessage, data)
+		}
+	}
+
+	// Check the connection keeps handling requests after the stream
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"header": "echo", "body": {"message": "after"}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != `{"message":"after"}` {
+		t.Errorf("expected the echo, got %s, %v", data, err)
+	}
+}
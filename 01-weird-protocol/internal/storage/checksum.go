@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch is the error for a content whose checksum is not the expected one
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+type (
+	// CorruptedFile is a file whose content does not match its stored checksum
+	CorruptedFile struct {
+		// Name is the path of the file
+		Name string
+
+		// Expected is the stored checksum
+		Expected string
+
+		// Actual is the checksum of the current content
+		Actual string
+	}
+
+	// Verification is the result of the verification of the files of a storage
+	Verification struct {
+		// Verified is the number of files whose content matches their stored checksum
+		Verified int64
+
+		// Corrupted are the files whose content does not match their stored checksum
+		Corrupted []CorruptedFile
+
+		// Unchecked are the files without a stored checksum
+		Unchecked []string
+	}
+)
+
+// Checksum returns the checksum of a content, the hexadecimal SHA-256 hash
+func Checksum(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// ReadChecksum returns the checksum of the content of a reader, reading it until its end
+func ReadChecksum(content io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyChecksum checks if the checksum of a content is the expected one, the comparison ignores the case
+func VerifyChecksum(content []byte, expected string) error {
+	if !strings.EqualFold(Checksum(content), expected) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// Verify reads all the files of a storage and compares their content with their stored checksum
+func Verify(storage Storage) (*Verification, error) {
+	verification := &Verification{}
+	err := Walk(
+		storage, func(file *FileInfo) error {
+			if file.Checksum == "" {
+				verification.Unchecked = append(verification.Unchecked, file.Name)
+				return nil
+			}
+
+			// Read the file and check its checksum
+			content, err := storage.ReadFile(file.Name)
+			if err != nil {
+				return err
+			}
+			if actual := Checksum(content); !strings.EqualFold(actual, file.Checksum) {
+				verification.Corrupted = append(
+					verification.Corrupted, CorruptedFile{
+						Name:     file.Name,
+						Expected: file.Checksum,
+						Actual:   actual,
+					},
+				)
+				return nil
+			}
+			verification.Verified++
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
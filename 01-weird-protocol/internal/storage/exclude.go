@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrReservedPath is the error for a path inside the excluded directory of a storage
+var ErrReservedPath = errors.New("reserved path")
+
+// ExcludeStorage is a storage without one of its directories, which is hidden from the listings and can not be read or written through it
+type ExcludeStorage struct {
+	storage   Storage
+	directory string
+}
+
+// NewExcludeStorage creates a new storage that hides a directory of the given storage
+func NewExcludeStorage(storage Storage, directory string) (*ExcludeStorage, error) {
+	cleanDirectory, err := CleanPath(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &ExcludeStorage{storage: storage, directory: cleanDirectory}, nil
+}
+
+// isExcluded checks if a path is the excluded directory or is inside it, the invalid paths are checked by the storage
+func (e *ExcludeStorage) isExcluded(name string) bool {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return false
+	}
+	return cleanName == e.directory || strings.HasPrefix(cleanName, e.directory+"/")
+}
+
+// checkRead checks if a path can be read, the excluded paths do not exist
+func (e *ExcludeStorage) checkRead(op, name string) error {
+	if e.isExcluded(name) {
+		cleanName, _ := CleanPath(name)
+		return NotFoundError(op, cleanName)
+	}
+	return nil
+}
+
+// checkWrite checks if a path can be written, the excluded paths are reserved
+func (e *ExcludeStorage) checkWrite(name string) error {
+	if e.isExcluded(name) {
+		return ErrReservedPath
+	}
+	return nil
+}
+
+// WriteFile writes the content of a file atomically, its directory must exist. Nil options overwrite the file
+func (e *ExcludeStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	if err := e.checkWrite(name); err != nil {
+		return err
+	}
+	return e.storage.WriteFile(name, content, options)
+}
+
+// WriteFileFrom writes the content of a reader like WriteFile, without holding it in memory
+func (e *ExcludeStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	if err := e.checkWrite(name); err != nil {
+		return err
+	}
+	return e.storage.WriteFileFrom(name, content, options)
+}
+
+// ReadFile reads the content of a file
+func (e *ExcludeStorage) ReadFile(name string) ([]byte, error) {
+	if err := e.checkRead("open", name); err != nil {
+		return nil, err
+	}
+	return e.storage.ReadFile(name)
+}
+
+// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it
+func (e *ExcludeStorage) ReadFileRange(name string, offset, length int64) ([]byte, error) {
+	if err := e.checkRead("open", name); err != nil {
+		return nil, err
+	}
+	return e.storage.ReadFileRange(name, offset, length)
+}
+
+// RemoveFile removes a file
+func (e *ExcludeStorage) RemoveFile(name string) error {
+	if err := e.checkRead("remove", name); err != nil {
+		return err
+	}
+	return e.storage.RemoveFile(name)
+}
+
+// Stat returns the information of a file or directory
+func (e *ExcludeStorage) Stat(name string) (*FileInfo, error) {
+	if err := e.checkRead("stat", name); err != nil {
+		return nil, err
+	}
+	return e.storage.Stat(name)
+}
+
+// List lists the files and directories of a directory, sorted by name. An empty directory name is the root, the excluded directory is not listed
+func (e *ExcludeStorage) List(directory string) ([]FileInfo, error) {
+	if !IsRootPath(directory) {
+		if err := e.checkRead("open", directory); err != nil {
+			return nil, err
+		}
+	}
+	files, err := e.storage.List(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the excluded directory
+	visibleFiles := files[:0]
+	for _, file := range files {
+		if file.Name != e.directory {
+			visibleFiles = append(visibleFiles, file)
+		}
+	}
+	return visibleFiles, nil
+}
+
+// Mkdir creates a directory, with its missing parents
+func (e *ExcludeStorage) Mkdir(name string) error {
+	if err := e.checkWrite(name); err != nil {
+		return err
+	}
+	return e.storage.Mkdir(name)
+}
+
+// Rmdir removes an empty directory
+func (e *ExcludeStorage) Rmdir(name string) error {
+	if err := e.checkRead("remove", name); err != nil {
+		return err
+	}
+	return e.storage.Rmdir(name)
+}
+
+// Rename renames a file or directory, the new path must not exist and its directory must exist
+func (e *ExcludeStorage) Rename(oldName, newName string) error {
+	if err := e.checkRead("stat", oldName); err != nil {
+		return err
+	}
+	if err := e.checkWrite(newName); err != nil {
+		return err
+	}
+	return e.storage.Rename(oldName, newName)
+}
@@ -0,0 +1,499 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// TempFileSuffix is the suffix of the pattern of the temporary files of the atomic writes
+	TempFileSuffix = ".tmp-*"
+
+	// ChecksumFileSuffix is the suffix of the hidden files that store the checksums of the files, next to them
+	ChecksumFileSuffix = ".sha256"
+)
+
+// IsInternalFileName checks if a file name is reserved for the temporary files or the checksum files
+func IsInternalFileName(name string) bool {
+	return strings.HasPrefix(name, ".") &&
+		(strings.HasSuffix(name, ChecksumFileSuffix) ||
+			strings.Contains(name, strings.TrimSuffix(TempFileSuffix, "*")))
+}
+
+// checksumPath returns the path of the checksum file of a resolved path
+func checksumPath(resolvedPath string) string {
+	return filepath.Join(
+		filepath.Dir(resolvedPath),
+		"."+filepath.Base(resolvedPath)+ChecksumFileSuffix,
+	)
+}
+
+// readChecksum reads the stored checksum of a resolved path, it is empty if it was not stored
+func readChecksum(resolvedPath string) string {
+	checksum, err := os.ReadFile(checksumPath(resolvedPath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(checksum))
+}
+
+// LocalStorage stores the files in a directory of the local disk, the paths are resolved inside it
+type LocalStorage struct {
+	root  string
+	mutex sync.Mutex
+}
+
+// NewLocalStorage creates a new local storage rooted at the given directory, which is created if it does not exist
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	// Create the root directory
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	// Get the canonical root, the resolved paths are compared with it
+	absoluteRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	canonicalRoot, err := filepath.EvalSymlinks(absoluteRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: canonicalRoot}, nil
+}
+
+// Root returns the canonical root directory
+func (l *LocalStorage) Root() string {
+	return l.root
+}
+
+// contains checks if the path is inside the root
+func (l *LocalStorage) contains(path string) bool {
+	return strings.HasPrefix(path, l.root+string(filepath.Separator))
+}
+
+// Resolve returns the canonical path of a file or directory inside the root. The symbolic links of the existing part of the path are followed, and the path is rejected if they lead outside the root or are dangling
+func (l *LocalStorage) Resolve(name string) (string, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	// Check if the path has a reserved name
+	for _, element := range strings.Split(cleanName, "/") {
+		if IsInternalFileName(element) {
+			return "", ErrInvalidPath
+		}
+	}
+
+	// Resolve the deepest existing part of the path
+	existingPath := filepath.Join(l.root, filepath.FromSlash(cleanName))
+	var missingElements []string
+	for {
+		resolvedPath, err := filepath.EvalSymlinks(existingPath)
+		if err == nil {
+			existingPath = resolvedPath
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", l.hideRoot(err)
+		}
+		missingElements = append(
+			[]string{filepath.Base(existingPath)},
+			missingElements...,
+		)
+		existingPath = filepath.Dir(existingPath)
+	}
+
+	// Check if the first missing element is a dangling symbolic link, it could be created outside the root
+	if len(missingElements) > 0 {
+		if _, err = os.Lstat(filepath.Join(existingPath, missingElements[0])); err == nil {
+			return "", ErrOutsideRoot
+		}
+	}
+
+	// Check if the resolved path is inside the root
+	resolvedPath := filepath.Join(append([]string{existingPath}, missingElements...)...)
+	if resolvedPath == l.root {
+		return "", ErrInvalidPath
+	}
+	if !l.contains(resolvedPath) {
+		return "", ErrOutsideRoot
+	}
+	return resolvedPath, nil
+}
+
+// hideRoot removes the root from the paths of the errors of the os package, so they are not revealed to the clients
+func (l *LocalStorage) hideRoot(err error) error {
+	trimRoot := func(path string) string {
+		if relativePath, err := filepath.Rel(l.root, path); err == nil {
+			return filepath.ToSlash(relativePath)
+		}
+		return path
+	}
+
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: trimRoot(pathErr.Path), Err: pathErr.Err}
+	}
+	if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: trimRoot(linkErr.Old), New: trimRoot(linkErr.New), Err: linkErr.Err}
+	}
+	return err
+}
+
+// checkParentDirectory checks if the parent directory of a resolved path exists
+func checkParentDirectory(resolvedPath string) error {
+	info, err := os.Stat(filepath.Dir(resolvedPath))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrParentNotExist
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("parent is not a directory")
+	}
+	return nil
+}
+
+// WriteFile writes the content of a file atomically, its directory must exist. The content is written to a temporary file in the same directory, which replaces the file once it is complete, so a failed write never leaves a partial file. Its checksum is stored in a hidden file next to it
+func (l *LocalStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	return l.WriteFileFrom(name, bytes.NewReader(content), options)
+}
+
+// WriteFileFrom writes the content of a reader atomically like WriteFile, copying it to the temporary file without holding it in memory
+func (l *LocalStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return err
+	}
+	if err = checkParentDirectory(resolvedPath); err != nil {
+		return err
+	}
+
+	// Lock the writes, so the checks of the options and the replacement of the file are not interleaved
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Check if the file exists
+	exists := false
+	info, err := os.Stat(resolvedPath)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", name)
+		}
+		exists = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return l.hideRoot(err)
+	}
+
+	// Check the options, the condition is checked against the current content of the file
+	cleanName, _ := CleanPath(name)
+	err = CheckWriteOptions(
+		cleanName, exists, func() (string, error) {
+			return readFileChecksum(resolvedPath)
+		}, options,
+	)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+
+	// Get the content to be written, after the current content of the file if it is appended
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := io.Reader(content)
+	if exists && IsAppend(options) {
+		current, err := os.Open(resolvedPath)
+		if err != nil {
+			return l.hideRoot(err)
+		}
+		defer current.Close()
+		reader = io.MultiReader(current, content)
+	}
+
+	// Write the content and its checksum to temporary files, they are removed if the write fails
+	tempPath, checksum, err := writeTempFile(resolvedPath, reader)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tempPath)
+		}
+	}()
+	tempChecksumPath, _, err := writeTempFile(
+		checksumPath(resolvedPath),
+		strings.NewReader(checksum),
+	)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+
+	// Replace the checksum and then the content, both are complete before any of them is replaced
+	if err = os.Rename(tempChecksumPath, checksumPath(resolvedPath)); err != nil {
+		_ = os.Remove(tempChecksumPath)
+		return l.hideRoot(err)
+	}
+	if err = os.Rename(tempPath, resolvedPath); err != nil {
+		return l.hideRoot(err)
+	}
+	return nil
+}
+
+// readFileChecksum returns the checksum of the content of a resolved path, reading it until its end
+func readFileChecksum(resolvedPath string) (string, error) {
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return ReadChecksum(file)
+}
+
+// writeTempFile writes the content to a hidden temporary file next to the path, flushed to the disk, and returns its path and the checksum of the written content. The temporary file is removed if the write fails
+func writeTempFile(resolvedPath string, content io.Reader) (tempPath, checksum string, err error) {
+	// Create the temporary file, it is hidden
+	file, err := os.CreateTemp(
+		filepath.Dir(resolvedPath),
+		"."+filepath.Base(resolvedPath)+TempFileSuffix,
+	)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	// Write the content while its checksum is computed, and flush it to the disk
+	if checksum, err = ReadChecksum(io.TeeReader(content, file)); err != nil {
+		return "", "", err
+	}
+	if err = file.Sync(); err != nil {
+		return "", "", err
+	}
+	if err = file.Chmod(0644); err != nil {
+		return "", "", err
+	}
+	if err = file.Close(); err != nil {
+		return "", "", err
+	}
+	return file.Name(), checksum, nil
+}
+
+// ReadFile reads the content of a file
+func (l *LocalStorage) ReadFile(name string) ([]byte, error) {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(resolvedPath)
+	return content, l.hideRoot(err)
+}
+
+// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it
+func (l *LocalStorage) ReadFileRange(name string, offset, length int64) ([]byte, error) {
+	if err := CheckRange(offset, length); err != nil {
+		return nil, err
+	}
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the file and get its size
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		return nil, l.hideRoot(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, l.hideRoot(err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	if offset >= info.Size() {
+		return []byte{}, nil
+	}
+
+	// Read the range
+	content := make([]byte, min(length, info.Size()-offset))
+	n, err := file.ReadAt(content, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, l.hideRoot(err)
+	}
+	return content[:n], nil
+}
+
+// RemoveFile removes a file and its checksum
+func (l *LocalStorage) RemoveFile(name string) error {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	// Lock the writes, so a concurrent write of the file does not leave its checksum behind
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Check if it is a directory
+	info, err := os.Stat(resolvedPath)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	if err = os.Remove(resolvedPath); err != nil {
+		return l.hideRoot(err)
+	}
+
+	// Remove the checksum file
+	if err = os.Remove(checksumPath(resolvedPath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return l.hideRoot(err)
+	}
+	return nil
+}
+
+// Mkdir creates a directory, with its missing parents
+func (l *LocalStorage) Mkdir(name string) error {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return err
+	}
+	return l.hideRoot(os.MkdirAll(resolvedPath, 0755))
+}
+
+// Rmdir removes an empty directory
+func (l *LocalStorage) Rmdir(name string) error {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	// Check if it is a directory
+	info, err := os.Stat(resolvedPath)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", name)
+	}
+	return l.hideRoot(os.Remove(resolvedPath))
+}
+
+// Rename renames a file or directory, the new path must not exist and its directory must exist
+func (l *LocalStorage) Rename(oldName, newName string) error {
+	oldPath, err := l.Resolve(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := l.Resolve(newName)
+	if err != nil {
+		return err
+	}
+
+	// Lock the writes, so the file and its checksum are moved together
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Check the paths
+	info, err := os.Stat(oldPath)
+	if err != nil {
+		return l.hideRoot(err)
+	}
+	if _, err = os.Lstat(newPath); err == nil {
+		return fmt.Errorf("%s already exists", newName)
+	}
+	if err = checkParentDirectory(newPath); err != nil {
+		return err
+	}
+
+	// Check if a directory would be moved inside itself
+	if l.contains(newPath) && strings.HasPrefix(newPath, oldPath+string(filepath.Separator)) {
+		return fmt.Errorf("cannot move %s inside itself", oldName)
+	}
+	if err = os.Rename(oldPath, newPath); err != nil || info.IsDir() {
+		return l.hideRoot(err)
+	}
+
+	// Move the checksum file of the file
+	err = os.Rename(checksumPath(oldPath), checksumPath(newPath))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return l.hideRoot(err)
+	}
+	return nil
+}
+
+// Stat returns the information of a file or directory
+func (l *LocalStorage) Stat(name string) (*FileInfo, error) {
+	resolvedPath, err := l.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolvedPath)
+	if err != nil {
+		return nil, l.hideRoot(err)
+	}
+	cleanName, _ := CleanPath(name)
+	fileInfo := NewFileInfo(cleanName, info)
+	if !info.IsDir() {
+		fileInfo.Checksum = readChecksum(resolvedPath)
+	}
+	return fileInfo, nil
+}
+
+// List lists the files and directories of a directory, sorted by name. An empty directory name is the root
+func (l *LocalStorage) List(directory string) ([]FileInfo, error) {
+	// Get the directory path
+	directoryPath := l.root
+	var cleanDirectory string
+	if !IsRootPath(directory) {
+		var err error
+		if cleanDirectory, err = CleanPath(directory); err != nil {
+			return nil, err
+		}
+		if directoryPath, err = l.Resolve(cleanDirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read the directory entries
+	entries, err := os.ReadDir(directoryPath)
+	if err != nil {
+		return nil, l.hideRoot(err)
+	}
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Skip the temporary files and the checksum files
+		if IsInternalFileName(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, l.hideRoot(err)
+		}
+		fileInfo := NewFileInfo(path.Join(cleanDirectory, entry.Name()), info)
+		if !info.IsDir() {
+			fileInfo.Checksum = readChecksum(filepath.Join(directoryPath, entry.Name()))
+		}
+		files = append(files, *fileInfo)
+	}
+	return files, nil
+}
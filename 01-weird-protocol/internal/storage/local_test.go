@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// assertChecksumFile checks that the checksum file of a file of a local storage matches its content, or that both are missing
+func assertChecksumFile(t *testing.T, storage *LocalStorage, name string) {
+	t.Helper()
+	resolvedPath := filepath.Join(storage.Root(), name)
+	content, err := os.ReadFile(resolvedPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, err = os.Stat(checksumPath(resolvedPath)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected no checksum file for the missing %s, got %v", name, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checksum, err := ReadChecksum(strings.NewReader(string(content)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored := readChecksum(resolvedPath); stored != checksum {
+		t.Errorf("expected the checksum %s of %s, got %q", checksum, name, stored)
+	}
+}
+
+// assertNoTempFiles checks that no temporary files are left in the root of a local storage
+func assertNoTempFiles(t *testing.T, storage *LocalStorage) {
+	t.Helper()
+	entries, err := os.ReadDir(storage.Root())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), strings.TrimSuffix(TempFileSuffix, "*")) {
+			t.Errorf("expected no temporary files, got %s", entry.Name())
+		}
+	}
+}
+
+// TestLocalStorageChecksumFile tests that the checksum file is replaced with the content of the file, and removed with it
+func TestLocalStorageChecksumFile(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, content := range []string{"first", "second"} {
+		if err = storage.WriteFile("notes.txt", []byte(content), nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertChecksumFile(t, storage, "notes.txt")
+	}
+	if err = storage.RemoveFile("notes.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertChecksumFile(t, storage, "notes.txt")
+	assertNoTempFiles(t, storage)
+}
+
+// TestLocalStorageChecksumFileFailedWrite tests that a write whose checksum file cannot be replaced keeps the previous content and checksum, and leaves no temporary files
+func TestLocalStorageChecksumFileFailedWrite(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = storage.WriteFile("notes.txt", []byte("first"), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Replace the checksum file with a directory, so it cannot be replaced
+	resolvedPath := filepath.Join(storage.Root(), "notes.txt")
+	if err = os.Remove(checksumPath(resolvedPath)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = os.Mkdir(checksumPath(resolvedPath), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(checksumPath(resolvedPath), "file"), nil, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = storage.WriteFile("notes.txt", []byte("second"), nil); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if content, err := os.ReadFile(resolvedPath); err != nil || string(content) != "first" {
+		t.Errorf("expected the previous content, got %q, %v", content, err)
+	}
+	assertNoTempFiles(t, storage)
+}
+
+// TestLocalStorageConcurrentRemove tests that the concurrent writes and removals of a file never leave a checksum file that does not match it
+func TestLocalStorageConcurrentRemove(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = storage.WriteFile("notes.txt", []byte(strings.Repeat("a", j)), nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = storage.RemoveFile("notes.txt")
+			}
+		}()
+	}
+	wg.Wait()
+	assertChecksumFile(t, storage, "notes.txt")
+
+	// Check the checksum file is removed with the file
+	_ = storage.RemoveFile("notes.txt")
+	assertChecksumFile(t, storage, "notes.txt")
+	assertNoTempFiles(t, storage)
+}
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// memoryEntry is a file or directory of the in-memory storage
+	memoryEntry struct {
+		isDir    bool
+		content  []byte
+		modTime  time.Time
+		checksum string
+	}
+
+	// MemoryStorage stores the files in memory, they are lost when the process ends. It is meant for tests
+	MemoryStorage struct {
+		mutex   sync.RWMutex
+		entries map[string]*memoryEntry
+	}
+)
+
+// NewMemoryStorage creates a new empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{entries: make(map[string]*memoryEntry)}
+}
+
+// checkParentDirectory checks if the parent directory of a clean path exists, the root always exists
+func (m *MemoryStorage) checkParentDirectory(cleanName string) error {
+	parent := path.Dir(cleanName)
+	if parent == "." {
+		return nil
+	}
+	entry, ok := m.entries[parent]
+	if !ok {
+		return ErrParentNotExist
+	}
+	if !entry.isDir {
+		return fmt.Errorf("parent is not a directory")
+	}
+	return nil
+}
+
+// WriteFile writes the content of a file atomically, its directory must exist. Nil options overwrite the file
+func (m *MemoryStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err = m.checkParentDirectory(cleanName); err != nil {
+		return err
+	}
+
+	// Get the current content of the file
+	var current []byte
+	entry, exists := m.entries[cleanName]
+	if exists {
+		if entry.isDir {
+			return fmt.Errorf("%s is a directory", name)
+		}
+		current = entry.content
+	}
+
+	// Get the content to be written
+	content, err = ApplyWriteOptions(cleanName, current, exists, content, options)
+	if err != nil {
+		return err
+	}
+	m.entries[cleanName] = &memoryEntry{
+		content:  append([]byte(nil), content...),
+		modTime:  time.Now(),
+		checksum: Checksum(content),
+	}
+	return nil
+}
+
+// WriteFileFrom writes the content of a reader, it is read to memory as the storage holds the contents in memory
+func (m *MemoryStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	return m.WriteFile(name, data, options)
+}
+
+// ReadFile reads the content of a file
+func (m *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	entry, ok := m.entries[cleanName]
+	if !ok {
+		return nil, NotFoundError("open", cleanName)
+	}
+	if entry.isDir {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	return append([]byte(nil), entry.content...), nil
+}
+
+// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it
+func (m *MemoryStorage) ReadFileRange(name string, offset, length int64) ([]byte, error) {
+	if err := CheckRange(offset, length); err != nil {
+		return nil, err
+	}
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	entry, ok := m.entries[cleanName]
+	if !ok {
+		return nil, NotFoundError("open", cleanName)
+	}
+	if entry.isDir {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	return GetRange(entry.content, offset, length), nil
+}
+
+// RemoveFile removes a file
+func (m *MemoryStorage) RemoveFile(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[cleanName]
+	if !ok {
+		return NotFoundError("remove", cleanName)
+	}
+	if entry.isDir {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	delete(m.entries, cleanName)
+	return nil
+}
+
+// Stat returns the information of a file or directory
+func (m *MemoryStorage) Stat(name string) (*FileInfo, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	entry, ok := m.entries[cleanName]
+	if !ok {
+		return nil, NotFoundError("stat", cleanName)
+	}
+	return &FileInfo{
+		Name:     cleanName,
+		Size:     int64(len(entry.content)),
+		IsDir:    entry.isDir,
+		ModTime:  entry.modTime,
+		Checksum: entry.checksum,
+	}, nil
+}
+
+// List lists the files and directories of a directory, sorted by name. An empty directory name is the root
+func (m *MemoryStorage) List(directory string) ([]FileInfo, error) {
+	// Get the directory
+	var cleanDirectory string
+	if !IsRootPath(directory) {
+		var err error
+		if cleanDirectory, err = CleanPath(directory); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if cleanDirectory != "" {
+		entry, ok := m.entries[cleanDirectory]
+		if !ok {
+			return nil, NotFoundError("open", cleanDirectory)
+		}
+		if !entry.isDir {
+			return nil, fmt.Errorf("%s is not a directory", directory)
+		}
+	}
+
+	// Get the direct children of the directory
+	var files []FileInfo
+	for name, entry := range m.entries {
+		parent := path.Dir(name)
+		if parent == "." {
+			parent = ""
+		}
+		if parent != cleanDirectory {
+			continue
+		}
+		files = append(
+			files, FileInfo{
+				Name:     name,
+				Size:     int64(len(entry.content)),
+				IsDir:    entry.isDir,
+				ModTime:  entry.modTime,
+				Checksum: entry.checksum,
+			},
+		)
+	}
+	sort.Slice(
+		files, func(i, j int) bool {
+			return files[i].Name < files[j].Name
+		},
+	)
+	return files, nil
+}
+
+// Mkdir creates a directory, with its missing parents
+func (m *MemoryStorage) Mkdir(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Check the directory and its parents
+	var missingDirectories []string
+	for directory := cleanName; directory != "."; directory = path.Dir(directory) {
+		entry, ok := m.entries[directory]
+		if !ok {
+			missingDirectories = append(missingDirectories, directory)
+			continue
+		}
+		if !entry.isDir {
+			return fmt.Errorf("%s is not a directory", directory)
+		}
+	}
+
+	// Create the missing directories
+	for _, directory := range missingDirectories {
+		m.entries[directory] = &memoryEntry{isDir: true, modTime: time.Now()}
+	}
+	return nil
+}
+
+// Rmdir removes an empty directory
+func (m *MemoryStorage) Rmdir(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[cleanName]
+	if !ok {
+		return NotFoundError("remove", cleanName)
+	}
+	if !entry.isDir {
+		return fmt.Errorf("%s is not a directory", name)
+	}
+
+	// Check if the directory is empty
+	for entryName := range m.entries {
+		if strings.HasPrefix(entryName, cleanName+"/") {
+			return fmt.Errorf("%s: directory not empty", cleanName)
+		}
+	}
+	delete(m.entries, cleanName)
+	return nil
+}
+
+// Rename renames a file or directory, the new path must not exist and its directory must exist
+func (m *MemoryStorage) Rename(oldName, newName string) error {
+	cleanOldName, err := CleanPath(oldName)
+	if err != nil {
+		return err
+	}
+	cleanNewName, err := CleanPath(newName)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Check the paths
+	entry, ok := m.entries[cleanOldName]
+	if !ok {
+		return NotFoundError("stat", cleanOldName)
+	}
+	if _, ok = m.entries[cleanNewName]; ok {
+		return fmt.Errorf("%s already exists", newName)
+	}
+	if err = m.checkParentDirectory(cleanNewName); err != nil {
+		return err
+	}
+	if strings.HasPrefix(cleanNewName, cleanOldName+"/") {
+		return fmt.Errorf("cannot move %s inside itself", oldName)
+	}
+
+	// Move the entry and, if it is a directory, its children
+	delete(m.entries, cleanOldName)
+	m.entries[cleanNewName] = entry
+	if entry.isDir {
+		for entryName, childEntry := range m.entries {
+			if strings.HasPrefix(entryName, cleanOldName+"/") {
+				delete(m.entries, entryName)
+				m.entries[cleanNewName+strings.TrimPrefix(entryName, cleanOldName)] = childEntry
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"io"
+	"path"
+)
+
+// ChangeType is the type of a change of a storage
+type ChangeType string
+
+const (
+	// ChangeAdded is the change of a new file or directory
+	ChangeAdded ChangeType = "added"
+
+	// ChangeRemoved is the change of a removed file or directory
+	ChangeRemoved ChangeType = "removed"
+
+	// ChangeModified is the change of an overwritten file
+	ChangeModified ChangeType = "modified"
+)
+
+type (
+	// Change is a change of a file or directory of a storage
+	Change struct {
+		// Type is the type of the change
+		Type ChangeType
+
+		// Name is the path of the file or directory
+		Name string
+
+		// Size is the size in bytes of the file after the change, zero if it was removed
+		Size int64
+
+		// IsDir is true if it is a directory
+		IsDir bool
+	}
+
+	// NotifyingStorage notifies the changes of a storage once they are done. A renamed file or directory is notified as removed from its old path and added to its new one
+	NotifyingStorage struct {
+		Storage
+		notifyFn func(change *Change)
+	}
+)
+
+// NewNotifyingStorage creates a new storage that notifies the changes of the given storage
+func NewNotifyingStorage(
+	storage Storage,
+	notifyFn func(change *Change),
+) *NotifyingStorage {
+	return &NotifyingStorage{Storage: storage, notifyFn: notifyFn}
+}
+
+// exists checks if a file or directory exists
+func (n *NotifyingStorage) exists(name string) bool {
+	_, err := n.Storage.Stat(name)
+	return err == nil
+}
+
+// notifyStat notifies a change with the current information of a file or directory
+func (n *NotifyingStorage) notifyStat(changeType ChangeType, name string) {
+	info, err := n.Storage.Stat(name)
+	if err != nil {
+		return
+	}
+	n.notifyFn(
+		&Change{
+			Type:  changeType,
+			Name:  info.Name,
+			Size:  info.Size,
+			IsDir: info.IsDir,
+		},
+	)
+}
+
+// WriteFile writes the content of a file and notifies it as added or modified
+func (n *NotifyingStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	return n.notifyWrite(
+		name, func() error {
+			return n.Storage.WriteFile(name, content, options)
+		},
+	)
+}
+
+// WriteFileFrom writes the content of a reader and notifies it as added or modified
+func (n *NotifyingStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	return n.notifyWrite(
+		name, func() error {
+			return n.Storage.WriteFileFrom(name, content, options)
+		},
+	)
+}
+
+// notifyWrite does a write of a file and notifies it as added or modified
+func (n *NotifyingStorage) notifyWrite(name string, writeFn func() error) error {
+	existed := n.exists(name)
+	if err := writeFn(); err != nil {
+		return err
+	}
+	if existed {
+		n.notifyStat(ChangeModified, name)
+	} else {
+		n.notifyStat(ChangeAdded, name)
+	}
+	return nil
+}
+
+// RemoveFile removes a file and notifies it as removed
+func (n *NotifyingStorage) RemoveFile(name string) error {
+	if err := n.Storage.RemoveFile(name); err != nil {
+		return err
+	}
+	cleanName, _ := CleanPath(name)
+	n.notifyFn(&Change{Type: ChangeRemoved, Name: cleanName})
+	return nil
+}
+
+// Mkdir creates a directory and notifies it as added if it did not exist
+func (n *NotifyingStorage) Mkdir(name string) error {
+	existed := n.exists(name)
+	if err := n.Storage.Mkdir(name); err != nil {
+		return err
+	}
+	if !existed {
+		n.notifyStat(ChangeAdded, name)
+	}
+	return nil
+}
+
+// Rmdir removes an empty directory and notifies it as removed
+func (n *NotifyingStorage) Rmdir(name string) error {
+	if err := n.Storage.Rmdir(name); err != nil {
+		return err
+	}
+	cleanName, _ := CleanPath(name)
+	n.notifyFn(&Change{Type: ChangeRemoved, Name: cleanName, IsDir: true})
+	return nil
+}
+
+// Rename renames a file or directory and notifies it as removed from its old path and added to its new one
+func (n *NotifyingStorage) Rename(oldName, newName string) error {
+	info, err := n.Storage.Stat(oldName)
+	if err != nil {
+		return err
+	}
+	if err = n.Storage.Rename(oldName, newName); err != nil {
+		return err
+	}
+	n.notifyFn(&Change{Type: ChangeRemoved, Name: info.Name, IsDir: info.IsDir})
+	n.notifyStat(ChangeAdded, newName)
+	return nil
+}
+
+// JoinChangeName returns a change with its name joined to a directory, for the changes of the storage of a directory
+func JoinChangeName(directory string, change *Change) *Change {
+	joinedChange := *change
+	joinedChange.Name = path.Join(directory, change.Name)
+	return &joinedChange
+}
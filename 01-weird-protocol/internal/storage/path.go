@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrInvalidPath is the error for a path that does not name a file or directory inside the root
+	ErrInvalidPath = errors.New("invalid path")
+
+	// ErrOutsideRoot is the error for a path that resolves outside the root, through a symbolic link
+	ErrOutsideRoot = errors.New("path resolves outside the root")
+)
+
+// CleanPath returns the canonical form of a path relative to the root, with slashes as separators. Backslashes are separators too, and the '..' elements cannot go above the root
+func CleanPath(name string) (string, error) {
+	// Check if the path contains null characters
+	if strings.ContainsRune(name, 0) {
+		return "", ErrInvalidPath
+	}
+
+	// Clean the path as if it was absolute, so it cannot go above the root
+	cleanName := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+	if cleanName == "" {
+		return "", ErrInvalidPath
+	}
+	return cleanName, nil
+}
+
+// IsRootPath checks if a path names the root
+func IsRootPath(name string) bool {
+	return path.Clean("/"+strings.ReplaceAll(name, "\\", "/")) == "/"
+}
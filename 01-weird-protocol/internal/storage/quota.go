@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// ErrQuotaExceeded is the error for a write that exceeds a quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type (
+	// Quota are the limits of a storage, a zero limit is unlimited
+	Quota struct {
+		// MaxFileSize is the maximum size in bytes of a file
+		MaxFileSize int64
+
+		// MaxBytes is the maximum size in bytes of all the files
+		MaxBytes int64
+
+		// MaxFiles is the maximum number of files
+		MaxFiles int64
+	}
+
+	// Usage is the usage of a storage
+	Usage struct {
+		// Bytes is the size in bytes of all the files
+		Bytes int64
+
+		// Files is the number of files
+		Files int64
+	}
+
+	// QuotaStorage enforces a quota on the writes of a storage, the other operations are done by the storage. Its usage is loaded once and then tracked by its writes, so the files of the storage must only be changed through it
+	QuotaStorage struct {
+		Storage
+		quota *Quota
+		usage *Usage
+		mutex sync.Mutex
+	}
+
+	// quotaWrite is a write checked against the quota, with the sizes of its file
+	quotaWrite struct {
+		currentSize int64
+		newSize     int64
+		exists      bool
+	}
+)
+
+// IsUnlimited checks if the quota has no limits
+func (q *Quota) IsUnlimited() bool {
+	return q.MaxFileSize == 0 && q.MaxBytes == 0 && q.MaxFiles == 0
+}
+
+// GetUsage returns the usage of a storage, walking all its directories. The usage of a quota storage is the one tracked by it
+func GetUsage(storage Storage) (*Usage, error) {
+	if quotaStorage, ok := storage.(*QuotaStorage); ok {
+		return quotaStorage.Usage()
+	}
+	usage := &Usage{}
+	err := Walk(
+		storage, func(file *FileInfo) error {
+			usage.Bytes += file.Size
+			usage.Files++
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// NewQuotaStorage creates a new storage that enforces a quota on the writes of the given storage
+func NewQuotaStorage(storage Storage, quota *Quota) *QuotaStorage {
+	return &QuotaStorage{Storage: storage, quota: quota}
+}
+
+// Quota returns the quota of the storage
+func (q *QuotaStorage) Quota() *Quota {
+	return q.quota
+}
+
+// loadUsage returns the tracked usage of the storage, walking it the first time. The mutex must be locked
+func (q *QuotaStorage) loadUsage() (*Usage, error) {
+	if q.usage == nil {
+		usage, err := GetUsage(q.Storage)
+		if err != nil {
+			return nil, err
+		}
+		q.usage = usage
+	}
+	return q.usage, nil
+}
+
+// Usage returns the usage of the storage
+func (q *QuotaStorage) Usage() (*Usage, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	usage, err := q.loadUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{Bytes: usage.Bytes, Files: usage.Files}, nil
+}
+
+// CheckQuota checks if a write of size bytes to a file is within the quota of a storage, the storages without a quota have no limits
+func CheckQuota(storage Storage, name string, size int64, options *WriteOptions) error {
+	quotaStorage, ok := storage.(*QuotaStorage)
+	if !ok {
+		return nil
+	}
+	quotaStorage.mutex.Lock()
+	defer quotaStorage.mutex.Unlock()
+
+	_, err := quotaStorage.check(name, size, options)
+	return err
+}
+
+// check checks if a write of size bytes to a file is within the quota. The mutex must be locked
+func (q *QuotaStorage) check(name string, size int64, options *WriteOptions) (*quotaWrite, error) {
+	// Get the current size of the file
+	write := &quotaWrite{newSize: size}
+	info, err := q.Storage.Stat(name)
+	if err == nil {
+		if !info.IsDir {
+			write.currentSize = info.Size
+			write.exists = true
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Get the size of the file after the write
+	if IsAppend(options) {
+		write.newSize += write.currentSize
+	}
+
+	// Check the limits
+	if q.quota.MaxFileSize > 0 && write.newSize > q.quota.MaxFileSize {
+		return nil, fmt.Errorf(
+			"%w: the file size %d exceeds the limit of %d bytes",
+			ErrQuotaExceeded,
+			write.newSize,
+			q.quota.MaxFileSize,
+		)
+	}
+	if q.quota.MaxBytes > 0 || q.quota.MaxFiles > 0 {
+		usage, err := q.loadUsage()
+		if err != nil {
+			return nil, err
+		}
+		if bytes := usage.Bytes - write.currentSize + write.newSize; q.quota.MaxBytes > 0 && bytes > q.quota.MaxBytes {
+			return nil, fmt.Errorf(
+				"%w: the total size %d exceeds the limit of %d bytes",
+				ErrQuotaExceeded,
+				bytes,
+				q.quota.MaxBytes,
+			)
+		}
+		if !write.exists && q.quota.MaxFiles > 0 && usage.Files+1 > q.quota.MaxFiles {
+			return nil, fmt.Errorf(
+				"%w: the limit of %d files was reached",
+				ErrQuotaExceeded,
+				q.quota.MaxFiles,
+			)
+		}
+	}
+	return write, nil
+}
+
+// write checks the quota of a write of size bytes to a file, does it and tracks the usage
+func (q *QuotaStorage) write(name string, size int64, options *WriteOptions, writeFn func() error) error {
+	// Lock the writes, so the usage does not change between the check and the write
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	write, err := q.check(name, size, options)
+	if err != nil {
+		return err
+	}
+	if err = writeFn(); err != nil {
+		return err
+	}
+
+	// Track the usage, if it was loaded
+	if q.usage != nil {
+		q.usage.Bytes += write.newSize - write.currentSize
+		if !write.exists {
+			q.usage.Files++
+		}
+	}
+	return nil
+}
+
+// WriteFile checks the quota and writes the content of a file
+func (q *QuotaStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	return q.write(
+		name, int64(len(content)), options, func() error {
+			return q.Storage.WriteFile(name, content, options)
+		},
+	)
+}
+
+// WriteFileFrom checks the quota and writes the content of a reader
+func (q *QuotaStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	size, err := GetReaderSize(content)
+	if err != nil {
+		return err
+	}
+	return q.write(
+		name, size, options, func() error {
+			return q.Storage.WriteFileFrom(name, content, options)
+		},
+	)
+}
+
+// RemoveFile removes a file and releases its usage
+func (q *QuotaStorage) RemoveFile(name string) error {
+	// Lock the writes, so the usage does not change between the stat and the removal
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// Get the size of the file
+	info, err := q.Storage.Stat(name)
+	if err != nil {
+		return err
+	}
+	if err = q.Storage.RemoveFile(name); err != nil {
+		return err
+	}
+
+	// Track the usage, if it was loaded
+	if q.usage != nil && !info.IsDir {
+		q.usage.Bytes -= info.Size
+		q.usage.Files--
+	}
+	return nil
+}
+
+// Rename renames a file or directory, both paths are inside the storage so the usage does not change. It is locked with the writes, so they do not check the size of a file that is being renamed
+func (q *QuotaStorage) Rename(oldName, newName string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.Storage.Rename(oldName, newName)
+}
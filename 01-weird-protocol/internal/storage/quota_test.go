@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// listCountingStorage is a storage that counts its listings
+type listCountingStorage struct {
+	Storage
+	lists int
+}
+
+// List counts the listing and lists the files and directories of a directory
+func (l *listCountingStorage) List(directory string) ([]FileInfo, error) {
+	l.lists++
+	return l.Storage.List(directory)
+}
+
+// TestQuotaStorage tests a storage with a quota against the storage contract
+func TestQuotaStorage(t *testing.T) {
+	testStorage(
+		t, func(t *testing.T) Storage {
+			return NewQuotaStorage(
+				NewMemoryStorage(),
+				&Quota{MaxFileSize: 1 << 20, MaxBytes: 1 << 20, MaxFiles: 100},
+			)
+		},
+	)
+}
+
+// TestQuotaStorageLimits tests the limits of a quota and the usage tracked by the storage
+func TestQuotaStorageLimits(t *testing.T) {
+	memoryStorage := &listCountingStorage{Storage: NewMemoryStorage()}
+	if err := memoryStorage.Mkdir("a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFile(t, memoryStorage, "a/existing.txt", "12")
+	storage := NewQuotaStorage(
+		memoryStorage,
+		&Quota{MaxFileSize: 6, MaxBytes: 10, MaxFiles: 3},
+	)
+
+	// assertUsage checks the tracked usage and the usage of the files
+	assertUsage := func(bytes, files int64) {
+		t.Helper()
+		usage, err := storage.Usage()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if usage.Bytes != bytes || usage.Files != files {
+			t.Errorf("expected %d bytes in %d files, got %d bytes in %d files", bytes, files, usage.Bytes, usage.Files)
+		}
+		walkedUsage, err := GetUsage(memoryStorage.Storage)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *walkedUsage != *usage {
+			t.Errorf("tracked usage %+v differs from the files %+v", *usage, *walkedUsage)
+		}
+	}
+
+	// Write files up to the limits
+	if err := storage.WriteFile("big.txt", []byte("1234567"), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error for the file size, got %v", err)
+	}
+	writeFile(t, storage, "first.txt", "12345")
+	writeFile(t, storage, "second.txt", "1")
+	assertUsage(8, 3)
+	if err := storage.WriteFile("third.txt", []byte("1"), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error for the number of files, got %v", err)
+	}
+	if err := storage.WriteFile("second.txt", []byte("1234"), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error for the total size, got %v", err)
+	}
+	if err := storage.WriteFile("second.txt", []byte("12"), &WriteOptions{Mode: WriteAppend}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	assertUsage(10, 3)
+
+	// Release the usage of the removed files
+	if err := storage.RemoveFile("first.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertUsage(5, 2)
+	if err := storage.RemoveFile("first.txt"); err == nil {
+		t.Errorf("expected an error removing a missing file")
+	}
+	assertUsage(5, 2)
+
+	// Rename files and directories without changing the usage
+	if err := storage.Rename("second.txt", "a/second.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.Rename("a", "b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertUsage(5, 2)
+	writeFile(t, storage, "b/third.txt", "12345")
+	assertUsage(10, 3)
+
+	// Check the storage was walked only once
+	lists := memoryStorage.lists
+	writeFile(t, storage, "b/third.txt", "1")
+	if _, err := storage.Usage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if memoryStorage.lists != lists {
+		t.Errorf("expected the tracked usage to be used, the storage was listed %d times", memoryStorage.lists-lists)
+	}
+}
+
+// TestCheckQuota tests the checks of the writes of the quota storages, and of the storages without a quota
+func TestCheckQuota(t *testing.T) {
+	storage := NewQuotaStorage(NewMemoryStorage(), &Quota{MaxBytes: 10})
+	writeFile(t, storage, "file.txt", "12345")
+
+	// Check the writes without doing them
+	if err := CheckQuota(storage, "other.txt", 5, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckQuota(storage, "other.txt", 6, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error, got %v", err)
+	}
+	if err := CheckQuota(storage, "file.txt", 10, nil); err != nil {
+		t.Errorf("unexpected error overwriting a file: %v", err)
+	}
+	if err := CheckQuota(storage, "file.txt", 6, &WriteOptions{Mode: WriteAppend}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error appending to a file, got %v", err)
+	}
+	if err := CheckQuota(NewMemoryStorage(), "file.txt", 1<<40, nil); err != nil {
+		t.Errorf("unexpected error for a storage without a quota: %v", err)
+	}
+
+	// Check the writes from a reader
+	if err := storage.WriteFileFrom("other.txt", strings.NewReader("123456"), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a quota exceeded error, got %v", err)
+	}
+	if err := storage.WriteFileFrom("other.txt", strings.NewReader("12345"), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if usage, err := storage.Usage(); err != nil || usage.Bytes != 10 || usage.Files != 2 {
+		t.Errorf("expected 10 bytes in 2 files, got %+v, %v", usage, err)
+	}
+}
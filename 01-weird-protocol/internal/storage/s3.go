@@ -0,0 +1,596 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// S3RequestTimeout is the timeout of each request to the object store
+	S3RequestTimeout = 30 * time.Second
+
+	// S3DirectoryContentType is the content type of the empty objects that mark the directories
+	S3DirectoryContentType = "application/x-directory"
+
+	// S3ChecksumMetadata is the user metadata of the objects with the checksum of their content
+	S3ChecksumMetadata = "Sha256"
+)
+
+type (
+	// S3Config is the configuration of an S3-compatible object store
+	S3Config struct {
+		// Endpoint is the host and port of the object store, e.g. localhost:9000 for a local MinIO
+		Endpoint string
+
+		// AccessKey is the access key of the object store
+		AccessKey string
+
+		// SecretKey is the secret key of the object store
+		SecretKey string
+
+		// Bucket is the bucket of the files, it is created if it does not exist
+		Bucket string
+
+		// Region is the region of the bucket, it can be empty
+		Region string
+
+		// UseSSL enables HTTPS
+		UseSSL bool
+	}
+
+	// S3Storage stores the files as the objects of a bucket of an S3-compatible object store. The directories are empty objects whose key ends with a slash
+	S3Storage struct {
+		client *minio.Client
+		bucket string
+	}
+)
+
+// NewS3Storage creates a new S3-compatible storage, creating its bucket if it does not exist
+func NewS3Storage(config *S3Config) (*S3Storage, error) {
+	// Create the client
+	client, err := minio.New(
+		config.Endpoint, &minio.Options{
+			Creds: credentials.NewStaticV4(
+				config.AccessKey,
+				config.SecretKey,
+				"",
+			),
+			Secure: config.UseSSL,
+			Region: config.Region,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the bucket
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		err = client.MakeBucket(
+			ctx,
+			config.Bucket,
+			minio.MakeBucketOptions{Region: config.Region},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &S3Storage{client: client, bucket: config.Bucket}, nil
+}
+
+// isNotFound checks if the error of the object store is for a missing object
+func isNotFound(err error) bool {
+	return minio.ToErrorResponse(err).StatusCode == 404
+}
+
+// getChecksum gets the checksum of the user metadata of an object, the listings of MinIO can keep the metadata prefix
+func getChecksum(userMetadata map[string]string) string {
+	if checksum, ok := userMetadata[S3ChecksumMetadata]; ok {
+		return checksum
+	}
+	return userMetadata["X-Amz-Meta-"+S3ChecksumMetadata]
+}
+
+// exists checks if an object exists
+func (s *S3Storage) exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// stat returns the information of a file or directory of a clean path
+func (s *S3Storage) stat(ctx context.Context, cleanName string) (*FileInfo, error) {
+	// Check if it is a file
+	info, err := s.client.StatObject(ctx, s.bucket, cleanName, minio.StatObjectOptions{})
+	if err == nil {
+		return &FileInfo{
+			Name:     cleanName,
+			Size:     info.Size,
+			ModTime:  info.LastModified,
+			Checksum: getChecksum(info.UserMetadata),
+		}, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	// Check if it is a directory
+	info, err = s.client.StatObject(ctx, s.bucket, cleanName+"/", minio.StatObjectOptions{})
+	if err == nil {
+		return &FileInfo{
+			Name:    cleanName,
+			IsDir:   true,
+			ModTime: info.LastModified,
+		}, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	return nil, NotFoundError("stat", cleanName)
+}
+
+// checkParentDirectory checks if the parent directory of a clean path exists, the root always exists
+func (s *S3Storage) checkParentDirectory(ctx context.Context, cleanName string) error {
+	parent := path.Dir(cleanName)
+	if parent == "." {
+		return nil
+	}
+	info, err := s.stat(ctx, parent)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrParentNotExist
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return fmt.Errorf("parent is not a directory")
+	}
+	return nil
+}
+
+// WriteFile writes the content of a file, its directory must exist. Nil options overwrite the file. The objects are replaced atomically by the object store, but the options are checked before the upload, so the conditional writes of different servers can be interleaved
+func (s *S3Storage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	return s.WriteFileFrom(name, bytes.NewReader(content), options)
+}
+
+// WriteFileFrom writes the content of a reader like WriteFile, streaming it to the object store. The content is read twice, as its checksum is stored in the metadata that is sent before it
+func (s *S3Storage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	if err = s.checkParentDirectory(ctx, cleanName); err != nil {
+		return err
+	}
+
+	// Check if it is a directory
+	isDir, err := s.exists(ctx, cleanName+"/")
+	if err != nil {
+		return err
+	}
+	if isDir {
+		return fmt.Errorf("%s is a directory", name)
+	}
+
+	// Get the size of the content
+	size, err := GetReaderSize(content)
+	if err != nil {
+		return err
+	}
+
+	// Check the options against the current object, only if the options need it. An appended object is pinned by its ETag, so it can not change between the reads
+	var current *minio.ObjectInfo
+	if options != nil && (options.Mode == WriteCreate || options.Mode == WriteAppend || options.IfMatch != "") {
+		info, err := s.client.StatObject(ctx, s.bucket, cleanName, minio.StatObjectOptions{})
+		exists := err == nil
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		err = CheckWriteOptions(
+			cleanName, exists, func() (string, error) {
+				return s.readObjectChecksum(ctx, cleanName)
+			}, options,
+		)
+		if err != nil {
+			return err
+		}
+		if exists && IsAppend(options) {
+			current = &info
+			size += info.Size
+		}
+	}
+
+	// Get the checksum of the content to be written
+	reader, closeFn, err := s.openWriteContent(ctx, cleanName, current, content)
+	if err != nil {
+		return err
+	}
+	checksum, err := ReadChecksum(reader)
+	closeFn()
+	if err != nil {
+		return err
+	}
+
+	// Upload the content
+	reader, closeFn, err = s.openWriteContent(ctx, cleanName, current, content)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	_, err = s.client.PutObject(
+		ctx,
+		s.bucket,
+		cleanName,
+		reader,
+		size,
+		minio.PutObjectOptions{
+			UserMetadata: map[string]string{S3ChecksumMetadata: checksum},
+		},
+	)
+	return err
+}
+
+// openWriteContent opens the content to be written from its start, after the content of the current object if it is appended. The returned function closes the current object
+func (s *S3Storage) openWriteContent(
+	ctx context.Context,
+	cleanName string,
+	current *minio.ObjectInfo,
+	content io.ReadSeeker,
+) (io.Reader, func(), error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	if current == nil {
+		return content, func() {}, nil
+	}
+
+	// Open the current object, the read fails if it was replaced
+	getOptions := minio.GetObjectOptions{}
+	if err := getOptions.SetMatchETag(current.ETag); err != nil {
+		return nil, nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, cleanName, getOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.MultiReader(object, content), func() {
+		_ = object.Close()
+	}, nil
+}
+
+// readObjectChecksum returns the checksum of the content of the object of a clean path, reading it until its end
+func (s *S3Storage) readObjectChecksum(ctx context.Context, cleanName string) (string, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, cleanName, minio.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+
+	checksum, err := ReadChecksum(object)
+	if isNotFound(err) {
+		return "", NotFoundError("open", cleanName)
+	}
+	return checksum, err
+}
+
+// readObject reads the content of the object of a clean path
+func (s *S3Storage) readObject(ctx context.Context, cleanName string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, cleanName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(object)
+	if isNotFound(err) {
+		return nil, NotFoundError("open", cleanName)
+	}
+	return content, err
+}
+
+// ReadFile reads the content of a file
+func (s *S3Storage) ReadFile(name string) ([]byte, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	return s.readObject(ctx, cleanName)
+}
+
+// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it. Only the range is downloaded from the object store
+func (s *S3Storage) ReadFileRange(name string, offset, length int64) ([]byte, error) {
+	if err := CheckRange(offset, length); err != nil {
+		return nil, err
+	}
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+
+	// Get the size of the file
+	info, err := s.stat(ctx, cleanName)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	if offset >= info.Size || length == 0 {
+		return []byte{}, nil
+	}
+
+	// Read the range, its end is inclusive
+	getOptions := minio.GetObjectOptions{}
+	if err = getOptions.SetRange(offset, min(offset+length, info.Size)-1); err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, cleanName, getOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(object)
+	if isNotFound(err) {
+		return nil, NotFoundError("open", cleanName)
+	}
+	return content, err
+}
+
+// RemoveFile removes a file
+func (s *S3Storage) RemoveFile(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	info, err := s.stat(ctx, cleanName)
+	if err != nil {
+		return err
+	}
+	if info.IsDir {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	return s.client.RemoveObject(ctx, s.bucket, cleanName, minio.RemoveObjectOptions{})
+}
+
+// Stat returns the information of a file or directory
+func (s *S3Storage) Stat(name string) (*FileInfo, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	return s.stat(ctx, cleanName)
+}
+
+// List lists the files and directories of a directory, sorted by name. An empty directory name is the root
+func (s *S3Storage) List(directory string) ([]FileInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+
+	// Get the prefix of the directory
+	var prefix string
+	if !IsRootPath(directory) {
+		cleanDirectory, err := CleanPath(directory)
+		if err != nil {
+			return nil, err
+		}
+		info, err := s.stat(ctx, cleanDirectory)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir {
+			return nil, fmt.Errorf("%s is not a directory", directory)
+		}
+		prefix = cleanDirectory + "/"
+	}
+
+	// List the objects and the common prefixes, which are the directories
+	var files []FileInfo
+	for object := range s.client.ListObjects(
+		ctx,
+		s.bucket,
+		minio.ListObjectsOptions{Prefix: prefix, WithMetadata: true},
+	) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if object.Key == prefix {
+			continue
+		}
+		if strings.HasSuffix(object.Key, "/") {
+			files = append(
+				files, FileInfo{
+					Name:  strings.TrimSuffix(object.Key, "/"),
+					IsDir: true,
+				},
+			)
+			continue
+		}
+		files = append(
+			files, FileInfo{
+				Name:     object.Key,
+				Size:     object.Size,
+				ModTime:  object.LastModified,
+				Checksum: getChecksum(object.UserMetadata),
+			},
+		)
+	}
+	sort.Slice(
+		files, func(i, j int) bool {
+			return files[i].Name < files[j].Name
+		},
+	)
+	return files, nil
+}
+
+// Mkdir creates a directory, with its missing parents
+func (s *S3Storage) Mkdir(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+
+	// Create the directory and its parents, from the root
+	elements := strings.Split(cleanName, "/")
+	for i := range elements {
+		directory := strings.Join(elements[:i+1], "/")
+		isFile, err := s.exists(ctx, directory)
+		if err != nil {
+			return err
+		}
+		if isFile {
+			return fmt.Errorf("%s is not a directory", directory)
+		}
+		_, err = s.client.PutObject(
+			ctx,
+			s.bucket,
+			directory+"/",
+			bytes.NewReader(nil),
+			0,
+			minio.PutObjectOptions{ContentType: S3DirectoryContentType},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rmdir removes an empty directory
+func (s *S3Storage) Rmdir(name string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+	info, err := s.stat(ctx, cleanName)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return fmt.Errorf("%s is not a directory", name)
+	}
+
+	// Check if the directory is empty
+	for object := range s.client.ListObjects(
+		ctx,
+		s.bucket,
+		minio.ListObjectsOptions{Prefix: cleanName + "/", MaxKeys: 2},
+	) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if object.Key != cleanName+"/" {
+			return fmt.Errorf("%s: directory not empty", cleanName)
+		}
+	}
+	return s.client.RemoveObject(ctx, s.bucket, cleanName+"/", minio.RemoveObjectOptions{})
+}
+
+// moveObject copies an object to a new key and removes the old one
+func (s *S3Storage) moveObject(ctx context.Context, oldKey, newKey string) error {
+	_, err := s.client.CopyObject(
+		ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: newKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: oldKey},
+	)
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, oldKey, minio.RemoveObjectOptions{})
+}
+
+// Rename renames a file or directory, the new path must not exist and its directory must exist. The objects of a directory are moved one by one, so it is not atomic
+func (s *S3Storage) Rename(oldName, newName string) error {
+	cleanOldName, err := CleanPath(oldName)
+	if err != nil {
+		return err
+	}
+	cleanNewName, err := CleanPath(newName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3RequestTimeout)
+	defer cancel()
+
+	// Check the paths
+	info, err := s.stat(ctx, cleanOldName)
+	if err != nil {
+		return err
+	}
+	if _, err = s.stat(ctx, cleanNewName); err == nil {
+		return fmt.Errorf("%s already exists", newName)
+	}
+	if err = s.checkParentDirectory(ctx, cleanNewName); err != nil {
+		return err
+	}
+	if strings.HasPrefix(cleanNewName, cleanOldName+"/") {
+		return fmt.Errorf("cannot move %s inside itself", oldName)
+	}
+
+	// Move the file
+	if !info.IsDir {
+		return s.moveObject(ctx, cleanOldName, cleanNewName)
+	}
+
+	// Move the objects of the directory, including its marker
+	var keys []string
+	for object := range s.client.ListObjects(
+		ctx,
+		s.bucket,
+		minio.ListObjectsOptions{Prefix: cleanOldName + "/", Recursive: true},
+	) {
+		if object.Err != nil {
+			return object.Err
+		}
+		keys = append(keys, object.Key)
+	}
+	for _, key := range keys {
+		newKey := cleanNewName + strings.TrimPrefix(key, cleanOldName)
+		if err = s.moveObject(ctx, key, newKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
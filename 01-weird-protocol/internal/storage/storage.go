@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+const (
+	// LocalBackend is the name of the local disk backend
+	LocalBackend = "local"
+
+	// MemoryBackend is the name of the in-memory backend
+	MemoryBackend = "memory"
+
+	// S3Backend is the name of the S3-compatible object store backend
+	S3Backend = "s3"
+)
+
+// Backends are the names of the storage backends
+var Backends = []string{LocalBackend, MemoryBackend, S3Backend}
+
+var (
+	// ErrParentNotExist is the error for a write or a rename whose parent directory does not exist
+	ErrParentNotExist = errors.New("parent directory does not exist")
+
+	// ErrInvalidRange is the error for a ranged read with a negative offset or length
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+type (
+	// FileInfo is the information of a file or directory of a storage
+	FileInfo struct {
+		// Name is the path of the file or directory, relative to the root
+		Name string
+
+		// Size is the size in bytes of the file
+		Size int64
+
+		// IsDir is true if it is a directory
+		IsDir bool
+
+		// ModTime is the last modification time
+		ModTime time.Time
+
+		// Checksum is the checksum of the content of the file stored when it was written, it is empty if it is unknown
+		Checksum string
+	}
+
+	// Storage stores the files and directories of the file commands. The names are paths relative to its root, with slashes or backslashes as separators
+	Storage interface {
+		// WriteFile writes the content of a file atomically, its directory must exist. Nil options overwrite the file
+		WriteFile(name string, content []byte, options *WriteOptions) error
+
+		// WriteFileFrom writes the content of a reader like WriteFile, without holding it in memory. The content is read from its start, and it can be read more than once
+		WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error
+
+		// ReadFile reads the content of a file
+		ReadFile(name string) ([]byte, error)
+
+		// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it
+		ReadFileRange(name string, offset, length int64) ([]byte, error)
+
+		// RemoveFile removes a file
+		RemoveFile(name string) error
+
+		// Stat returns the information of a file or directory
+		Stat(name string) (*FileInfo, error)
+
+		// List lists the files and directories of a directory, sorted by name. An empty directory name is the root
+		List(directory string) ([]FileInfo, error)
+
+		// Mkdir creates a directory, with its missing parents
+		Mkdir(name string) error
+
+		// Rmdir removes an empty directory
+		Rmdir(name string) error
+
+		// Rename renames a file or directory, the new path must not exist and its directory must exist
+		Rename(oldName, newName string) error
+	}
+)
+
+// NewFileInfo creates the information of a file or directory from the information of the fs package
+func NewFileInfo(name string, info fs.FileInfo) *FileInfo {
+	fileInfo := &FileInfo{
+		Name:    name,
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}
+	if !info.IsDir() {
+		fileInfo.Size = info.Size()
+	}
+	return fileInfo
+}
+
+// NotFoundError is the error for a file or directory that does not exist, it wraps fs.ErrNotExist
+func NotFoundError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// CheckRange checks the offset and the length of a ranged read
+func CheckRange(offset, length int64) error {
+	if offset < 0 || length < 0 {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// GetRange returns the bytes of a ranged read of a content, fewer at its end and none after it
+func GetRange(content []byte, offset, length int64) []byte {
+	size := int64(len(content))
+	if offset >= size {
+		return []byte{}
+	}
+	return append([]byte(nil), content[offset:min(offset+length, size)]...)
+}
+
+// GetReaderSize returns the size in bytes of the content of a reader, leaving it at its start
+func GetReaderSize(content io.ReadSeeker) (int64, error) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Move moves a file or directory into a directory of the storage, keeping its name. The directory can be the root
+func Move(storage Storage, name, directory string) error {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+
+	// Check if the destination is the root
+	if IsRootPath(directory) {
+		return storage.Rename(cleanName, path.Base(cleanName))
+	}
+
+	// Check if the destination is a directory
+	cleanDirectory, err := CleanPath(directory)
+	if err != nil {
+		return err
+	}
+	info, err := storage.Stat(cleanDirectory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return fmt.Errorf("%s is not a directory", directory)
+	}
+	return storage.Rename(cleanName, path.Join(cleanDirectory, path.Base(cleanName)))
+}
+
+// Walk calls the function for each file of a storage, walking all its directories
+func Walk(storage Storage, fn func(file *FileInfo) error) error {
+	directories := []string{""}
+	for len(directories) > 0 {
+		// Get the next directory
+		directory := directories[len(directories)-1]
+		directories = directories[:len(directories)-1]
+
+		// List its files and directories
+		files, err := storage.List(directory)
+		if err != nil {
+			return err
+		}
+		for i := range files {
+			if files[i].IsDir {
+				directories = append(directories, files[i].Name)
+				continue
+			}
+			if err = fn(&files[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,587 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	// EnvS3TestEndpoint is the key for the endpoint of the MinIO server of the S3 tests in the environment variables, they are skipped if it is empty
+	EnvS3TestEndpoint = "S3_TEST_ENDPOINT"
+
+	// EnvS3TestAccessKey is the key for the access key of the MinIO server of the S3 tests in the environment variables
+	EnvS3TestAccessKey = "S3_TEST_ACCESS_KEY"
+
+	// EnvS3TestSecretKey is the key for the secret key of the MinIO server of the S3 tests in the environment variables
+	EnvS3TestSecretKey = "S3_TEST_SECRET_KEY"
+
+	// S3TestDefaultCredential is the access and secret key of a default MinIO server
+	S3TestDefaultCredential = "minioadmin"
+)
+
+// TestMemoryStorage tests the in-memory backend against the storage contract
+func TestMemoryStorage(t *testing.T) {
+	testStorage(
+		t, func(t *testing.T) Storage {
+			return NewMemoryStorage()
+		},
+	)
+}
+
+// TestLocalStorage tests the local disk backend against the storage contract
+func TestLocalStorage(t *testing.T) {
+	testStorage(
+		t, func(t *testing.T) Storage {
+			storage, err := NewLocalStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return storage
+		},
+	)
+}
+
+// TestSubStorage tests a directory of a storage used as a storage against the storage contract
+func TestSubStorage(t *testing.T) {
+	testStorage(
+		t, func(t *testing.T) Storage {
+			storage, err := NewSubStorage(NewMemoryStorage(), "users/alice")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return storage
+		},
+	)
+}
+
+// TestExcludeStorage tests a storage with a hidden directory against the storage contract
+func TestExcludeStorage(t *testing.T) {
+	testStorage(
+		t, func(t *testing.T) Storage {
+			storage, err := NewExcludeStorage(NewMemoryStorage(), "users")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return storage
+		},
+	)
+}
+
+// TestExcludeStorageHidden tests that the hidden directory can not be accessed through the storage
+func TestExcludeStorageHidden(t *testing.T) {
+	// Create a file inside and outside the hidden directory
+	memoryStorage := NewMemoryStorage()
+	if err := memoryStorage.Mkdir("users/alice"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFile(t, memoryStorage, "users/alice/secret.txt", "secret")
+	writeFile(t, memoryStorage, "shared.txt", "shared")
+	storage, err := NewExcludeStorage(memoryStorage, "users")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Check the hidden paths do not exist
+	assertList(t, storage, "", "shared.txt")
+	for _, name := range []string{"users", "users/alice", "./users/alice/../alice/secret.txt", "/users"} {
+		if _, err = storage.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat %q: expected a not exist error, got %v", name, err)
+		}
+		if _, err = storage.List(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("List %q: expected a not exist error, got %v", name, err)
+		}
+	}
+	if _, err = storage.ReadFile("users/alice/secret.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile: expected a not exist error, got %v", err)
+	}
+	if err = storage.RemoveFile("users/alice/secret.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RemoveFile: expected a not exist error, got %v", err)
+	}
+	if err = storage.Rmdir("users"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rmdir: expected a not exist error, got %v", err)
+	}
+	if err = storage.Rename("users/alice", "alice"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename from the hidden directory: expected a not exist error, got %v", err)
+	}
+
+	// Check the hidden paths can not be written
+	if err = storage.WriteFile("users/alice/secret.txt", []byte("stolen"), nil); !errors.Is(err, ErrReservedPath) {
+		t.Errorf("WriteFile: expected a reserved path error, got %v", err)
+	}
+	if err = storage.Mkdir("users/bob"); !errors.Is(err, ErrReservedPath) {
+		t.Errorf("Mkdir: expected a reserved path error, got %v", err)
+	}
+	if err = storage.Rename("shared.txt", "users/alice/shared.txt"); !errors.Is(err, ErrReservedPath) {
+		t.Errorf("Rename to the hidden directory: expected a reserved path error, got %v", err)
+	}
+
+	// Check the hidden files were not changed
+	if content := readFile(t, memoryStorage, "users/alice/secret.txt"); content != "secret" {
+		t.Errorf("expected secret, got %q", content)
+	}
+	assertList(t, memoryStorage, "users/alice", "users/alice/secret.txt")
+
+	// Check the names that only start like the hidden directory are not hidden
+	writeFile(t, storage, "users.txt", "not hidden")
+	assertList(t, storage, "", "shared.txt", "users.txt")
+}
+
+// TestS3Storage tests the S3-compatible backend against the storage contract, on a new bucket of a MinIO server for each test
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv(EnvS3TestEndpoint)
+	if endpoint == "" {
+		t.Skipf("%s is not set", EnvS3TestEndpoint)
+	}
+	testStorage(
+		t, func(t *testing.T) Storage {
+			// Get the credentials, the ones of a default MinIO server if they are not set
+			accessKey := os.Getenv(EnvS3TestAccessKey)
+			if accessKey == "" {
+				accessKey = S3TestDefaultCredential
+			}
+			secretKey := os.Getenv(EnvS3TestSecretKey)
+			if secretKey == "" {
+				secretKey = S3TestDefaultCredential
+			}
+
+			// Create the storage on a new bucket
+			suffix := make([]byte, 8)
+			if _, err := rand.Read(suffix); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			storage, err := NewS3Storage(
+				&S3Config{
+					Endpoint:  endpoint,
+					AccessKey: accessKey,
+					SecretKey: secretKey,
+					Bucket:    "weird-protocol-test-" + hex.EncodeToString(suffix),
+				},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Remove the bucket and its objects at the end of the test
+			t.Cleanup(
+				func() {
+					ctx := context.Background()
+					for object := range storage.client.ListObjects(
+						ctx,
+						storage.bucket,
+						minio.ListObjectsOptions{Recursive: true},
+					) {
+						if object.Err == nil {
+							_ = storage.client.RemoveObject(ctx, storage.bucket, object.Key, minio.RemoveObjectOptions{})
+						}
+					}
+					_ = storage.client.RemoveBucket(ctx, storage.bucket)
+				},
+			)
+			return storage
+		},
+	)
+}
+
+// testStorage tests a backend against the contract of the Storage interface, each subtest gets a new empty storage
+func testStorage(t *testing.T, newStorage func(t *testing.T) Storage) {
+	t.Run(
+		"write and read", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello")
+			if content := readFile(t, storage, "file.txt"); content != "hello" {
+				t.Errorf("expected hello, got %q", content)
+			}
+
+			// Check the information of the file
+			info, err := storage.Stat("file.txt")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Name != "file.txt" || info.IsDir || info.Size != 5 {
+				t.Errorf("unexpected information: %+v", info)
+			}
+			if info.Checksum != Checksum([]byte("hello")) {
+				t.Errorf("expected the checksum of the content, got %q", info.Checksum)
+			}
+
+			// Check the information of the listed file
+			files, err := storage.List("")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(files) != 1 || files[0].Name != "file.txt" || files[0].Size != 5 {
+				t.Fatalf("unexpected listing: %+v", files)
+			}
+			if files[0].Checksum != Checksum([]byte("hello")) {
+				t.Errorf("expected the listed checksum of the content, got %q", files[0].Checksum)
+			}
+
+			// Overwrite the file
+			writeFile(t, storage, "file.txt", "bye")
+			if content := readFile(t, storage, "file.txt"); content != "bye" {
+				t.Errorf("expected bye, got %q", content)
+			}
+		},
+	)
+
+	t.Run(
+		"write options", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello")
+
+			// Check the create-only writes
+			err := storage.WriteFile("file.txt", []byte("other"), &WriteOptions{Mode: WriteCreate})
+			if !errors.Is(err, ErrFileExists) {
+				t.Errorf("expected ErrFileExists, got %v", err)
+			}
+			if err = storage.WriteFile("new.txt", []byte("new"), &WriteOptions{Mode: WriteCreate}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			// Check the appends
+			if err = storage.WriteFile("file.txt", []byte(" world"), &WriteOptions{Mode: WriteAppend}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content := readFile(t, storage, "file.txt"); content != "hello world" {
+				t.Errorf("expected hello world, got %q", content)
+			}
+
+			// Check the conditional writes
+			err = storage.WriteFile("file.txt", []byte("x"), &WriteOptions{IfMatch: Checksum([]byte("hello"))})
+			if !errors.Is(err, ErrPreconditionFailed) {
+				t.Errorf("expected ErrPreconditionFailed, got %v", err)
+			}
+			err = storage.WriteFile("file.txt", []byte("x"), &WriteOptions{IfMatch: Checksum([]byte("hello world"))})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			err = storage.WriteFile("missing.txt", []byte("x"), &WriteOptions{IfMatch: Checksum([]byte("x"))})
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected fs.ErrNotExist, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"missing files", func(t *testing.T) {
+			storage := newStorage(t)
+			if _, err := storage.ReadFile("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("ReadFile: expected fs.ErrNotExist, got %v", err)
+			}
+			if _, err := storage.Stat("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Stat: expected fs.ErrNotExist, got %v", err)
+			}
+			if err := storage.RemoveFile("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("RemoveFile: expected fs.ErrNotExist, got %v", err)
+			}
+			if _, err := storage.List("missing"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("List: expected fs.ErrNotExist, got %v", err)
+			}
+			if err := storage.WriteFile("missing/file.txt", []byte("x"), nil); !errors.Is(err, ErrParentNotExist) {
+				t.Errorf("WriteFile: expected ErrParentNotExist, got %v", err)
+			}
+			if _, err := storage.ReadFileRange("missing.txt", 0, 1); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("ReadFileRange: expected fs.ErrNotExist, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"write from a reader", func(t *testing.T) {
+			storage := newStorage(t)
+
+			// Write a file from a reader that is not at its start
+			content := strings.NewReader("hello")
+			if _, err := content.Seek(2, io.SeekStart); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := storage.WriteFileFrom("file.txt", content, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content := readFile(t, storage, "file.txt"); content != "hello" {
+				t.Errorf("expected hello, got %q", content)
+			}
+
+			// Check the options
+			err := storage.WriteFileFrom("file.txt", strings.NewReader("x"), &WriteOptions{Mode: WriteCreate})
+			if !errors.Is(err, ErrFileExists) {
+				t.Errorf("expected ErrFileExists, got %v", err)
+			}
+			err = storage.WriteFileFrom("file.txt", strings.NewReader("x"), &WriteOptions{IfMatch: Checksum([]byte("bye"))})
+			if !errors.Is(err, ErrPreconditionFailed) {
+				t.Errorf("expected ErrPreconditionFailed, got %v", err)
+			}
+			err = storage.WriteFileFrom(
+				"file.txt",
+				strings.NewReader(" world"),
+				&WriteOptions{Mode: WriteAppend, IfMatch: Checksum([]byte("hello"))},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content := readFile(t, storage, "file.txt"); content != "hello world" {
+				t.Errorf("expected hello world, got %q", content)
+			}
+
+			// Check the stored checksum is the one of the whole content
+			info, err := storage.Stat("file.txt")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Size != 11 || info.Checksum != Checksum([]byte("hello world")) {
+				t.Errorf("unexpected information: %+v", info)
+			}
+		},
+	)
+
+	t.Run(
+		"read ranges", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello world")
+
+			tests := []struct {
+				offset   int64
+				length   int64
+				expected string
+			}{
+				{0, 5, "hello"},
+				{6, 5, "world"},
+				{6, 100, "world"},
+				{0, 0, ""},
+				{10, 1, "d"},
+				{11, 1, ""},
+				{20, 1, ""},
+			}
+			for _, test := range tests {
+				content, err := storage.ReadFileRange("file.txt", test.offset, test.length)
+				if err != nil {
+					t.Errorf("range %d+%d: unexpected error: %v", test.offset, test.length, err)
+					continue
+				}
+				if string(content) != test.expected {
+					t.Errorf("range %d+%d: expected %q, got %q", test.offset, test.length, test.expected, content)
+				}
+			}
+			if _, err := storage.ReadFileRange("file.txt", -1, 1); !errors.Is(err, ErrInvalidRange) {
+				t.Errorf("expected ErrInvalidRange, got %v", err)
+			}
+			if err := storage.Mkdir("directory"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := storage.ReadFileRange("directory", 0, 1); err == nil {
+				t.Errorf("expected an error reading a directory")
+			}
+		},
+	)
+
+	t.Run(
+		"paths", func(t *testing.T) {
+			storage := newStorage(t)
+
+			// Check the paths that do not name a file
+			for _, name := range []string{"", "/", "..", "file\x00.txt"} {
+				if err := storage.WriteFile(name, []byte("x"), nil); err == nil {
+					t.Errorf("expected an error writing %q", name)
+				}
+			}
+
+			// Check the paths above the root are kept inside it, and backslashes are separators
+			writeFile(t, storage, "../../file.txt", "hello")
+			if content := readFile(t, storage, "/file.txt"); content != "hello" {
+				t.Errorf("expected hello, got %q", content)
+			}
+			if err := storage.Mkdir("a"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			writeFile(t, storage, "a\\file.txt", "inner")
+			if content := readFile(t, storage, "a/file.txt"); content != "inner" {
+				t.Errorf("expected inner, got %q", content)
+			}
+		},
+	)
+
+	t.Run(
+		"remove", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello")
+			if err := storage.RemoveFile("file.txt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := storage.Stat("file.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected fs.ErrNotExist, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"directories", func(t *testing.T) {
+			storage := newStorage(t)
+
+			// Create a directory with its parents
+			if err := storage.Mkdir("a/b"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := storage.Mkdir("a/b"); err != nil {
+				t.Errorf("unexpected error creating an existing directory: %v", err)
+			}
+			writeFile(t, storage, "a/b/file.txt", "hello")
+			writeFile(t, storage, "a/file.txt", "hello")
+			info, err := storage.Stat("a/b")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !info.IsDir {
+				t.Errorf("expected a directory")
+			}
+
+			// Check the listings
+			assertList(t, storage, "", "a")
+			assertList(t, storage, "a", "a/b", "a/file.txt")
+			assertList(t, storage, "a/b", "a/b/file.txt")
+
+			// Check the directories can not be read or removed as files
+			if _, err = storage.ReadFile("a"); err == nil {
+				t.Errorf("ReadFile: expected an error for a directory")
+			}
+			if err = storage.RemoveFile("a"); err == nil {
+				t.Errorf("RemoveFile: expected an error for a directory")
+			}
+			if err = storage.WriteFile("a", []byte("x"), nil); err == nil {
+				t.Errorf("WriteFile: expected an error for a directory")
+			}
+			if err = storage.Rmdir("a/file.txt"); err == nil {
+				t.Errorf("Rmdir: expected an error for a file")
+			}
+
+			// Remove the directories, they must be empty
+			if err = storage.Rmdir("a/b"); err == nil {
+				t.Errorf("Rmdir: expected an error for a directory that is not empty")
+			}
+			if err = storage.RemoveFile("a/b/file.txt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = storage.Rmdir("a/b"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			assertList(t, storage, "a", "a/file.txt")
+		},
+	)
+
+	t.Run(
+		"rename", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello")
+			if err := storage.Mkdir("a/b"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			writeFile(t, storage, "a/b/inner.txt", "inner")
+
+			// Rename a file, keeping its content and checksum
+			if err := storage.Rename("file.txt", "a/renamed.txt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content := readFile(t, storage, "a/renamed.txt"); content != "hello" {
+				t.Errorf("expected hello, got %q", content)
+			}
+			info, err := storage.Stat("a/renamed.txt")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Checksum != Checksum([]byte("hello")) {
+				t.Errorf("expected the checksum to be kept, got %q", info.Checksum)
+			}
+			if _, err = storage.Stat("file.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected fs.ErrNotExist, got %v", err)
+			}
+
+			// Rename a directory with its files
+			if err = storage.Rename("a/b", "c"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content := readFile(t, storage, "c/inner.txt"); content != "inner" {
+				t.Errorf("expected inner, got %q", content)
+			}
+			assertList(t, storage, "", "a", "c")
+
+			// Check the invalid renames
+			if err = storage.Rename("a/renamed.txt", "c/inner.txt"); err == nil {
+				t.Errorf("expected an error renaming to an existing file")
+			}
+			if err = storage.Rename("a", "a/inside"); err == nil {
+				t.Errorf("expected an error moving a directory inside itself")
+			}
+			if err = storage.Rename("missing.txt", "other.txt"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected fs.ErrNotExist, got %v", err)
+			}
+			if err = storage.Rename("a/renamed.txt", "missing/file.txt"); !errors.Is(err, ErrParentNotExist) {
+				t.Errorf("expected ErrParentNotExist, got %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"move", func(t *testing.T) {
+			storage := newStorage(t)
+			writeFile(t, storage, "file.txt", "hello")
+			if err := storage.Mkdir("a"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := Move(storage, "file.txt", "a"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertList(t, storage, "a", "a/file.txt")
+			if err := Move(storage, "a/file.txt", ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertList(t, storage, "", "a", "file.txt")
+		},
+	)
+}
+
+// writeFile writes a file of a test, overwriting it
+func writeFile(t *testing.T, storage Storage, name, content string) {
+	t.Helper()
+	if err := storage.WriteFile(name, []byte(content), nil); err != nil {
+		t.Fatalf("unexpected error writing %s: %v", name, err)
+	}
+}
+
+// readFile reads a file of a test
+func readFile(t *testing.T, storage Storage, name string) string {
+	t.Helper()
+	content, err := storage.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unexpected error reading %s: %v", name, err)
+	}
+	return string(content)
+}
+
+// assertList checks the names of the files and directories of a directory, in order
+func assertList(t *testing.T, storage Storage, directory string, expected ...string) {
+	t.Helper()
+	files, err := storage.List(directory)
+	if err != nil {
+		t.Fatalf("unexpected error listing %q: %v", directory, err)
+	}
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("listing %q: expected %v, got %v", directory, expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("listing %q: expected %v, got %v", directory, expected, names)
+			return
+		}
+	}
+}
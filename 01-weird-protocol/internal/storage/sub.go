@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// SubStorage is a directory of a storage used as a storage, the paths are resolved inside it
+type SubStorage struct {
+	storage   Storage
+	directory string
+}
+
+// NewSubStorage creates a new storage rooted at a directory of the given storage, which is created if it does not exist
+func NewSubStorage(storage Storage, directory string) (*SubStorage, error) {
+	cleanDirectory, err := CleanPath(directory)
+	if err != nil {
+		return nil, err
+	}
+	if err = storage.Mkdir(cleanDirectory); err != nil {
+		return nil, err
+	}
+	return &SubStorage{storage: storage, directory: cleanDirectory}, nil
+}
+
+// Directory returns the directory of the storage
+func (s *SubStorage) Directory() string {
+	return s.directory
+}
+
+// join returns the path of a name in the storage
+func (s *SubStorage) join(name string) (string, error) {
+	cleanName, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.directory, cleanName), nil
+}
+
+// trimDirectory removes the directory from a path of the storage
+func (s *SubStorage) trimDirectory(name string) string {
+	return strings.TrimPrefix(name, s.directory+"/")
+}
+
+// hideDirectory removes the directory from the paths of the errors of the storage
+func (s *SubStorage) hideDirectory(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: s.trimDirectory(pathErr.Path), Err: pathErr.Err}
+	}
+	return err
+}
+
+// WriteFile writes the content of a file atomically, its directory must exist. Nil options overwrite the file
+func (s *SubStorage) WriteFile(name string, content []byte, options *WriteOptions) error {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.WriteFile(joinedName, content, options))
+}
+
+// WriteFileFrom writes the content of a reader like WriteFile, without holding it in memory
+func (s *SubStorage) WriteFileFrom(name string, content io.ReadSeeker, options *WriteOptions) error {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.WriteFileFrom(joinedName, content, options))
+}
+
+// ReadFile reads the content of a file
+func (s *SubStorage) ReadFile(name string) ([]byte, error) {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.storage.ReadFile(joinedName)
+	return content, s.hideDirectory(err)
+}
+
+// ReadFileRange reads up to length bytes of a file from the offset, fewer at the end of the file and none after it
+func (s *SubStorage) ReadFileRange(name string, offset, length int64) ([]byte, error) {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.storage.ReadFileRange(joinedName, offset, length)
+	return content, s.hideDirectory(err)
+}
+
+// RemoveFile removes a file
+func (s *SubStorage) RemoveFile(name string) error {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.RemoveFile(joinedName))
+}
+
+// Stat returns the information of a file or directory
+func (s *SubStorage) Stat(name string) (*FileInfo, error) {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.storage.Stat(joinedName)
+	if err != nil {
+		return nil, s.hideDirectory(err)
+	}
+	info.Name = s.trimDirectory(info.Name)
+	return info, nil
+}
+
+// List lists the files and directories of a directory, sorted by name. An empty directory name is the root
+func (s *SubStorage) List(directory string) ([]FileInfo, error) {
+	// Get the directory
+	joinedDirectory := s.directory
+	if !IsRootPath(directory) {
+		var err error
+		if joinedDirectory, err = s.join(directory); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := s.storage.List(joinedDirectory)
+	if err != nil {
+		return nil, s.hideDirectory(err)
+	}
+	for i := range files {
+		files[i].Name = s.trimDirectory(files[i].Name)
+	}
+	return files, nil
+}
+
+// Mkdir creates a directory, with its missing parents
+func (s *SubStorage) Mkdir(name string) error {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.Mkdir(joinedName))
+}
+
+// Rmdir removes an empty directory
+func (s *SubStorage) Rmdir(name string) error {
+	joinedName, err := s.join(name)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.Rmdir(joinedName))
+}
+
+// Rename renames a file or directory, the new path must not exist and its directory must exist
+func (s *SubStorage) Rename(oldName, newName string) error {
+	joinedOldName, err := s.join(oldName)
+	if err != nil {
+		return err
+	}
+	joinedNewName, err := s.join(newName)
+	if err != nil {
+		return err
+	}
+	return s.hideDirectory(s.storage.Rename(joinedOldName, joinedNewName))
+}
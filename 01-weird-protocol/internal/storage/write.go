@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WriteMode is the policy of a write for an existing file
+type WriteMode string
+
+const (
+	// WriteOverwrite replaces the content of an existing file, it is the default mode
+	WriteOverwrite WriteMode = "overwrite"
+
+	// WriteCreate only creates new files, the write fails if the file exists
+	WriteCreate WriteMode = "create"
+
+	// WriteAppend appends the content to an existing file, or creates it
+	WriteAppend WriteMode = "append"
+)
+
+// WriteModes are the supported write modes
+var WriteModes = []WriteMode{WriteOverwrite, WriteCreate, WriteAppend}
+
+var (
+	// ErrFileExists is the error for a create-only write of an existing file
+	ErrFileExists = errors.New("file already exists")
+
+	// ErrPreconditionFailed is the error for a conditional write whose checksum does not match the current content of the file
+	ErrPreconditionFailed = errors.New("precondition failed: the file was modified")
+)
+
+// WriteOptions are the options of a write
+type WriteOptions struct {
+	// Mode is the policy for an existing file, the default is WriteOverwrite
+	Mode WriteMode
+
+	// IfMatch is the checksum of the content the file must have for the write to be done, it is ignored if it is empty
+	IfMatch string
+}
+
+// ParseWriteMode parses a write mode, an empty one is WriteOverwrite
+func ParseWriteMode(mode string) (WriteMode, error) {
+	if mode == "" {
+		return WriteOverwrite, nil
+	}
+	for _, writeMode := range WriteModes {
+		if WriteMode(mode) == writeMode {
+			return writeMode, nil
+		}
+	}
+
+	// Get the supported write modes
+	modes := make([]string, len(WriteModes))
+	for i, writeMode := range WriteModes {
+		modes[i] = string(writeMode)
+	}
+	return "", fmt.Errorf(
+		"unknown write mode %s, expected: %s",
+		mode,
+		strings.Join(modes, ", "),
+	)
+}
+
+// CheckWriteOptions checks the write options against the current checksum of the file, which is only got if the options have a condition
+func CheckWriteOptions(
+	name string,
+	exists bool,
+	currentChecksumFn func() (string, error),
+	options *WriteOptions,
+) error {
+	if options == nil {
+		return nil
+	}
+
+	// Check the condition
+	if options.IfMatch != "" {
+		if !exists {
+			return NotFoundError("open", name)
+		}
+		currentChecksum, err := currentChecksumFn()
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(options.IfMatch, currentChecksum) {
+			return ErrPreconditionFailed
+		}
+	}
+	if options.Mode == WriteCreate && exists {
+		return fmt.Errorf("%s: %w", name, ErrFileExists)
+	}
+	return nil
+}
+
+// IsAppend checks if the write options append the content to the file
+func IsAppend(options *WriteOptions) bool {
+	return options != nil && options.Mode == WriteAppend
+}
+
+// ApplyWriteOptions checks the write options against the current content of the file, if it exists, and returns the content to be written
+func ApplyWriteOptions(
+	name string,
+	current []byte,
+	exists bool,
+	content []byte,
+	options *WriteOptions,
+) ([]byte, error) {
+	err := CheckWriteOptions(
+		name, exists, func() (string, error) {
+			return Checksum(current), nil
+		}, options,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if IsAppend(options) {
+		return append(append([]byte(nil), current...), content...), nil
+	}
+	return content, nil
+}
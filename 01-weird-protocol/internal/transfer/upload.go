@@ -0,0 +1,314 @@
+package transfer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionTimeout is the time without chunks after which an upload session expires
+	SessionTimeout = 30 * time.Minute
+
+	// MaxUploadSize is the maximum size in bytes of an upload
+	MaxUploadSize = 1 << 30
+
+	// SessionIDSize is the size in bytes of the random session IDs
+	SessionIDSize = 16
+
+	// MaxSessionsPerUser is the maximum number of open upload sessions of a user, the anonymous requests share the same limit
+	MaxSessionsPerUser = 8
+)
+
+var (
+	// ErrSessionNotFound is the error for an unknown, expired or finished upload session
+	ErrSessionNotFound = errors.New("upload session not found")
+
+	// ErrUploadTooLarge is the error for an upload larger than its declared size or than the maximum size
+	ErrUploadTooLarge = errors.New("upload too large")
+
+	// ErrTooManySessions is the error for a user that has the maximum number of open upload sessions
+	ErrTooManySessions = errors.New("too many upload sessions")
+)
+
+type (
+	// OffsetMismatchError is the error for a chunk that does not start at or before the committed offset of its session
+	OffsetMismatchError struct {
+		// Expected is the committed offset of the session
+		Expected int64
+	}
+
+	// UploadSession is an upload whose chunks are written to a temporary file until it is committed
+	UploadSession struct {
+		// ID is the random ID of the session
+		ID string
+
+		// User is the user that began the session, empty if it is anonymous
+		User string
+
+		// Filename is the path of the file in the storage of the user
+		Filename string
+
+		// Size is the declared size in bytes of the file, -1 if it is unknown
+		Size int64
+
+		// Checksum is the declared checksum of the file, empty if it is unknown
+		Checksum string
+
+		// Options are the write options of the commit
+		Options *internalstorage.WriteOptions
+
+		mutex        sync.Mutex
+		file         *os.File
+		offset       int64
+		lastActivity time.Time
+		checkSizeFn  func(size int64) error
+	}
+
+	// Manager manages the upload sessions, which are kept until they are committed, aborted or expire. They survive the connections, but not the server
+	Manager struct {
+		mutex     sync.Mutex
+		directory string
+		sessions  map[string]*UploadSession
+	}
+)
+
+// Error returns the error message
+func (o *OffsetMismatchError) Error() string {
+	return fmt.Sprintf("offset mismatch, expected: %d", o.Expected)
+}
+
+// NewManager creates a new upload sessions manager, whose temporary files are written to the given directory, which is created if it does not exist
+func NewManager(directory string) (*Manager, error) {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		directory: directory,
+		sessions:  make(map[string]*UploadSession),
+	}, nil
+}
+
+// removeExpired removes the expired sessions, the manager must be locked
+func (m *Manager) removeExpired() {
+	for id, session := range m.sessions {
+		session.mutex.Lock()
+		expired := time.Since(session.lastActivity) > SessionTimeout
+		session.mutex.Unlock()
+		if expired {
+			session.close()
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// countSessions counts the sessions of a user, the manager must be locked
+func (m *Manager) countSessions(user string) int {
+	count := 0
+	for _, session := range m.sessions {
+		if session.User == user {
+			count++
+		}
+	}
+	return count
+}
+
+// Begin begins an upload session. The size check function checks if the file can be written with a size, such as the quota of the storage, and it is called with the declared size and with the received size after each chunk
+func (m *Manager) Begin(
+	user, filename string,
+	size int64,
+	checksum string,
+	options *internalstorage.WriteOptions,
+	checkSizeFn func(size int64) error,
+) (*UploadSession, error) {
+	// Check the path and the size
+	if _, err := internalstorage.CleanPath(filename); err != nil {
+		return nil, err
+	}
+	if size > MaxUploadSize {
+		return nil, ErrUploadTooLarge
+	}
+	if err := checkSizeFn(max(size, 0)); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removeExpired()
+	if m.countSessions(user) >= MaxSessionsPerUser {
+		return nil, ErrTooManySessions
+	}
+
+	// Generate the session ID
+	idBytes := make([]byte, SessionIDSize)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	// Create the temporary file of the chunks
+	file, err := os.CreateTemp(m.directory, "upload-"+id+"-*")
+	if err != nil {
+		return nil, err
+	}
+	session := &UploadSession{
+		ID:           id,
+		User:         user,
+		Filename:     filename,
+		Size:         size,
+		Checksum:     checksum,
+		Options:      options,
+		file:         file,
+		lastActivity: time.Now(),
+		checkSizeFn:  checkSizeFn,
+	}
+	m.sessions[id] = session
+	return session, nil
+}
+
+// Get gets an upload session of a user
+func (m *Manager) Get(user, id string) (*UploadSession, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removeExpired()
+
+	// The sessions of other users are reported as not found, so their IDs are not revealed
+	session, ok := m.sessions[id]
+	if !ok || session.User != user {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// remove removes an upload session and its temporary file
+func (m *Manager) remove(session *UploadSession) {
+	m.mutex.Lock()
+	delete(m.sessions, session.ID)
+	m.mutex.Unlock()
+	session.close()
+}
+
+// Abort aborts an upload session of a user, removing its chunks
+func (m *Manager) Abort(user, id string) error {
+	session, err := m.Get(user, id)
+	if err != nil {
+		return err
+	}
+	m.remove(session)
+	return nil
+}
+
+// Commit writes the file of an upload session of a user to the storage, after checking its declared size and checksum. The chunks are streamed from the temporary file. The session is removed unless the write fails
+func (m *Manager) Commit(
+	user, id string,
+	storage internalstorage.Storage,
+) (*UploadSession, error) {
+	session, err := m.Get(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the session while it is committed, so its chunks do not change
+	session.mutex.Lock()
+	if session.file == nil {
+		session.mutex.Unlock()
+		return nil, ErrSessionNotFound
+	}
+	session.lastActivity = time.Now()
+	content := io.NewSectionReader(session.file, 0, session.offset)
+
+	// Check the size and the checksum, the session is aborted if they do not match
+	if err = session.verify(content); err != nil {
+		session.mutex.Unlock()
+		m.remove(session)
+		return nil, err
+	}
+
+	// Write the file
+	err = storage.WriteFileFrom(session.Filename, content, session.Options)
+	session.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	m.remove(session)
+	return session, nil
+}
+
+// verify checks the received content of the session against its declared size and checksum, the session must be locked
+func (u *UploadSession) verify(content *io.SectionReader) error {
+	if u.Size >= 0 && content.Size() != u.Size {
+		return fmt.Errorf(
+			"incomplete upload, received %d of %d bytes",
+			content.Size(),
+			u.Size,
+		)
+	}
+	if u.Checksum == "" {
+		return nil
+	}
+	checksum, err := internalstorage.ReadChecksum(content)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(checksum, u.Checksum) {
+		return internalstorage.ErrChecksumMismatch
+	}
+	return nil
+}
+
+// close closes and removes the temporary file of the session
+func (u *UploadSession) close() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.file == nil {
+		return
+	}
+	_ = u.file.Close()
+	_ = os.Remove(u.file.Name())
+	u.file = nil
+}
+
+// Offset returns the committed offset of the session, the size of the received data
+func (u *UploadSession) Offset() int64 {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.offset
+}
+
+// WriteChunk writes a chunk at the given offset and returns the new committed offset. The offset can be before the committed one, so a chunk can be resent when its confirmation is lost, but not after it
+func (u *UploadSession) WriteChunk(offset int64, data []byte) (int64, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.file == nil {
+		return 0, ErrSessionNotFound
+	}
+
+	// Check the offset and the size
+	if offset < 0 || offset > u.offset {
+		return u.offset, &OffsetMismatchError{Expected: u.offset}
+	}
+	end := offset + int64(len(data))
+	if end > MaxUploadSize || (u.Size >= 0 && end > u.Size) {
+		return u.offset, ErrUploadTooLarge
+	}
+	if end > u.offset {
+		if err := u.checkSizeFn(end); err != nil {
+			return u.offset, err
+		}
+	}
+
+	// Write the chunk
+	if _, err := u.file.WriteAt(data, offset); err != nil {
+		return u.offset, err
+	}
+	u.offset = max(u.offset, end)
+	u.lastActivity = time.Now()
+	return u.offset, nil
+}
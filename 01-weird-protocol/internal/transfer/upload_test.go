@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"errors"
+	internalstorage "github.com/ralvarezdev/uru-networks-protocol-programming/01-weird-protocol/internal/storage"
+	"testing"
+)
+
+// noSizeCheck accepts every size of an upload
+func noSizeCheck(size int64) error {
+	return nil
+}
+
+// TestUploadCommit tests that the chunks of a session are written to the storage when it is committed
+func TestUploadCommit(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage := internalstorage.NewMemoryStorage()
+
+	// Upload the chunks, resending the first one
+	content := []byte("hello world")
+	session, err := manager.Begin("alice", "file.txt", int64(len(content)), internalstorage.Checksum(content), nil, noSizeCheck)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, offset := range []int64{0, 0, 6} {
+		end := min(offset+6, int64(len(content)))
+		if _, err = session.WriteChunk(offset, content[offset:end]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Commit the session
+	if _, err = manager.Commit("alice", session.ID, storage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written, err := storage.ReadFile("file.txt")
+	if err != nil || string(written) != string(content) {
+		t.Errorf("expected %q, got %q, %v", content, written, err)
+	}
+	if _, err = manager.Get("alice", session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected the session to be removed, got %v", err)
+	}
+}
+
+// TestUploadCommitChecksumMismatch tests that a session whose content does not match its checksum is aborted
+func TestUploadCommitChecksumMismatch(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage := internalstorage.NewMemoryStorage()
+
+	session, err := manager.Begin("", "file.txt", -1, internalstorage.Checksum([]byte("other")), nil, noSizeCheck)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = session.WriteChunk(0, []byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = manager.Commit("", session.ID, storage); !errors.Is(err, internalstorage.ErrChecksumMismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err = storage.Stat("file.txt"); err == nil {
+		t.Errorf("expected the file not to be written")
+	}
+	if _, err = manager.Get("", session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected the session to be removed, got %v", err)
+	}
+}
+
+// TestUploadSizeCheck tests that the declared size and the received chunks are checked
+func TestUploadSizeCheck(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	errTooLarge := errors.New("too large")
+	checkSizeFn := func(size int64) error {
+		if size > 8 {
+			return errTooLarge
+		}
+		return nil
+	}
+
+	// Check the declared size
+	if _, err = manager.Begin("alice", "file.txt", 9, "", nil, checkSizeFn); !errors.Is(err, errTooLarge) {
+		t.Errorf("expected the declared size to be rejected, got %v", err)
+	}
+
+	// Check the received size of a session of unknown size
+	session, err := manager.Begin("alice", "file.txt", -1, "", nil, checkSizeFn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = session.WriteChunk(0, []byte("12345")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	offset, err := session.WriteChunk(5, []byte("6789"))
+	if !errors.Is(err, errTooLarge) {
+		t.Errorf("expected the chunk to be rejected, got %v", err)
+	}
+	if offset != 5 {
+		t.Errorf("expected the offset to stay at 5, got %d", offset)
+	}
+}
+
+// TestUploadSessionsLimit tests the maximum number of open sessions of a user
+func TestUploadSessionsLimit(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Open the maximum number of sessions
+	var session *UploadSession
+	for range MaxSessionsPerUser {
+		if session, err = manager.Begin("alice", "file.txt", -1, "", nil, noSizeCheck); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err = manager.Begin("alice", "file.txt", -1, "", nil, noSizeCheck); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("expected ErrTooManySessions, got %v", err)
+	}
+
+	// Check the other users have their own limit
+	if _, err = manager.Begin("bob", "file.txt", -1, "", nil, noSizeCheck); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Check a finished session releases its place
+	if err = manager.Abort("alice", session.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = manager.Begin("alice", "file.txt", -1, "", nil, noSizeCheck); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}